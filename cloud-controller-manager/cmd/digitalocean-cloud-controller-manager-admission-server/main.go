@@ -21,6 +21,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"go.uber.org/zap/zapcore"
 	"golang.org/x/oauth2"
@@ -39,6 +40,10 @@ const (
 	doOverrideAPIURLEnv        = "DO_OVERRIDE_URL"
 	doClusterIDEnv             = "DO_CLUSTER_ID"
 	doClusterVPCIDEnv          = "DO_CLUSTER_VPC_ID"
+
+	// lbInventoryMaxAge is the maximum age of the load balancer inventory
+	// used to look up existing load balancers by name.
+	lbInventoryMaxAge = 5 * time.Minute
 )
 
 var loggerVerbosity = flag.Int("v", 0, "logger verbosity")
@@ -78,6 +83,8 @@ func startAdmissionServer() error {
 	vpcID := os.Getenv(doClusterVPCIDEnv)
 	lbAdmissionHandler.WithVPCID(vpcID)
 
+	lbAdmissionHandler.WithLBInventory(lbInventoryMaxAge)
+
 	ll.Info("registering admission handlers")
 	server.Register("/lb-service", &webhook.Admission{Handler: lbAdmissionHandler})
 
@@ -21,6 +21,28 @@ import (
 	"strconv"
 )
 
+// annotationError is returned if the value of a Service annotation is
+// invalid. It names the annotation so that the failure can be reported on the
+// Service.
+type annotationError struct {
+	name string
+	err  error
+}
+
+func (e annotationError) Error() string {
+	return e.err.Error()
+}
+
+func (e annotationError) Unwrap() error {
+	return e.err
+}
+
+// invalidAnnotation returns an annotationError for the annotation with the
+// given name, formatting the error according to format.
+func invalidAnnotation(name, format string, a ...interface{}) error {
+	return annotationError{name: name, err: fmt.Errorf(format, a...)}
+}
+
 func getBool(annotations map[string]string, key string) (managed bool, found bool, err error) {
 	value, ok := annotations[key]
 	if !ok {
@@ -29,7 +51,7 @@ func getBool(annotations map[string]string, key string) (managed bool, found boo
 
 	parsed, err := strconv.ParseBool(value)
 	if err != nil {
-		return false, false, fmt.Errorf("cannot convert value %q for annotation %q to bool: %s", value, key, err)
+		return false, false, invalidAnnotation(key, "cannot convert value %q for annotation %q to bool: %s", value, key, err)
 	}
 
 	return parsed, true, nil
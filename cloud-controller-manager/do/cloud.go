@@ -25,6 +25,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/digitalocean/godo"
@@ -57,6 +58,19 @@ const (
 	publicAccessFirewallTagsEnv string = "PUBLIC_ACCESS_FIREWALL_TAGS"
 	regionEnv                   string = "REGION"
 	doAPIRateLimitQPSEnv        string = "DO_API_RATE_LIMIT_QPS"
+	nodeIPFamiliesEnv           string = "NODE_IP_FAMILIES"
+	nodePublicIPRequiredEnv     string = "NODE_PUBLIC_IP_REQUIRED"
+	lbDriftDetectionIntervalEnv string = "LB_DRIFT_DETECTION_INTERVAL"
+	lbDriftAutoCorrectEnv       string = "LB_DRIFT_AUTO_CORRECT"
+	lbGCModeEnv                 string = "LB_GC_MODE"
+	lbGCGracePeriodEnv          string = "LB_GC_GRACE_PERIOD"
+	lbNameTemplateEnv           string = "LB_NAME_TEMPLATE"
+	lbRemediationStrategyEnv    string = "LB_REMEDIATION_STRATEGY"
+	lbRemediationTimeoutEnv     string = "LB_REMEDIATION_TIMEOUT"
+	lbEndpointAwareBackendsEnv  string = "LB_ENDPOINT_AWARE_BACKENDS"
+	lbNodeDrainPeriodEnv        string = "LB_NODE_DRAIN_PERIOD"
+	lbUpdateBatchWindowEnv      string = "LB_UPDATE_BATCH_WINDOW"
+	lbUpdateBatchConcurrencyEnv string = "LB_UPDATE_BATCH_CONCURRENCY"
 )
 
 var version string
@@ -75,12 +89,21 @@ func (t *tokenSource) Token() (*oauth2.Token, error) {
 type cloud struct {
 	client        *godo.Client
 	instances     cloudprovider.Instances
+	instancesV2   cloudprovider.InstancesV2
 	zones         cloudprovider.Zones
 	loadbalancers cloudprovider.LoadBalancer
 	metrics       metrics
 
 	resources *resources
 
+	lbDriftDetectionInterval time.Duration
+	lbDriftAutoCorrect       bool
+	lbEndpointAwareBackends  bool
+
+	// firewallController manages the worker firewall if a firewall name is
+	// configured. It is set up by Initialize.
+	firewallController *FirewallController
+
 	httpServer *http.Server
 }
 
@@ -139,6 +162,78 @@ func newCloud() (cloudprovider.Interface, error) {
 	tags := strings.Split(firewallTags, ",")
 	resources := newResources(clusterID, clusterVPCID, publicAccessFirewall{firewallName, tags}, doClient)
 
+	if ipFamiliesRaw := os.Getenv(nodeIPFamiliesEnv); ipFamiliesRaw != "" {
+		ipFamilies, err := parseIPFamilies(ipFamiliesRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", nodeIPFamiliesEnv, err)
+		}
+		klog.Infof("Reporting node addresses for IP families %v", ipFamilies)
+		resources.nodeAddresses.ipFamilies = ipFamilies
+	}
+
+	if publicIPRequiredRaw := os.Getenv(nodePublicIPRequiredEnv); publicIPRequiredRaw != "" {
+		publicIPRequired, err := strconv.ParseBool(publicIPRequiredRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", nodePublicIPRequiredEnv, err)
+		}
+		if !publicIPRequired {
+			klog.Info("Allowing nodes without a public IP address")
+		}
+		resources.nodeAddresses.publicIPOptional = !publicIPRequired
+	}
+
+	resources.lbGC = lbGCConfig{
+		mode:        lbGCModeDisabled,
+		gracePeriod: defaultLBGCGracePeriod,
+	}
+	if modeRaw := os.Getenv(lbGCModeEnv); modeRaw != "" {
+		resources.lbGC.mode, err = parseLBGCMode(modeRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbGCModeEnv, err)
+		}
+	}
+	if gracePeriodRaw := os.Getenv(lbGCGracePeriodEnv); gracePeriodRaw != "" {
+		resources.lbGC.gracePeriod, err = time.ParseDuration(gracePeriodRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbGCGracePeriodEnv, err)
+		}
+	}
+	if resources.lbGC.enabled() && clusterID == "" {
+		return nil, fmt.Errorf("environment variable %q is required when garbage-collecting load-balancers", doClusterIDEnv)
+	}
+
+	if nameTemplateRaw := os.Getenv(lbNameTemplateEnv); nameTemplateRaw != "" {
+		resources.lbNameTemplate, err = parseLBNameTemplate(nameTemplateRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbNameTemplateEnv, err)
+		}
+		klog.Infof("Naming load-balancers after template %q", nameTemplateRaw)
+	}
+
+	lbDriftDetectionInterval := defaultLBDriftDetectionInterval
+	if intervalRaw := os.Getenv(lbDriftDetectionIntervalEnv); intervalRaw != "" {
+		lbDriftDetectionInterval, err = time.ParseDuration(intervalRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbDriftDetectionIntervalEnv, err)
+		}
+	}
+
+	var lbDriftAutoCorrect bool
+	if autoCorrectRaw := os.Getenv(lbDriftAutoCorrectEnv); autoCorrectRaw != "" {
+		lbDriftAutoCorrect, err = strconv.ParseBool(autoCorrectRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbDriftAutoCorrectEnv, err)
+		}
+	}
+
+	var lbEndpointAwareBackends bool
+	if endpointAwareRaw := os.Getenv(lbEndpointAwareBackendsEnv); endpointAwareRaw != "" {
+		lbEndpointAwareBackends, err = strconv.ParseBool(endpointAwareRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbEndpointAwareBackendsEnv, err)
+		}
+	}
+
 	var httpServer *http.Server
 	if debugAddr := os.Getenv(debugAddrEnv); debugAddr != "" {
 		debugMux := http.NewServeMux()
@@ -149,6 +244,48 @@ func newCloud() (cloudprovider.Interface, error) {
 		}
 	}
 
+	lbs := newLoadBalancers(resources, region)
+	if strategyRaw := os.Getenv(lbRemediationStrategyEnv); strategyRaw != "" {
+		lbs.remediationStrategy, err = parseLBRemediationStrategy(strategyRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbRemediationStrategyEnv, err)
+		}
+	}
+	if timeoutRaw := os.Getenv(lbRemediationTimeoutEnv); timeoutRaw != "" {
+		lbs.remediationTimeout, err = time.ParseDuration(timeoutRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbRemediationTimeoutEnv, err)
+		}
+		if lbs.remediationTimeout < time.Minute {
+			return nil, fmt.Errorf("value of environment variable %s must be at least 1m, got %s", lbRemediationTimeoutEnv, timeoutRaw)
+		}
+	}
+	if drainPeriodRaw := os.Getenv(lbNodeDrainPeriodEnv); drainPeriodRaw != "" {
+		lbs.drainPeriod, err = time.ParseDuration(drainPeriodRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbNodeDrainPeriodEnv, err)
+		}
+	}
+	if batchWindowRaw := os.Getenv(lbUpdateBatchWindowEnv); batchWindowRaw != "" {
+		batchWindow, err := time.ParseDuration(batchWindowRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbUpdateBatchWindowEnv, err)
+		}
+		batchConcurrency := defaultLBUpdateBatchConcurrency
+		if concurrencyRaw := os.Getenv(lbUpdateBatchConcurrencyEnv); concurrencyRaw != "" {
+			batchConcurrency, err = strconv.Atoi(concurrencyRaw)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse value from environment variable %s: %s", lbUpdateBatchConcurrencyEnv, err)
+			}
+			if batchConcurrency < 1 {
+				return nil, fmt.Errorf("environment variable %s must be at least 1", lbUpdateBatchConcurrencyEnv)
+			}
+		}
+		if batchWindow > 0 {
+			lbs.updateBatcher = newLBUpdateBatcher(lbs, batchWindow, batchConcurrency)
+		}
+	}
+
 	var addr string
 	if metricsAddr := os.Getenv(metricsAddrEnv); metricsAddr != "" {
 		addrHost, addrPort, err := net.SplitHostPort(metricsAddr)
@@ -161,11 +298,16 @@ func newCloud() (cloudprovider.Interface, error) {
 	return &cloud{
 		client:        doClient,
 		instances:     newInstances(resources, region),
+		instancesV2:   newInstancesV2(resources, region),
 		zones:         newZones(resources, region),
-		loadbalancers: newLoadBalancers(resources, region),
+		loadbalancers: lbs,
 		metrics:       newMetrics(addr),
 		resources:     resources,
 
+		lbDriftDetectionInterval: lbDriftDetectionInterval,
+		lbDriftAutoCorrect:       lbDriftAutoCorrect,
+		lbEndpointAwareBackends:  lbEndpointAwareBackends,
+
 		httpServer: httpServer,
 	}, nil
 }
@@ -182,29 +324,78 @@ func (c *cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 
 	res := NewResourcesController(c.resources, sharedInformer.Core().V1().Services(), clientset)
 
+	var (
+		driftDetector       *lbDriftDetector
+		endpointsController *lbEndpointsController
+		drainCoordinator    *lbDrainCoordinator
+		updateBatcher       *lbUpdateBatcher
+		disownedStatusSync  func() error
+	)
+	if lbs, ok := c.loadbalancers.(*loadBalancers); ok {
+		lbs.recorder = newEventRecorder(clientset)
+		lbs.svcLister = sharedInformer.Core().V1().Services().Lister()
+		lbs.nodeLister = sharedInformer.Core().V1().Nodes().Lister()
+
+		if c.lbDriftDetectionInterval > 0 {
+			driftDetector = newLBDriftDetector(lbs, sharedInformer.Core().V1().Services(), sharedInformer.Core().V1().Nodes(), c.lbDriftAutoCorrect)
+		}
+		drainCoordinator = newLBDrainCoordinator(lbs, sharedInformer.Core().V1().Services(), sharedInformer.Core().V1().Nodes())
+		updateBatcher = lbs.updateBatcher
+		disownedStatusSync = lbs.syncDisownedStatuses
+		if c.lbEndpointAwareBackends {
+			endpointsController = newLBEndpointsController(lbs, sharedInformer.Core().V1().Services(), sharedInformer.Core().V1().Nodes(), sharedInformer.Discovery().V1().EndpointSlices())
+		}
+	}
+
+	// All informers must be requested before the shared informer factory is
+	// started, or they will never sync.
+	if c.resources.firewall.name != "" {
+		fm := &firewallManager{
+			client:             c.client,
+			fwCache:            &firewallCache{},
+			workerFirewallName: c.resources.firewall.name,
+			workerFirewallTags: c.resources.firewall.tags,
+			metrics:            c.metrics,
+		}
+		c.firewallController = NewFirewallController(c.resources.kclient, c.client, sharedInformer.Core().V1().Services(), sharedInformer.Core().V1().Nodes(), fm)
+	}
+
 	sharedInformer.Start(nil)
 	sharedInformer.WaitForCacheSync(nil)
 
 	go res.Run(stop)
+	if driftDetector != nil {
+		klog.Infof("Detecting load-balancer drift every %s (auto-correct: %t)", c.lbDriftDetectionInterval, c.lbDriftAutoCorrect)
+		go (&tickerSyncer{}).Sync("load-balancer drift detector", c.lbDriftDetectionInterval, stop, driftDetector.detect)
+	}
+	if drainCoordinator != nil {
+		if drainCoordinator.lbs.drainPeriod > 0 {
+			klog.Infof("Draining nodes from load-balancers for %s before their deletion", drainCoordinator.lbs.drainPeriod)
+		}
+		go (&tickerSyncer{}).Sync("load-balancer node drain coordinator", lbDrainSyncPeriod, stop, drainCoordinator.sync)
+	}
+	if disownedStatusSync != nil {
+		go (&tickerSyncer{}).Sync("disowned load-balancer status syncer", lbDisownedStatusSyncPeriod, stop, disownedStatusSync)
+	}
+	if updateBatcher != nil {
+		klog.Infof("Coalescing load-balancer node updates within %s using %d worker(s)", updateBatcher.window, updateBatcher.concurrency)
+		go updateBatcher.Run(stop)
+	}
+	if endpointsController != nil {
+		klog.Info("Targeting nodes hosting ready endpoints for Services with endpoint-aware backends")
+		go endpointsController.Run(stop)
+	}
 	go c.serveDebug(stop)
 	go c.serveMetrics()
 
-	if c.resources.firewall.name == "" {
+	if c.firewallController == nil {
 		klog.Info("Nothing to manage since firewall name was not provided")
 		return
 	}
 	klog.Infof("Managing the firewall using provided firewall worker name: %s", c.resources.firewall.name)
-	fm := &firewallManager{
-		client:             c.client,
-		fwCache:            &firewallCache{},
-		workerFirewallName: c.resources.firewall.name,
-		workerFirewallTags: c.resources.firewall.tags,
-		metrics:            c.metrics,
-	}
 	ctx := context.Background()
-	fc := NewFirewallController(c.resources.kclient, c.client, sharedInformer.Core().V1().Services(), fm)
-	go fc.runWorker()
-	go fc.Run(ctx, stop, firewallReconcileFrequency)
+	go c.firewallController.runWorker()
+	go c.firewallController.Run(ctx, stop, firewallReconcileFrequency)
 }
 
 func (c *cloud) serveDebug(stop <-chan struct{}) {
@@ -226,16 +417,33 @@ func (c *cloud) serveDebug(stop <-chan struct{}) {
 	}
 }
 
-func (c *cloud) serveMetrics() {
+// registerMetricsOnce guards registering metrics with the global registry and
+// the metrics handler with the default mux, which panic if done twice.
+var registerMetricsOnce sync.Once
+
+func registerMetrics() {
 	http.Handle("/metrics", promhttp.Handler())
 
-	// register metrics
 	prometheus.MustRegister(apiOperationDuration)
 	prometheus.MustRegister(apiOperationsTotal)
 	prometheus.MustRegister(resourceSyncDuration)
 	prometheus.MustRegister(resourceSyncsTotal)
 	prometheus.MustRegister(reconcileDuration)
 	prometheus.MustRegister(reconcilesTotal)
+	prometheus.MustRegister(dropletInventoryLookupsTotal)
+	prometheus.MustRegister(lbDrift)
+	prometheus.MustRegister(lbDriftFieldsTotal)
+	prometheus.MustRegister(lbGCOrphans)
+	prometheus.MustRegister(lbGCDeletionsTotal)
+	prometheus.MustRegister(lbRemediationsTotal)
+	prometheus.MustRegister(lbNodesDraining)
+	prometheus.MustRegister(lbNodeDrainsTotal)
+	prometheus.MustRegister(lbUpdatesCoalescedTotal)
+	prometheus.MustRegister(lbBatchedUpdatesTotal)
+}
+
+func (c *cloud) serveMetrics() {
+	registerMetricsOnce.Do(registerMetrics)
 
 	if err := http.ListenAndServe(c.metrics.host, nil); err != http.ErrServerClosed {
 		klog.Warningf("Metrics server has not been configured: %s", err)
@@ -251,10 +459,7 @@ func (c *cloud) Instances() (cloudprovider.Instances, bool) {
 }
 
 func (c *cloud) InstancesV2() (cloudprovider.InstancesV2, bool) {
-	// TODO: Implement the InstancesV2 interface. Our API should be sufficient
-	// to fetch all the necessary implementation, but it's not required at the
-	// moment.
-	return nil, false
+	return c.instancesV2, true
 }
 
 func (c *cloud) Zones() (cloudprovider.Zones, bool) {
@@ -0,0 +1,98 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	restclient "k8s.io/client-go/rest"
+)
+
+type fakeClientBuilder struct {
+	client kubernetes.Interface
+}
+
+func (f *fakeClientBuilder) Config(string) (*restclient.Config, error) {
+	return &restclient.Config{}, nil
+}
+
+func (f *fakeClientBuilder) ConfigOrDie(string) *restclient.Config {
+	return &restclient.Config{}
+}
+
+func (f *fakeClientBuilder) Client(string) (kubernetes.Interface, error) {
+	return f.client, nil
+}
+
+func (f *fakeClientBuilder) ClientOrDie(string) kubernetes.Interface {
+	return f.client
+}
+
+func TestInitialize_firewallNodeInformer(t *testing.T) {
+	// The firewall API is not exercised by this test.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	client, err := godo.New(ts.Client(), godo.SetBaseURL(ts.URL))
+	if err != nil {
+		t.Fatalf("failed to create godo client: %s", err)
+	}
+
+	fakeDroplet := &fakeDropletService{
+		listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+			return nil, newFakeOKResponse(), nil
+		},
+	}
+	fakeLB := &fakeLBService{
+		listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+			return nil, newFakeOKResponse(), nil
+		},
+	}
+	resources := newResources("", "", publicAccessFirewall{name: "k8s-worker-firewall"}, newFakeClient(fakeDroplet, fakeLB, nil))
+
+	// Drift detection, draining, and endpoint-aware backends are disabled,
+	// so the firewall controller is the only user of the node informer.
+	c := &cloud{
+		client:        client,
+		loadbalancers: newLoadBalancers(resources, "nyc3"),
+		metrics:       newMetrics("127.0.0.1:0"),
+		resources:     resources,
+	}
+	node := newNodeSelectorTestNode("node-1", "digitalocean://100", nil)
+	stop := make(chan struct{})
+	defer close(stop)
+	c.Initialize(&fakeClientBuilder{client: fake.NewSimpleClientset(node)}, stop)
+
+	if c.firewallController == nil {
+		t.Fatal("firewall controller was not set up")
+	}
+	nodes, err := c.firewallController.nodeLister.List(labels.Everything())
+	if err != nil {
+		t.Fatalf("failed to list nodes: %s", err)
+	}
+	if len(nodes) != 1 {
+		t.Errorf("got %d node(s) from the firewall controller's lister, want 1", len(nodes))
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/digitalocean/godo"
 	v1 "k8s.io/api/core/v1"
@@ -29,11 +31,29 @@ import (
 const apiResultsPerPage = 200
 
 func allDropletList(ctx context.Context, client *godo.Client) ([]godo.Droplet, error) {
+	return listAllDroplets(ctx, client.Droplets.List)
+}
+
+// allDropletListByTag returns all droplets tagged with tag.
+func allDropletListByTag(ctx context.Context, client *godo.Client, tag string) ([]godo.Droplet, error) {
+	return listAllDroplets(ctx, func(ctx context.Context, opt *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+		return client.Droplets.ListByTag(ctx, tag, opt)
+	})
+}
+
+// allDropletListByName returns all droplets named name.
+func allDropletListByName(ctx context.Context, client *godo.Client, name string) ([]godo.Droplet, error) {
+	return listAllDroplets(ctx, func(ctx context.Context, opt *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+		return client.Droplets.ListByName(ctx, name, opt)
+	})
+}
+
+func listAllDroplets(ctx context.Context, listFn func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error)) ([]godo.Droplet, error) {
 	list := []godo.Droplet{}
 
 	opt := &godo.ListOptions{Page: 1, PerPage: apiResultsPerPage}
 	for {
-		droplets, resp, err := client.Droplets.List(ctx, opt)
+		droplets, resp, err := listFn(ctx, opt)
 		if err != nil {
 			return nil, err
 		}
@@ -124,8 +144,68 @@ func allLoadBalancerList(ctx context.Context, client *godo.Client) ([]godo.LoadB
 	return list, nil
 }
 
-// nodeAddresses returns a []v1.NodeAddress from droplet.
-func nodeAddresses(droplet *godo.Droplet) ([]v1.NodeAddress, error) {
+// nodeAddressConfig controls which droplet addresses are reported as node
+// addresses. The zero value reports IPv4 addresses only.
+type nodeAddressConfig struct {
+	// ipFamilies lists the IP families to report addresses for in order of
+	// preference. The public address of the first (primary) family is
+	// required unless publicIPOptional is set; addresses of any further
+	// families are reported if present.
+	ipFamilies []v1.IPFamily
+
+	// publicIPOptional allows droplets without a public address (e.g., nodes
+	// that egress through a NAT gateway) to report their internal addresses
+	// only.
+	publicIPOptional bool
+}
+
+func (c nodeAddressConfig) families() []v1.IPFamily {
+	if len(c.ipFamilies) == 0 {
+		return []v1.IPFamily{v1.IPv4Protocol}
+	}
+	return c.ipFamilies
+}
+
+func (c nodeAddressConfig) hasFamily(family v1.IPFamily) bool {
+	for _, f := range c.families() {
+		if f == family {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIPFamilies parses a comma-separated list of IP families (e.g.,
+// "ipv4,ipv6") into an ordered list of distinct IP families.
+func parseIPFamilies(raw string) ([]v1.IPFamily, error) {
+	var families []v1.IPFamily
+	seen := map[v1.IPFamily]bool{}
+	for _, val := range strings.Split(raw, ",") {
+		var family v1.IPFamily
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "ipv4":
+			family = v1.IPv4Protocol
+		case "ipv6":
+			family = v1.IPv6Protocol
+		default:
+			return nil, fmt.Errorf("invalid IP family %q (supported: ipv4, ipv6)", val)
+		}
+
+		if seen[family] {
+			return nil, fmt.Errorf("duplicate IP family %q", val)
+		}
+		seen[family] = true
+		families = append(families, family)
+	}
+
+	return families, nil
+}
+
+// nodeAddresses returns a []v1.NodeAddress from droplet. The host name is
+// followed by the internal and then the external addresses, each ordered by
+// the IP family preference of cfg. The private IPv4 address is always
+// reported since droplets always have one.
+func nodeAddresses(droplet *godo.Droplet, cfg nodeAddressConfig) ([]v1.NodeAddress, error) {
 	var addresses []v1.NodeAddress
 	addresses = append(addresses, v1.NodeAddress{Type: v1.NodeHostName, Address: droplet.Name})
 
@@ -133,13 +213,76 @@ func nodeAddresses(droplet *godo.Droplet) ([]v1.NodeAddress, error) {
 	if err != nil || privateIP == "" {
 		return nil, fmt.Errorf("could not get private ip: %v", err)
 	}
-	addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: privateIP})
 
-	publicIP, err := droplet.PublicIPv4()
-	if err != nil || publicIP == "" {
-		return nil, fmt.Errorf("could not get public ip: %v", err)
+	// The private IPv4 address is always reported, last if IPv4 is not among
+	// the preferred IP families. The private IPv6 address is optional.
+	for _, family := range cfg.families() {
+		switch family {
+		case v1.IPv4Protocol:
+			addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: privateIP})
+		case v1.IPv6Protocol:
+			if privateIPv6 := dropletIPv6(droplet, "private"); privateIPv6 != "" {
+				addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: privateIPv6})
+			}
+		}
+	}
+	if !cfg.hasFamily(v1.IPv4Protocol) {
+		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: privateIP})
+	}
+
+	for i, family := range cfg.families() {
+		var publicIP string
+		switch family {
+		case v1.IPv4Protocol:
+			publicIP, err = droplet.PublicIPv4()
+		case v1.IPv6Protocol:
+			publicIP, err = droplet.PublicIPv6()
+		}
+		if err != nil || publicIP == "" {
+			if i > 0 || cfg.publicIPOptional {
+				// Only the primary IP family is mandatory, and only if
+				// public addresses are required.
+				continue
+			}
+			return nil, fmt.Errorf("could not get public %s ip: %v", family, err)
+		}
+		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeExternalIP, Address: publicIP})
 	}
-	addresses = append(addresses, v1.NodeAddress{Type: v1.NodeExternalIP, Address: publicIP})
 
 	return addresses, nil
 }
+
+// dropletIPv6 returns the first IPv6 address of droplet with the given network
+// type, or the empty string if there is none.
+func dropletIPv6(droplet *godo.Droplet, networkType string) string {
+	if droplet.Networks == nil {
+		return ""
+	}
+	for _, n := range droplet.Networks.V6 {
+		if n.Type == networkType {
+			return n.IPAddress
+		}
+	}
+	return ""
+}
+
+// dropletHasIP returns true if ip is one of droplet's IPv4 or IPv6 addresses.
+// IP addresses are compared in their parsed form so that differently
+// formatted representations of the same IPv6 address match.
+func dropletHasIP(droplet *godo.Droplet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || droplet.Networks == nil {
+		return false
+	}
+	for _, n := range droplet.Networks.V4 {
+		if parsed.Equal(net.ParseIP(n.IPAddress)) {
+			return true
+		}
+	}
+	for _, n := range droplet.Networks.V6 {
+		if parsed.Equal(net.ParseIP(n.IPAddress)) {
+			return true
+		}
+	}
+	return false
+}
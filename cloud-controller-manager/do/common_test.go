@@ -27,6 +27,7 @@ import (
 	"testing"
 
 	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
 )
 
 func stringP(s string) *string {
@@ -165,3 +166,201 @@ func TestAllLoadBalancerList(t *testing.T) {
 		t.Errorf("incorrect lbs\nwant: %#v\n got: %#v", want, got)
 	}
 }
+
+func newFakeDualStackDroplet() *godo.Droplet {
+	droplet := newFakeDroplet()
+	droplet.Networks.V6 = []godo.NetworkV6{
+		{
+			IPAddress: "2001:db8::1",
+			Type:      "public",
+		},
+	}
+	return droplet
+}
+
+func newFakeDualStackPrivateDroplet() *godo.Droplet {
+	droplet := newFakeDualStackDroplet()
+	droplet.Networks.V6 = append(droplet.Networks.V6, godo.NetworkV6{
+		IPAddress: "fd00::1",
+		Type:      "private",
+	})
+	return droplet
+}
+
+func newFakePrivateDroplet() *godo.Droplet {
+	droplet := newFakeDroplet()
+	droplet.Networks.V4 = []godo.NetworkV4{
+		{
+			IPAddress: "10.0.0.0",
+			Type:      "private",
+		},
+	}
+	return droplet
+}
+
+func TestNodeAddressesIPFamilies(t *testing.T) {
+	tests := []struct {
+		name             string
+		droplet          *godo.Droplet
+		ipFamilies       []v1.IPFamily
+		publicIPOptional bool
+		want             []v1.NodeAddress
+		wantErr          bool
+	}{
+		{
+			name:    "default config ignores IPv6",
+			droplet: newFakeDualStackDroplet(),
+			want: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "test-droplet"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.0"},
+				{Type: v1.NodeExternalIP, Address: "99.99.99.99"},
+			},
+		},
+		{
+			name:       "dual-stack preferring IPv4",
+			droplet:    newFakeDualStackDroplet(),
+			ipFamilies: []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol},
+			want: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "test-droplet"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.0"},
+				{Type: v1.NodeExternalIP, Address: "99.99.99.99"},
+				{Type: v1.NodeExternalIP, Address: "2001:db8::1"},
+			},
+		},
+		{
+			name:       "dual-stack preferring IPv6",
+			droplet:    newFakeDualStackDroplet(),
+			ipFamilies: []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol},
+			want: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "test-droplet"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.0"},
+				{Type: v1.NodeExternalIP, Address: "2001:db8::1"},
+				{Type: v1.NodeExternalIP, Address: "99.99.99.99"},
+			},
+		},
+		{
+			name:       "dual-stack with private IPv6 preferring IPv4",
+			droplet:    newFakeDualStackPrivateDroplet(),
+			ipFamilies: []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol},
+			want: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "test-droplet"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.0"},
+				{Type: v1.NodeInternalIP, Address: "fd00::1"},
+				{Type: v1.NodeExternalIP, Address: "99.99.99.99"},
+				{Type: v1.NodeExternalIP, Address: "2001:db8::1"},
+			},
+		},
+		{
+			name:       "dual-stack with private IPv6 preferring IPv6",
+			droplet:    newFakeDualStackPrivateDroplet(),
+			ipFamilies: []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol},
+			want: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "test-droplet"},
+				{Type: v1.NodeInternalIP, Address: "fd00::1"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.0"},
+				{Type: v1.NodeExternalIP, Address: "2001:db8::1"},
+				{Type: v1.NodeExternalIP, Address: "99.99.99.99"},
+			},
+		},
+		{
+			name:       "IPv6 only with private IPv6",
+			droplet:    newFakeDualStackPrivateDroplet(),
+			ipFamilies: []v1.IPFamily{v1.IPv6Protocol},
+			want: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "test-droplet"},
+				{Type: v1.NodeInternalIP, Address: "fd00::1"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.0"},
+				{Type: v1.NodeExternalIP, Address: "2001:db8::1"},
+			},
+		},
+		{
+			name:       "secondary IPv6 address missing",
+			droplet:    newFakeDroplet(),
+			ipFamilies: []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol},
+			want: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "test-droplet"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.0"},
+				{Type: v1.NodeExternalIP, Address: "99.99.99.99"},
+			},
+		},
+		{
+			name:       "primary IPv6 address missing",
+			droplet:    newFakeDroplet(),
+			ipFamilies: []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol},
+			wantErr:    true,
+		},
+		{
+			name:    "public IPv4 address missing",
+			droplet: newFakePrivateDroplet(),
+			wantErr: true,
+		},
+		{
+			name:             "public IPv4 address missing but optional",
+			droplet:          newFakePrivateDroplet(),
+			publicIPOptional: true,
+			want: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: "test-droplet"},
+				{Type: v1.NodeInternalIP, Address: "10.0.0.0"},
+			},
+		},
+		{
+			name:             "private IPv4 address missing",
+			droplet:          &godo.Droplet{Name: "test-droplet", Networks: &godo.Networks{}},
+			publicIPOptional: true,
+			wantErr:          true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := nodeAddressConfig{
+				ipFamilies:       test.ipFamilies,
+				publicIPOptional: test.publicIPOptional,
+			}
+			got, err := nodeAddresses(test.droplet, cfg)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got addresses %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseIPFamilies(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []v1.IPFamily
+		wantErr bool
+	}{
+		{
+			raw:  "ipv4",
+			want: []v1.IPFamily{v1.IPv4Protocol},
+		},
+		{
+			raw:  "IPv6, ipv4",
+			want: []v1.IPFamily{v1.IPv6Protocol, v1.IPv4Protocol},
+		},
+		{
+			raw:     "ipv4,ipv4",
+			wantErr: true,
+		},
+		{
+			raw:     "ipv5",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			got, err := parseIPFamilies(test.raw)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got IP families %v, want %v", got, test.want)
+			}
+		})
+	}
+}
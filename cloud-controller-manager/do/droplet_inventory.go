@@ -0,0 +1,306 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// dropletInventoryRefreshPeriod is the interval at which the droplet
+	// inventory is refreshed in the background. It is also the maximum age
+	// of the inventory before lookups refresh it on demand, and thus bounds
+	// how long a changed droplet status goes unnoticed.
+	dropletInventoryRefreshPeriod = 1 * time.Minute
+
+	// dropletInventoryMinScanInterval is the minimum time between two full
+	// droplet listings triggered by lookup misses.
+	dropletInventoryMinScanInterval = 10 * time.Second
+
+	dropletInventoryIndexID   = "id"
+	dropletInventoryIndexName = "name"
+
+	dropletInventoryResultHit  = "hit"
+	dropletInventoryResultMiss = "miss"
+)
+
+// dropletInventory is an in-memory inventory of droplets, indexed by ID, name
+// and IP address. If a tag is set, the inventory only lists droplets carrying
+// the tag and resolves misses through server-side filtering before resorting
+// to a full listing of all droplets.
+type dropletInventory struct {
+	client *godo.Client
+	tag    string
+	maxAge time.Duration
+
+	// refreshMu serializes listings so that concurrent lookups do not list
+	// the droplets more than once.
+	refreshMu sync.Mutex
+
+	mu           sync.RWMutex
+	byID         map[int]*godo.Droplet
+	byName       map[string]*godo.Droplet
+	byIP         map[string]*godo.Droplet
+	lastRefresh  time.Time
+	lastFullScan time.Time
+	stale        bool
+}
+
+func newDropletInventory(client *godo.Client, tag string, maxAge time.Duration) *dropletInventory {
+	return &dropletInventory{
+		client: client,
+		tag:    tag,
+		maxAge: maxAge,
+		byID:   map[int]*godo.Droplet{},
+		byName: map[string]*godo.Droplet{},
+		byIP:   map[string]*godo.Droplet{},
+		stale:  true,
+	}
+}
+
+// refresh lists the droplets and rebuilds the inventory.
+func (i *dropletInventory) refresh(ctx context.Context) error {
+	i.refreshMu.Lock()
+	defer i.refreshMu.Unlock()
+	return i.refreshLocked(ctx)
+}
+
+func (i *dropletInventory) refreshLocked(ctx context.Context) error {
+	var (
+		droplets []godo.Droplet
+		err      error
+	)
+	if i.tag != "" {
+		droplets, err = allDropletListByTag(ctx, i.client, i.tag)
+	} else {
+		droplets, err = allDropletList(ctx, i.client)
+	}
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int]*godo.Droplet, len(droplets))
+	byName := make(map[string]*godo.Droplet, len(droplets))
+	byIP := make(map[string]*godo.Droplet, len(droplets))
+	for idx := range droplets {
+		indexDroplet(&droplets[idx], byID, byName, byIP)
+	}
+
+	now := time.Now()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.byID = byID
+	i.byName = byName
+	i.byIP = byIP
+	i.lastRefresh = now
+	if i.tag == "" {
+		i.lastFullScan = now
+	}
+	i.stale = false
+
+	klog.V(5).Infof("Refreshed droplet inventory with %d droplet(s)", len(droplets))
+	return nil
+}
+
+// ensureFresh refreshes the inventory if it is stale or older than its
+// maximum age. Concurrent callers wait for a single refresh.
+func (i *dropletInventory) ensureFresh(ctx context.Context) error {
+	if !i.needsRefresh() {
+		return nil
+	}
+
+	i.refreshMu.Lock()
+	defer i.refreshMu.Unlock()
+	// Another caller may have refreshed the inventory while we were waiting.
+	if !i.needsRefresh() {
+		return nil
+	}
+	return i.refreshLocked(ctx)
+}
+
+func (i *dropletInventory) needsRefresh() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.stale || time.Since(i.lastRefresh) >= i.maxAge
+}
+
+// getByID returns the droplet identified by id. Droplets missing from the
+// inventory are retrieved from the API, which also yields the API's not found
+// error for droplets that do not exist.
+func (i *dropletInventory) getByID(ctx context.Context, id int) (*godo.Droplet, error) {
+	if err := i.ensureFresh(ctx); err != nil {
+		klog.Warningf("Failed to refresh droplet inventory, falling back to API lookup: %s", err)
+	}
+
+	i.mu.RLock()
+	droplet, ok := i.byID[id]
+	i.mu.RUnlock()
+	if ok {
+		dropletInventoryLookupsTotal.WithLabelValues(dropletInventoryIndexID, dropletInventoryResultHit).Inc()
+		return copyDroplet(droplet), nil
+	}
+	dropletInventoryLookupsTotal.WithLabelValues(dropletInventoryIndexID, dropletInventoryResultMiss).Inc()
+
+	droplet, err := dropletByID(ctx, i.client, id)
+	if err != nil {
+		return nil, err
+	}
+	i.put(droplet)
+	return droplet, nil
+}
+
+// getByName returns the droplet whose name or one of whose IP addresses is
+// name. nil is returned if no such droplet exists.
+func (i *dropletInventory) getByName(ctx context.Context, name string) (*godo.Droplet, error) {
+	if err := i.ensureFresh(ctx); err != nil {
+		return nil, err
+	}
+
+	if droplet := i.lookupName(name); droplet != nil {
+		dropletInventoryLookupsTotal.WithLabelValues(dropletInventoryIndexName, dropletInventoryResultHit).Inc()
+		return droplet, nil
+	}
+	dropletInventoryLookupsTotal.WithLabelValues(dropletInventoryIndexName, dropletInventoryResultMiss).Inc()
+
+	// Names that are not IP addresses can be resolved by the API directly.
+	if i.tag != "" && net.ParseIP(name) == nil {
+		droplets, err := allDropletListByName(ctx, i.client, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(droplets) == 0 {
+			return nil, nil
+		}
+		i.put(&droplets[0])
+		return &droplets[0], nil
+	}
+
+	if err := i.fullScan(ctx); err != nil {
+		return nil, err
+	}
+	return i.lookupName(name), nil
+}
+
+// fullScan adds all droplets of the account to the inventory unless they
+// were listed only recently.
+func (i *dropletInventory) fullScan(ctx context.Context) error {
+	i.refreshMu.Lock()
+	defer i.refreshMu.Unlock()
+
+	i.mu.RLock()
+	recent := time.Since(i.lastFullScan) < dropletInventoryMinScanInterval
+	i.mu.RUnlock()
+	if recent {
+		return nil
+	}
+
+	if i.tag == "" {
+		return i.refreshLocked(ctx)
+	}
+
+	droplets, err := allDropletList(ctx, i.client)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for idx := range droplets {
+		indexDroplet(&droplets[idx], i.byID, i.byName, i.byIP)
+	}
+	i.lastFullScan = time.Now()
+	return nil
+}
+
+func (i *dropletInventory) lookupName(name string) *godo.Droplet {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if droplet, ok := i.byName[name]; ok {
+		return copyDroplet(droplet)
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		if droplet, ok := i.byIP[ip.String()]; ok {
+			return copyDroplet(droplet)
+		}
+	}
+	return nil
+}
+
+// put adds or replaces droplet in the inventory.
+func (i *dropletInventory) put(droplet *godo.Droplet) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	indexDroplet(copyDroplet(droplet), i.byID, i.byName, i.byIP)
+}
+
+// remove deletes the droplet with the given ID from the inventory.
+func (i *dropletInventory) remove(id int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	droplet, ok := i.byID[id]
+	if !ok {
+		return
+	}
+	delete(i.byID, id)
+	if old, ok := i.byName[droplet.Name]; ok && old.ID == id {
+		delete(i.byName, droplet.Name)
+	}
+	for ip, old := range i.byIP {
+		if old.ID == id {
+			delete(i.byIP, ip)
+		}
+	}
+}
+
+// indexDroplet adds droplet to the given indexes. Droplets sharing a name or
+// IP address with another droplet do not replace the droplet indexed first.
+func indexDroplet(droplet *godo.Droplet, byID map[int]*godo.Droplet, byName, byIP map[string]*godo.Droplet) {
+	byID[droplet.ID] = droplet
+	if old, ok := byName[droplet.Name]; !ok || old.ID == droplet.ID {
+		byName[droplet.Name] = droplet
+	}
+	if droplet.Networks == nil {
+		return
+	}
+	var ips []string
+	for _, n := range droplet.Networks.V4 {
+		ips = append(ips, n.IPAddress)
+	}
+	for _, n := range droplet.Networks.V6 {
+		ips = append(ips, n.IPAddress)
+	}
+	for _, ipStr := range ips {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			continue
+		}
+		if old, ok := byIP[ip.String()]; !ok || old.ID == droplet.ID {
+			byIP[ip.String()] = droplet
+		}
+	}
+}
+
+func copyDroplet(droplet *godo.Droplet) *godo.Droplet {
+	dropletCopy := *droplet
+	return &dropletCopy
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+)
+
+type dropletAPICalls struct {
+	list, listByTag, listByName, get int
+}
+
+func newCountingDropletInventory(tag string, tagged, all []godo.Droplet) (*dropletInventory, *dropletAPICalls) {
+	calls := &dropletAPICalls{}
+	fake := &fakeDropletService{
+		listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+			calls.list++
+			return all, newFakeOKResponse(), nil
+		},
+		listByTagFunc: func(_ context.Context, gotTag string, _ *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+			calls.listByTag++
+			if gotTag != tag {
+				return nil, newFakeOKResponse(), nil
+			}
+			return tagged, newFakeOKResponse(), nil
+		},
+		listByNameFunc: func(_ context.Context, name string, _ *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+			calls.listByName++
+			var droplets []godo.Droplet
+			for _, d := range all {
+				if d.Name == name {
+					droplets = append(droplets, d)
+				}
+			}
+			return droplets, newFakeOKResponse(), nil
+		},
+		getFunc: func(_ context.Context, id int) (*godo.Droplet, *godo.Response, error) {
+			calls.get++
+			for _, d := range all {
+				if d.ID == id {
+					return &d, newFakeOKResponse(), nil
+				}
+			}
+			return nil, newFakeNotFoundResponse(), newFakeNotFoundErrorResponse()
+		},
+	}
+	return newDropletInventory(newFakeDropletClient(fake), tag, time.Hour), calls
+}
+
+func TestDropletInventory_taggedLookups(t *testing.T) {
+	tagged := newFakeDualStackDroplet()
+	untagged := godo.Droplet{ID: 456, Name: "untagged"}
+	unnamed := godo.Droplet{
+		ID:   789,
+		Name: "unnamed",
+		Networks: &godo.Networks{
+			V4: []godo.NetworkV4{{IPAddress: "10.0.0.99", Type: "private"}},
+		},
+	}
+	inv, calls := newCountingDropletInventory("k8s:cluster", []godo.Droplet{*tagged}, []godo.Droplet{*tagged, untagged, unnamed})
+	ctx := context.Background()
+
+	for _, name := range []string{tagged.Name, "10.0.0.0", "2001:db8:0::1"} {
+		d, err := inv.getByName(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if d == nil || d.ID != tagged.ID {
+			t.Fatalf("got droplet %v for %q, want ID %d", d, name, tagged.ID)
+		}
+	}
+	if _, err := inv.getByID(ctx, tagged.ID); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := (dropletAPICalls{listByTag: 1}); *calls != want {
+		t.Fatalf("got API calls %+v, want %+v", *calls, want)
+	}
+
+	// Name misses are resolved through server-side filtering, ID misses
+	// through a direct lookup.
+	d, err := inv.getByName(ctx, untagged.Name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d == nil || d.ID != untagged.ID {
+		t.Fatalf("got droplet %v, want ID %d", d, untagged.ID)
+	}
+	if _, err := inv.getByID(ctx, unnamed.ID); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := inv.getByID(ctx, 999); err == nil {
+		t.Fatal("expected error for missing droplet but got none")
+	}
+	if want := (dropletAPICalls{listByTag: 1, listByName: 1, get: 2}); *calls != want {
+		t.Fatalf("got API calls %+v, want %+v", *calls, want)
+	}
+
+	// IP address misses require a full listing.
+	d, err = inv.getByName(ctx, "10.0.0.99")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d == nil || d.ID != unnamed.ID {
+		t.Fatalf("got droplet %v, want ID %d", d, unnamed.ID)
+	}
+	d, err = inv.getByName(ctx, "10.0.0.100")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d != nil {
+		t.Fatalf("got droplet %v, want none", d)
+	}
+	if want := (dropletAPICalls{list: 1, listByTag: 1, listByName: 1, get: 2}); *calls != want {
+		t.Errorf("got API calls %+v, want %+v", *calls, want)
+	}
+}
+
+func TestDropletInventory_untaggedLookups(t *testing.T) {
+	droplet := newFakeDroplet()
+	inv, calls := newCountingDropletInventory("", nil, []godo.Droplet{*droplet})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		d, err := inv.getByName(ctx, droplet.Name)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if d == nil || d.ID != droplet.ID {
+			t.Fatalf("got droplet %v, want ID %d", d, droplet.ID)
+		}
+	}
+
+	// A miss right after a full listing must not list again.
+	d, err := inv.getByName(ctx, "missing")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d != nil {
+		t.Fatalf("got droplet %v, want none", d)
+	}
+	if want := (dropletAPICalls{list: 1}); *calls != want {
+		t.Errorf("got API calls %+v, want %+v", *calls, want)
+	}
+}
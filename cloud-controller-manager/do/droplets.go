@@ -32,6 +32,10 @@ import (
 
 const (
 	dropletShutdownStatus = "off"
+
+	// providerIDPrefix is the prefix of the provider ID stored in a node's
+	// spec.providerID field.
+	providerIDPrefix = ProviderName + "://"
 )
 
 type instances struct {
@@ -47,34 +51,35 @@ func newInstances(resources *resources, region string) cloudprovider.Instances {
 }
 
 // NodeAddresses returns all the valid addresses of the droplet identified by
-// nodeName. Only the public/private IPv4 addresses are considered for now.
+// nodeName. IPv6 addresses are only considered if enabled through the node
+// IP families configuration.
 //
 // When nodeName identifies more than one droplet, only the first will be
 // considered.
 func (i *instances) NodeAddresses(ctx context.Context, nodeName types.NodeName) ([]v1.NodeAddress, error) {
-	droplet, err := dropletByName(ctx, i.resources.gclient, nodeName)
+	droplet, err := i.resources.dropletByName(ctx, nodeName)
 	if err != nil {
 		return nil, err
 	}
 
-	return nodeAddresses(droplet)
+	return nodeAddresses(droplet, i.resources.nodeAddresses)
 }
 
 // NodeAddressesByProviderID returns all the valid addresses of the droplet
-// identified by providerID. Only the public/private IPv4 addresses will be
-// considered for now.
+// identified by providerID. IPv6 addresses are only considered if enabled
+// through the node IP families configuration.
 func (i *instances) NodeAddressesByProviderID(ctx context.Context, providerID string) ([]v1.NodeAddress, error) {
 	id, err := dropletIDFromProviderID(providerID)
 	if err != nil {
 		return nil, err
 	}
 
-	droplet, err := dropletByID(ctx, i.resources.gclient, id)
+	droplet, err := i.resources.dropletByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	return nodeAddresses(droplet)
+	return nodeAddresses(droplet, i.resources.nodeAddresses)
 }
 
 // ExternalID returns the cloud provider ID of the droplet identified by
@@ -89,7 +94,7 @@ func (i *instances) ExternalID(ctx context.Context, nodeName types.NodeName) (st
 
 // InstanceID returns the cloud provider ID of the droplet identified by nodeName.
 func (i *instances) InstanceID(ctx context.Context, nodeName types.NodeName) (string, error) {
-	droplet, err := dropletByName(ctx, i.resources.gclient, nodeName)
+	droplet, err := i.resources.dropletByName(ctx, nodeName)
 	if err != nil {
 		return "", err
 	}
@@ -98,7 +103,7 @@ func (i *instances) InstanceID(ctx context.Context, nodeName types.NodeName) (st
 
 // InstanceType returns the type of the droplet identified by name.
 func (i *instances) InstanceType(ctx context.Context, name types.NodeName) (string, error) {
-	droplet, err := dropletByName(ctx, i.resources.gclient, name)
+	droplet, err := i.resources.dropletByName(ctx, name)
 	if err != nil {
 		return "", err
 	}
@@ -113,7 +118,7 @@ func (i *instances) InstanceTypeByProviderID(ctx context.Context, providerID str
 		return "", err
 	}
 
-	droplet, err := dropletByID(ctx, i.resources.gclient, id)
+	droplet, err := i.resources.dropletByID(ctx, id)
 	if err != nil {
 		return "", err
 	}
@@ -142,7 +147,9 @@ func (i *instances) InstanceExistsByProviderID(ctx context.Context, providerID s
 		return false, err
 	}
 
-	_, err = dropletByID(ctx, i.resources.gclient, id)
+	// The droplet is looked up from the API rather than the droplet
+	// inventory since a stale inventory would get the node deleted.
+	_, err = i.resources.liveDropletByID(ctx, id)
 	if err == nil {
 		return true, nil
 	}
@@ -166,7 +173,7 @@ func (i *instances) InstanceShutdownByProviderID(ctx context.Context, providerID
 		return false, fmt.Errorf("error getting droplet ID from provider ID %q: %s", providerID, err)
 	}
 
-	droplet, err := dropletByID(ctx, i.resources.gclient, dropletID)
+	droplet, err := i.resources.liveDropletByID(ctx, dropletID)
 	if err != nil {
 		return false, fmt.Errorf("error getting droplet \"%d\" by ID: %s", dropletID, err)
 	}
@@ -185,22 +192,15 @@ func dropletByID(ctx context.Context, client *godo.Client, id int) (*godo.Drople
 // When nodeName identifies more than one droplet, only the first will be
 // considered.
 func dropletByName(ctx context.Context, client *godo.Client, nodeName types.NodeName) (*godo.Droplet, error) {
-	// TODO (andrewsykim): list by tag once a tagging format is determined
 	droplets, err := allDropletList(ctx, client)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, droplet := range droplets {
-		if droplet.Name == string(nodeName) {
+		if droplet.Name == string(nodeName) || dropletHasIP(&droplet, string(nodeName)) {
 			return &droplet, nil
 		}
-		addresses, _ := nodeAddresses(&droplet)
-		for _, address := range addresses {
-			if address.Address == string(nodeName) {
-				return &droplet, nil
-			}
-		}
 	}
 
 	return nil, cloudprovider.InstanceNotFound
@@ -215,13 +215,11 @@ func dropletIDFromProviderID(providerID string) (int, error) {
 		return 0, errors.New("provider ID cannot be empty")
 	}
 
-	const prefix = "digitalocean://"
-
-	if !strings.HasPrefix(providerID, prefix) {
-		return 0, fmt.Errorf("provider ID %q is missing prefix %q", providerID, prefix)
+	if !strings.HasPrefix(providerID, providerIDPrefix) {
+		return 0, fmt.Errorf("provider ID %q is missing prefix %q", providerID, providerIDPrefix)
 	}
 
-	provIDNum := strings.TrimPrefix(providerID, prefix)
+	provIDNum := strings.TrimPrefix(providerID, providerIDPrefix)
 	if provIDNum == "" {
 		return 0, errors.New("provider ID number cannot be empty")
 	}
@@ -233,3 +231,9 @@ func dropletIDFromProviderID(providerID string) (int, error) {
 
 	return dropletID, nil
 }
+
+// providerIDFromDropletID returns the provider ID for the droplet identified
+// by id.
+func providerIDFromDropletID(id int) string {
+	return providerIDPrefix + strconv.Itoa(id)
+}
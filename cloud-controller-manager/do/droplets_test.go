@@ -375,6 +375,11 @@ func TestDropletMatching(t *testing.T) {
 			nodeName: "99.99.99.99",
 			wantErr:  nil,
 		},
+		{
+			name:     "external IPv6 matches",
+			nodeName: "2001:db8:0:0::1",
+			wantErr:  nil,
+		},
 		{
 			name:     "no match",
 			nodeName: "1.2.3.4",
@@ -386,7 +391,7 @@ func TestDropletMatching(t *testing.T) {
 		t.Run(fmt.Sprintf(test.name), func(t *testing.T) {
 			fake := &fakeDropletService{}
 			fake.listFunc = func(ctx context.Context, opt *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
-				droplet := newFakeDroplet()
+				droplet := newFakeDualStackDroplet()
 				droplets := []godo.Droplet{*droplet}
 
 				resp := newFakeOKResponse()
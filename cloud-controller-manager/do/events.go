@@ -0,0 +1,113 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventComponent is the source component of the events we record.
+const eventComponent = "digitalocean-cloud-controller-manager"
+
+// Reasons of the events recorded on Services.
+const (
+	eventReasonLBCreated          = "LoadBalancerCreated"
+	eventReasonLBUpdated          = "LoadBalancerUpdated"
+	eventReasonLBDeleted          = "LoadBalancerDeleted"
+	eventReasonLBAdopted          = "LoadBalancerAdopted"
+	eventReasonLBProvisioning     = "LoadBalancerProvisioning"
+	eventReasonCertificateRotated = "CertificateRotated"
+	eventReasonInvalidAnnotation  = "InvalidAnnotation"
+	eventReasonAPIRejected        = "LoadBalancerRejected"
+	eventReasonLBDrift            = "LoadBalancerDrift"
+	eventReasonLBNotOwned         = "LoadBalancerNotOwned"
+	eventReasonLBRetained         = "LoadBalancerRetained"
+	eventReasonLBRenamed          = "LoadBalancerRenamed"
+	eventReasonLBReplacing        = "LoadBalancerReplacing"
+	eventReasonLBReplaced         = "LoadBalancerReplaced"
+	eventReasonLBRemediation      = "LoadBalancerRemediation"
+	eventReasonLBMissing          = "LoadBalancerMissing"
+	eventReasonLBSharingConflict  = "LoadBalancerSharingConflict"
+	eventReasonLBSharingLeft      = "LoadBalancerSharingGroupLeft"
+	eventReasonLBNoNodesSelected  = "LoadBalancerNoNodesSelected"
+	// eventReasonLBUpdateFailed matches the reason the service controller
+	// records for failed node updates, which batched updates replace.
+	eventReasonLBUpdateFailed = "UpdateLoadBalancerFailed"
+)
+
+// newEventRecorder returns an event recorder that records events through
+// client.
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponent})
+}
+
+// apiRejectionMessage returns the message of err if err is a client error
+// returned by the DO API, that is, the API rejected the request.
+func apiRejectionMessage(err error) (string, bool) {
+	godoErr, ok := err.(*godo.ErrorResponse)
+	if !ok || godoErr.Response == nil {
+		return "", false
+	}
+
+	// Missing resources and rate limiting are not rejections of the request
+	// itself.
+	code := godoErr.Response.StatusCode
+	if code < http.StatusBadRequest || code >= http.StatusInternalServerError || code == http.StatusNotFound || code == http.StatusTooManyRequests {
+		return "", false
+	}
+	return godoErr.Message, true
+}
+
+// recordEvent records an event on service if the load-balancers have an
+// event recorder.
+func (l *loadBalancers) recordEvent(service *v1.Service, eventType, reason, messageFmt string, args ...interface{}) {
+	if l.recorder == nil {
+		return
+	}
+	l.recorder.Eventf(service, eventType, reason, messageFmt, args...)
+}
+
+// recordBuildFailure records an event on service naming the invalid
+// annotation that caused err, if any. Other failures are surfaced by the
+// service controller already.
+func (l *loadBalancers) recordBuildFailure(service *v1.Service, err error) {
+	var annErr annotationError
+	if !errors.As(err, &annErr) {
+		return
+	}
+	l.recordEvent(service, v1.EventTypeWarning, eventReasonInvalidAnnotation, "Annotation %s is invalid: %s", annErr.name, annErr.err)
+}
+
+// recordAPIRejection records an event on service if err is a DO API
+// rejection of a load-balancer request.
+func (l *loadBalancers) recordAPIRejection(service *v1.Service, operation string, err error) {
+	msg, ok := apiRejectionMessage(err)
+	if !ok {
+		return
+	}
+	l.recordEvent(service, v1.EventTypeWarning, eventReasonAPIRejected, "DigitalOcean API rejected load-balancer %s: %s", operation, msg)
+}
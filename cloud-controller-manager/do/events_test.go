@@ -0,0 +1,331 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_recordBuildFailure(t *testing.T) {
+	sticky := map[string]string{
+		annDOStickySessionsType:       stickySessionsTypeCookies,
+		annDOStickySessionsCookieName: "session",
+		annDOStickySessionsCookieTTL:  "300",
+	}
+	healthCheck := map[string]string{
+		annDOOverrideHealthCheck: "",
+	}
+	global := map[string]string{
+		annDOType:       godo.LoadBalancerTypeGlobal,
+		annDOGLBDomains: "example.com",
+	}
+
+	tests := []struct {
+		annotation string
+		value      string
+		// others are set alongside the invalid annotation.
+		others map[string]string
+		// lbs are the existing load-balancers.
+		lbs []godo.LoadBalancer
+	}{
+		{annotation: annDOLoadBalancerName, value: "not a name!"},
+		{annotation: annDOProtocol, value: "smtp"},
+		{annotation: annDOHealthCheckPort, value: "eighty", others: healthCheck},
+		{annotation: annDOHealthCheckProtocol, value: "smtp", others: healthCheck},
+		{annotation: annDOHealthCheckIntervalSeconds, value: "often"},
+		{annotation: annDOHealthCheckResponseTimeoutSeconds, value: "soon"},
+		{annotation: annDOHealthCheckUnhealthyThreshold, value: "many"},
+		{annotation: annDOHealthCheckHealthyThreshold, value: "few"},
+		{annotation: annDOHTTPPorts, value: "eighty"},
+		{annotation: annDOTLSPorts, value: "eighty"},
+		{annotation: annDOHTTP2Ports, value: "eighty"},
+		{annotation: annDOHTTP3Port, value: "eighty"},
+		{annotation: annDOSizeSlug, value: "lb-huge"},
+		{annotation: annDOSizeUnit, value: "big"},
+		{annotation: annDOStickySessionsCookieName, value: "", others: sticky},
+		{annotation: annDOStickySessionsCookieTTL, value: "forever", others: sticky},
+		{annotation: annDORedirectHTTPToHTTPS, value: "maybe"},
+		{annotation: annDODisableLetsEncryptDNSRecords, value: "maybe"},
+		{annotation: annDOEnableProxyProtocol, value: "maybe"},
+		{annotation: annDOEnableBackendKeepalive, value: "maybe"},
+		{annotation: annDODisownLB, value: "maybe"},
+		{annotation: annDOAdoptionMode, value: "steal", others: map[string]string{annDOAdoptLB: "existing"}, lbs: []godo.LoadBalancer{{ID: "lb-1", Name: "existing"}}},
+		{annotation: annDOSharingGroup, value: "Not_A_Label"},
+		{annotation: annDOPortConfig, value: "{"},
+		{annotation: annDONodeSelector, value: " "},
+		{annotation: annDOBackendTag, value: "not a tag!"},
+		{annotation: annDOEndpointAwareBackends, value: "maybe"},
+		{annotation: annDOAppProtocols, value: "maybe"},
+		{annotation: annDOHttpIdleTimeoutSeconds, value: "long"},
+		{annotation: annDOType, value: "MAGIC"},
+		{annotation: annDONetwork, value: "MAGIC"},
+		{annotation: annDOGLBTargetProtocol, value: "smtp", others: global},
+		{annotation: annDOGLBTargetPort, value: "eighty", others: global},
+		{annotation: annDOGLBCDNEnabled, value: "maybe", others: global},
+		{annotation: annDOGLBRegionPriorities, value: "nyc1", others: global},
+		{annotation: annDOGLBFailoverThreshold, value: "100", others: global},
+		{annotation: annDOGLBDomainsManaged, value: "maybe", others: global},
+	}
+
+	for _, test := range tests {
+		t.Run(test.annotation, func(t *testing.T) {
+			annotations := map[string]string{}
+			for k, v := range test.others {
+				annotations[k] = v
+			}
+			annotations[test.annotation] = test.value
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   v1.NamespaceDefault,
+					UID:         "foobar123",
+					Annotations: annotations,
+				},
+				Spec: v1.ServiceSpec{
+					Type: v1.ServiceTypeLoadBalancer,
+					Ports: []v1.ServicePort{
+						{
+							Name:     "test",
+							Protocol: v1.ProtocolTCP,
+							Port:     80,
+							NodePort: 30000,
+						},
+					},
+				},
+			}
+
+			// Creating load-balancers is not faked and would panic.
+			fakeLB := &fakeLBService{
+				getFn: func(context.Context, string) (*godo.LoadBalancer, *godo.Response, error) {
+					return nil, newFakeNotFoundResponse(), newFakeNotFoundErrorResponse()
+				},
+				listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+					return test.lbs, newFakeOKResponse(), nil
+				},
+			}
+			fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(&fakeDropletService{}, fakeLB, nil))
+			fakeResources.kclient = fake.NewSimpleClientset(service)
+			recorder := record.NewFakeRecorder(10)
+			lbs := &loadBalancers{
+				resources:         fakeResources,
+				region:            "nyc3",
+				lbActiveTimeout:   2,
+				lbActiveCheckTick: 1,
+				recorder:          recorder,
+			}
+
+			if _, err := lbs.EnsureLoadBalancer(context.Background(), "test", service, nil); err == nil {
+				t.Fatal("expected error but got none")
+			}
+
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			want := "Warning InvalidAnnotation Annotation " + test.annotation + " is invalid"
+			if len(events) != 1 || !strings.HasPrefix(events[0], want) {
+				t.Errorf("got events %q, want prefix %q", events, want)
+			}
+		})
+	}
+}
+
+func Test_apiRejectionMessage(t *testing.T) {
+	newErrorResponse := func(code int) error {
+		return &godo.ErrorResponse{
+			Response: &http.Response{
+				Request:    &http.Request{Method: http.MethodPost},
+				StatusCode: code,
+			},
+			Message: "invalid forwarding rule",
+		}
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantReject bool
+	}{
+		{
+			name:       "bad request",
+			err:        newErrorResponse(http.StatusUnprocessableEntity),
+			wantReject: true,
+		},
+		{
+			name: "not found",
+			err:  newErrorResponse(http.StatusNotFound),
+		},
+		{
+			name: "rate limited",
+			err:  newErrorResponse(http.StatusTooManyRequests),
+		},
+		{
+			name: "server error",
+			err:  newErrorResponse(http.StatusInternalServerError),
+		},
+		{
+			name: "other error",
+			err:  errors.New("connection reset"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg, reject := apiRejectionMessage(test.err)
+			if reject != test.wantReject {
+				t.Fatalf("got rejection %t, want %t", reject, test.wantReject)
+			}
+			if reject && msg != "invalid forwarding rule" {
+				t.Errorf("got message %q, want API message", msg)
+			}
+		})
+	}
+}
+
+func Test_EnsureLoadBalancerEvents(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		createFn    func(context.Context, *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error)
+		wantErr     bool
+		wantEvents  []string
+	}{
+		{
+			name: "created",
+			createFn: func(_ context.Context, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+				return &godo.LoadBalancer{ID: "load-balancer-id", Name: lbr.Name, IP: "10.0.0.1", Status: lbStatusActive}, newFakeOKResponse(), nil
+			},
+			wantEvents: []string{"Normal LoadBalancerCreated Created load-balancer afoobar123 (load-balancer-id)"},
+		},
+		{
+			name: "provisioning",
+			createFn: func(_ context.Context, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+				return &godo.LoadBalancer{ID: "load-balancer-id", Name: lbr.Name, Status: lbStatusNew}, newFakeOKResponse(), nil
+			},
+			wantErr: true,
+			wantEvents: []string{
+				"Normal LoadBalancerCreated",
+				"Normal LoadBalancerProvisioning",
+			},
+		},
+		{
+			name: "rejected by API",
+			createFn: func(context.Context, *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+				resp := newFakeResponse(http.StatusUnprocessableEntity)
+				return nil, resp, &godo.ErrorResponse{Response: resp.Response, Message: "region has no capacity"}
+			},
+			wantErr:    true,
+			wantEvents: []string{"Warning LoadBalancerRejected DigitalOcean API rejected load-balancer creation: region has no capacity"},
+		},
+		{
+			name: "invalid annotation",
+			annotations: map[string]string{
+				annDOHealthCheckIntervalSeconds: "often",
+			},
+			wantErr:    true,
+			wantEvents: []string{"Warning InvalidAnnotation Annotation " + annDOHealthCheckIntervalSeconds + " is invalid"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   v1.NamespaceDefault,
+					UID:         "foobar123",
+					Annotations: test.annotations,
+				},
+				Spec: v1.ServiceSpec{
+					Type: v1.ServiceTypeLoadBalancer,
+					Ports: []v1.ServicePort{
+						{
+							Name:     "test",
+							Protocol: v1.ProtocolTCP,
+							Port:     80,
+							NodePort: 30000,
+						},
+					},
+				},
+			}
+			nodes := []*v1.Node{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+				},
+			}
+
+			fakeDroplet := &fakeDropletService{
+				listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+					return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+				},
+			}
+			fakeLB := &fakeLBService{
+				getFn: func(context.Context, string) (*godo.LoadBalancer, *godo.Response, error) {
+					return nil, newFakeNotFoundResponse(), newFakeNotFoundErrorResponse()
+				},
+				listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+					return nil, newFakeOKResponse(), nil
+				},
+				createFn: test.createFn,
+			}
+			fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(fakeDroplet, fakeLB, nil))
+			fakeResources.kclient = fake.NewSimpleClientset()
+			if _, err := fakeResources.kclient.CoreV1().Services(service.Namespace).Create(context.Background(), service, metav1.CreateOptions{}); err != nil {
+				t.Fatalf("failed to add service to fake client: %s", err)
+			}
+
+			recorder := record.NewFakeRecorder(10)
+			lbs := &loadBalancers{
+				resources:         fakeResources,
+				region:            "nyc3",
+				lbActiveTimeout:   2,
+				lbActiveCheckTick: 1,
+				recorder:          recorder,
+			}
+
+			_, err := lbs.EnsureLoadBalancer(context.Background(), "test", service, nodes)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			if len(events) != len(test.wantEvents) {
+				t.Fatalf("got events %q, want %d event(s)", events, len(test.wantEvents))
+			}
+			for i, want := range test.wantEvents {
+				if !strings.HasPrefix(events[i], want) {
+					t.Errorf("got event %q, want prefix %q", events[i], want)
+				}
+			}
+		})
+	}
+}
@@ -37,7 +37,8 @@ type fakeTagsService struct {
 	// given, a default error is returned. Ignored when failOnRequest is < 0.
 	failError error
 
-	tagRequests []*godo.TagResourcesRequest
+	tagRequests   []*godo.TagResourcesRequest
+	untagRequests []*godo.UntagResourcesRequest
 }
 
 func newFakeTagsService(tags ...string) *fakeTagsService {
@@ -118,5 +119,15 @@ func (f *fakeTagsService) TagResources(ctx context.Context, name string, tagRequ
 }
 
 func (f *fakeTagsService) UntagResources(ctx context.Context, name string, untagRequest *godo.UntagResourcesRequest) (*godo.Response, error) {
-	panic("not implemented")
+	if f.shouldFail() {
+		return nil, f.failError
+	}
+
+	if !f.tags[name] {
+		return newFakeResponse(http.StatusNotFound), fmt.Errorf("tag %q does not exist", name)
+	}
+
+	f.untagRequests = append(f.untagRequests, untagRequest)
+
+	return newFakeOKResponse(), nil
 }
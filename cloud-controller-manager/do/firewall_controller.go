@@ -96,12 +96,19 @@ type FirewallController struct {
 	workerFirewallTags []string
 	workerFirewallName string
 	serviceLister      corelisters.ServiceLister
+	nodeLister         corelisters.NodeLister
 	fwManager          *firewallManager
 	queue              workqueue.RateLimitingInterface
+
+	// nodeSelectorFirewallsListed is set once the node selector firewalls
+	// have been listed, and nodeSelectorFirewalls holds their number since.
+	nodeSelectorFirewallsListed bool
+	nodeSelectorFirewalls       int
 }
 
 // NewFirewallController returns a new firewall controller to reconcile public access firewall state.
-func NewFirewallController(kubeClient clientset.Interface, client *godo.Client, serviceInformer coreinformers.ServiceInformer, fwManager *firewallManager) *FirewallController {
+// Node selector firewalls are only managed if nodeInformer is given.
+func NewFirewallController(kubeClient clientset.Interface, client *godo.Client, serviceInformer coreinformers.ServiceInformer, nodeInformer coreinformers.NodeInformer, fwManager *firewallManager) *FirewallController {
 	fc := &FirewallController{
 		kubeClient: kubeClient,
 		client:     client,
@@ -125,6 +132,31 @@ func NewFirewallController(kubeClient clientset.Interface, client *godo.Client,
 	)
 	fc.serviceLister = serviceInformer.Lister()
 
+	if nodeInformer != nil {
+		nodeInformer.Informer().AddEventHandlerWithResyncPeriod(
+			cache.ResourceEventHandlerFuncs{
+				AddFunc: func(cur interface{}) {
+					fc.queue.Add(queueKey)
+				},
+				UpdateFunc: func(old, cur interface{}) {
+					oldNode, ok1 := old.(*v1.Node)
+					curNode, ok2 := cur.(*v1.Node)
+					// Only labels and provider IDs affect node selector
+					// firewalls.
+					if ok1 && ok2 && labels.Equals(oldNode.Labels, curNode.Labels) && oldNode.Spec.ProviderID == curNode.Spec.ProviderID {
+						return
+					}
+					fc.queue.Add(queueKey)
+				},
+				DeleteFunc: func(cur interface{}) {
+					fc.queue.Add(queueKey)
+				},
+			},
+			0,
+		)
+		fc.nodeLister = nodeInformer.Lister()
+	}
+
 	return fc
 }
 
@@ -327,27 +359,29 @@ func (fm *firewallManager) createReconciledFirewallRequest(serviceList []*v1.Ser
 			if err != nil {
 				return nil, fmt.Errorf("failed to get load balancer network for service %s/%s: %v", svc.Namespace, svc.Name, err)
 			}
-			if lbType == godo.LoadBalancerTypeRegionalNetwork && lbNetwork == godo.LoadBalancerNetworkTypeExternal {
-				// Add the health check port
-				_, healthCheckPort := healthCheckPathAndPort(svc)
-				if healthCheckPort == 0 {
+			switch {
+			case lbType == godo.LoadBalancerTypeGlobal:
+				// Global load balancers targeting droplets directly send
+				// traffic to the GLB target port on the worker nodes.
+				// Global load balancers forwarding to regional load
+				// balancers do not require any firewall rules.
+				if len(getGLBTargetLoadBalancerIDs(svc)) > 0 {
 					continue
 				}
-				loadBalancerPorts[portProtocol{protocol: "tcp", port: healthCheckPort}] = struct{}{}
-
-				// Add the services (port, protocol)
-				var protocol string
-				for _, servicePort := range svc.Spec.Ports {
-					switch servicePort.Protocol {
-					case v1.ProtocolTCP:
-						protocol = "tcp"
-					case v1.ProtocolUDP:
-						protocol = "udp"
-					default:
-						klog.Warningf("unsupported service protocol %v, skipping service port %v", servicePort.Protocol, servicePort.Name)
-						continue
-					}
-					loadBalancerPorts[portProtocol{protocol: protocol, port: int(servicePort.Port)}] = struct{}{}
+				targetPort, err := getGLBTargetPort(svc)
+				if err != nil {
+					klog.Warningf("failed to get GLB target port for service %s/%s, skipping: %s", svc.Namespace, svc.Name, err)
+					continue
+				}
+				loadBalancerPorts[portProtocol{protocol: "tcp", port: int(targetPort)}] = struct{}{}
+			case lbType == godo.LoadBalancerTypeRegionalNetwork && lbNetwork == godo.LoadBalancerNetworkTypeExternal:
+				// Ports of load balancers targeting selected nodes only
+				// are opened on those nodes by a dedicated firewall.
+				if selector, err := getNodeSelector(svc); err == nil && selector != nil {
+					continue
+				}
+				for _, p := range regionalNetworkPorts(svc) {
+					loadBalancerPorts[p] = struct{}{}
 				}
 			}
 		}
@@ -376,6 +410,33 @@ func (fm *firewallManager) createReconciledFirewallRequest(serviceList []*v1.Ser
 	}, nil
 }
 
+// regionalNetworkPorts returns the ports that external regional network load
+// balancers of svc send traffic to on the worker nodes.
+func regionalNetworkPorts(svc *v1.Service) []portProtocol {
+	// Add the health check port
+	_, healthCheckPort := healthCheckPathAndPort(svc)
+	if healthCheckPort == 0 {
+		return nil
+	}
+	ports := []portProtocol{{protocol: "tcp", port: healthCheckPort}}
+
+	// Add the services (port, protocol)
+	var protocol string
+	for _, servicePort := range svc.Spec.Ports {
+		switch servicePort.Protocol {
+		case v1.ProtocolTCP:
+			protocol = "tcp"
+		case v1.ProtocolUDP:
+			protocol = "udp"
+		default:
+			klog.Warningf("unsupported service protocol %v, skipping service port %v", servicePort.Protocol, servicePort.Name)
+			continue
+		}
+		ports = append(ports, portProtocol{protocol: protocol, port: int(servicePort.Port)})
+	}
+	return ports
+}
+
 // isManaged returns if the given Service should be firewall-managed based on the
 // configuration annotation. An omitted annotation applies the default behavior
 // of managing firewall rules for the Service.
@@ -453,6 +514,20 @@ func (fc *FirewallController) ensureReconciledFirewall(ctx context.Context) (ski
 	if err != nil {
 		return false, fmt.Errorf("failed to list services: %v", err)
 	}
+
+	skipped, err = fc.ensureReconciledWorkerFirewall(ctx, serviceList)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := fc.ensureNodeSelectorFirewalls(ctx, serviceList)
+	if err != nil {
+		return false, fmt.Errorf("failed to reconcile node selector firewalls: %v", err)
+	}
+	return skipped && !changed, nil
+}
+
+func (fc *FirewallController) ensureReconciledWorkerFirewall(ctx context.Context, serviceList []*v1.Service) (skipped bool, err error) {
 	fr, err := fc.fwManager.createReconciledFirewallRequest(serviceList)
 	if err != nil {
 		return false, fmt.Errorf("failed to create reconciled firewall request: %v", err)
@@ -31,6 +31,7 @@ type comparableFirewall struct {
 	InboundRules  []godo.InboundRule
 	OutboundRules []godo.OutboundRule
 	Tags          []string
+	DropletIDs    []int
 }
 
 func compFirewallFromFirewall(fw *godo.Firewall) *comparableFirewall {
@@ -43,6 +44,7 @@ func compFirewallFromFirewall(fw *godo.Firewall) *comparableFirewall {
 		InboundRules:  fw.InboundRules,
 		OutboundRules: fw.OutboundRules,
 		Tags:          fw.Tags,
+		DropletIDs:    fw.DropletIDs,
 	}
 }
 
@@ -56,6 +58,7 @@ func compFirewallFromFirewallRequest(fr *godo.FirewallRequest) *comparableFirewa
 		InboundRules:  fr.InboundRules,
 		OutboundRules: fr.OutboundRules,
 		Tags:          fr.Tags,
+		DropletIDs:    fr.DropletIDs,
 	}
 }
 
@@ -122,6 +125,10 @@ func compFirewallsEqual(cf1, cf2 *comparableFirewall) (bool, string) {
 		return false
 	}, cmp.Ignore())
 
-	diff := cmp.Diff(cf1, cf2, sorterInboundRules, sorterOutboundRules, portRangeMapper, ruleSourceDestFilter, cmpopts.EquateEmpty())
+	sorterDropletIDs := cmpopts.SortSlices(func(id1, id2 int) bool {
+		return id1 < id2
+	})
+
+	diff := cmp.Diff(cf1, cf2, sorterInboundRules, sorterOutboundRules, sorterDropletIDs, portRangeMapper, ruleSourceDestFilter, cmpopts.EquateEmpty())
 	return diff == "", diff
 }
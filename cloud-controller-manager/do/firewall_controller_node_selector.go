@@ -0,0 +1,221 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// nodeSelectorFirewallName returns the name of the firewall opening the ports
+// of load balancers with the given node selector on the selected nodes.
+func (fm *firewallManager) nodeSelectorFirewallName(selector string) string {
+	sum := sha256.Sum256([]byte(selector))
+	return fmt.Sprintf("%s%x", fm.nodeSelectorFirewallPrefix(), sum[:4])
+}
+
+func (fm *firewallManager) nodeSelectorFirewallPrefix() string {
+	return fm.workerFirewallName + "-nodes-"
+}
+
+// createNodeSelectorFirewallRequests returns the requests of the firewalls
+// opening the ports of external regional network load balancers with a node
+// selector on the selected nodes only, keyed by firewall name. The request is
+// nil for selections that match no droplets: their firewall is left as is,
+// just like the droplets of their load balancers.
+func (fm *firewallManager) createNodeSelectorFirewallRequests(serviceList []*v1.Service, nodes []*v1.Node) map[string]*godo.FirewallRequest {
+	selectors := map[string]labels.Selector{}
+	ports := map[string]map[portProtocol]struct{}{}
+	for _, svc := range serviceList {
+		if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+			continue
+		}
+		lbType, err := getType(svc)
+		if err != nil || lbType != godo.LoadBalancerTypeRegionalNetwork {
+			continue
+		}
+		lbNetwork, err := getNetwork(svc)
+		if err != nil || lbNetwork != godo.LoadBalancerNetworkTypeExternal {
+			continue
+		}
+		selector, err := getNodeSelector(svc)
+		if err != nil || selector == nil {
+			continue
+		}
+
+		key := selector.String()
+		selectors[key] = selector
+		if ports[key] == nil {
+			ports[key] = map[portProtocol]struct{}{}
+		}
+		for _, p := range regionalNetworkPorts(svc) {
+			ports[key][p] = struct{}{}
+		}
+	}
+
+	requests := map[string]*godo.FirewallRequest{}
+	for key, selector := range selectors {
+		name := fm.nodeSelectorFirewallName(key)
+
+		var dropletIDs []int
+		for _, node := range filterNodes(nodes, selector) {
+			if node.Spec.ProviderID == "" {
+				klog.Warningf("node %s lacks a provider ID and cannot be added to firewall %s", node.Name, name)
+				continue
+			}
+			dropletID, err := dropletIDFromProviderID(node.Spec.ProviderID)
+			if err != nil {
+				klog.Warningf("failed to parse provider ID of node %s: %s", node.Name, err)
+				continue
+			}
+			dropletIDs = append(dropletIDs, dropletID)
+		}
+		if len(dropletIDs) == 0 {
+			requests[name] = nil
+			continue
+		}
+		sort.Ints(dropletIDs)
+
+		var inboundRules []godo.InboundRule
+		for p := range ports[key] {
+			inboundRules = append(inboundRules, godo.InboundRule{
+				Protocol:  p.protocol,
+				PortRange: strconv.Itoa(p.port),
+				Sources: &godo.Sources{
+					Addresses: []string{"0.0.0.0/0", "::/0"},
+				},
+			})
+		}
+		sort.SliceStable(inboundRules, func(i, j int) bool {
+			if inboundRules[i].Protocol == inboundRules[j].Protocol {
+				return inboundRules[i].PortRange < inboundRules[j].PortRange
+			}
+			return inboundRules[i].Protocol < inboundRules[j].Protocol
+		})
+
+		requests[name] = &godo.FirewallRequest{
+			Name:         name,
+			InboundRules: inboundRules,
+			DropletIDs:   dropletIDs,
+		}
+	}
+
+	return requests
+}
+
+// listNodeSelectorFirewalls returns the node selector firewalls keyed by
+// name.
+func (fm *firewallManager) listNodeSelectorFirewalls(ctx context.Context) (map[string]godo.Firewall, error) {
+	firewalls := map[string]godo.Firewall{}
+	_, _, err := fm.executeInstrumentedFirewallOperation(ctx, firewallOperationGetByList, func(ctx context.Context) (*godo.Firewall, *godo.Response, error) {
+		return filterFirewallList(ctx, fm.client, func(fw godo.Firewall) bool {
+			if strings.HasPrefix(fw.Name, fm.nodeSelectorFirewallPrefix()) {
+				firewalls[fw.Name] = fw
+			}
+			return false
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return firewalls, nil
+}
+
+func (fm *firewallManager) deleteFirewall(ctx context.Context, fwID string) error {
+	_, resp, err := fm.executeInstrumentedFirewallOperation(ctx, firewallOperationDelete, func(ctx context.Context) (*godo.Firewall, *godo.Response, error) {
+		resp, err := fm.client.Firewalls.Delete(ctx, fwID)
+		return nil, resp, err
+	})
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return err
+	}
+	return nil
+}
+
+// ensureNodeSelectorFirewalls reconciles the node selector firewalls and
+// returns whether any of them changed. The API is only consulted while node
+// selector firewalls are desired or known to exist.
+func (fc *FirewallController) ensureNodeSelectorFirewalls(ctx context.Context, serviceList []*v1.Service) (bool, error) {
+	if fc.nodeLister == nil {
+		return false, nil
+	}
+	nodes, err := fc.nodeLister.List(labels.Everything())
+	if err != nil {
+		return false, fmt.Errorf("failed to list nodes: %v", err)
+	}
+
+	desired := fc.fwManager.createNodeSelectorFirewallRequests(serviceList, nodes)
+	if len(desired) == 0 && fc.nodeSelectorFirewallsListed && fc.nodeSelectorFirewalls == 0 {
+		return false, nil
+	}
+
+	current, err := fc.fwManager.listNodeSelectorFirewalls(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to list node selector firewalls: %v", err)
+	}
+	fc.nodeSelectorFirewallsListed = true
+	fc.nodeSelectorFirewalls = len(current)
+
+	var changed bool
+	for name, fr := range desired {
+		fw, exists := current[name]
+		if fr == nil {
+			klog.Warningf("no droplets match the node selector of firewall %s, leaving it as is", name)
+			continue
+		}
+		if exists {
+			if equal, _ := firewallRequestEqual(&fw, fr); equal {
+				continue
+			}
+			klog.Infof("updating node selector firewall %s: %s droplets: %v", name, printRelevantFirewallRequestParts(fr), fr.DropletIDs)
+			if _, _, err := fc.fwManager.updateFirewall(ctx, fw.ID, fr); err != nil {
+				return changed, fmt.Errorf("failed to update firewall %s: %v", name, err)
+			}
+		} else {
+			klog.Infof("creating node selector firewall %s: %s droplets: %v", name, printRelevantFirewallRequestParts(fr), fr.DropletIDs)
+			if _, err := fc.fwManager.createFirewall(ctx, fr); err != nil {
+				return changed, fmt.Errorf("failed to create firewall %s: %v", name, err)
+			}
+			fc.nodeSelectorFirewalls++
+		}
+		changed = true
+	}
+
+	for name, fw := range current {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		klog.Infof("deleting node selector firewall %s that is not needed anymore", name)
+		if err := fc.fwManager.deleteFirewall(ctx, fw.ID); err != nil {
+			return changed, fmt.Errorf("failed to delete firewall %s: %v", name, err)
+		}
+		fc.nodeSelectorFirewalls--
+		changed = true
+	}
+
+	return changed, nil
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newNodeSelectorFirewallService(name, selector string, port int32) *v1.Service {
+	annotations := map[string]string{
+		annDOType:    godo.LoadBalancerTypeRegionalNetwork,
+		annDONetwork: godo.LoadBalancerNetworkTypeExternal,
+	}
+	if selector != "" {
+		annotations[annDONodeSelector] = selector
+	}
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   v1.NamespaceDefault,
+			Annotations: annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{
+				{Name: "tcp", Protocol: v1.ProtocolTCP, Port: port, NodePort: port + 30000},
+			},
+		},
+	}
+}
+
+func newNodeLister(t *testing.T, nodes ...*v1.Node) corelisters.NodeLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range nodes {
+		if err := indexer.Add(node); err != nil {
+			t.Fatalf("failed to add node: %s", err)
+		}
+	}
+	return corelisters.NewNodeLister(indexer)
+}
+
+func newServiceLister(t *testing.T, services ...*v1.Service) corelisters.ServiceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, service := range services {
+		if err := indexer.Add(service); err != nil {
+			t.Fatalf("failed to add service: %s", err)
+		}
+	}
+	return corelisters.NewServiceLister(indexer)
+}
+
+func TestFirewallManager_createNodeSelectorFirewallRequests(t *testing.T) {
+	fm := newFakeFirewallManager(&godo.Client{}, newFakeFirewallCacheEmpty())
+	nodes := []*v1.Node{
+		newNodeSelectorTestNode("node-1", "digitalocean://101", map[string]string{"pool": "web"}),
+		newNodeSelectorTestNode("node-2", "digitalocean://102", map[string]string{"pool": "db"}),
+		newNodeSelectorTestNode("node-3", "digitalocean://100", map[string]string{"pool": "web"}),
+	}
+	services := []*v1.Service{
+		newNodeSelectorFirewallService("all", "", 80),
+		newNodeSelectorFirewallService("web-1", "pool=web", 8001),
+		newNodeSelectorFirewallService("web-2", "pool=web", 8002),
+		newNodeSelectorFirewallService("cache", "pool=cache", 8003),
+	}
+
+	got := fm.createNodeSelectorFirewallRequests(services, nodes)
+
+	webName := fm.nodeSelectorFirewallName("pool=web")
+	cacheName := fm.nodeSelectorFirewallName("pool=cache")
+	if len(got) != 2 {
+		t.Fatalf("got %d firewall requests, want 2", len(got))
+	}
+	if fr, ok := got[cacheName]; !ok || fr != nil {
+		t.Errorf("got request %v for selection without nodes, want nil", fr)
+	}
+
+	wantWeb := &godo.FirewallRequest{
+		Name: webName,
+		InboundRules: []godo.InboundRule{
+			{Protocol: "tcp", PortRange: "10256", Sources: &godo.Sources{Addresses: []string{"0.0.0.0/0", "::/0"}}},
+			{Protocol: "tcp", PortRange: "8001", Sources: &godo.Sources{Addresses: []string{"0.0.0.0/0", "::/0"}}},
+			{Protocol: "tcp", PortRange: "8002", Sources: &godo.Sources{Addresses: []string{"0.0.0.0/0", "::/0"}}},
+		},
+		DropletIDs: []int{100, 101},
+	}
+	if !reflect.DeepEqual(got[webName], wantWeb) {
+		t.Errorf("got firewall request %+v, want %+v", got[webName], wantWeb)
+	}
+	if !strings.HasPrefix(webName, testWorkerFWName+"-nodes-") {
+		t.Errorf("got firewall name %q, want prefix %q", webName, testWorkerFWName+"-nodes-")
+	}
+
+	// Ports of services with a node selector must not be opened on all nodes.
+	fr, err := fm.createReconciledFirewallRequest(services)
+	if err != nil {
+		t.Fatalf("failed to create reconciled firewall request: %s", err)
+	}
+	var gotPorts []string
+	for _, rule := range fr.InboundRules {
+		gotPorts = append(gotPorts, rule.PortRange)
+	}
+	for _, port := range []string{"8001", "8002", "8003"} {
+		for _, gotPort := range gotPorts {
+			if gotPort == port {
+				t.Errorf("got port %s of service with node selector in worker firewall", port)
+			}
+		}
+	}
+}
+
+func TestFirewallController_ensureNodeSelectorFirewalls(t *testing.T) {
+	nodes := []*v1.Node{
+		newNodeSelectorTestNode("node-1", "digitalocean://100", map[string]string{"pool": "web"}),
+	}
+	fm := newFakeFirewallManager(&godo.Client{}, newFakeFirewallCacheEmpty())
+	webName := fm.nodeSelectorFirewallName("pool=web")
+	staleName := fm.nodeSelectorFirewallName("pool=stale")
+
+	tests := []struct {
+		name        string
+		services    []*v1.Service
+		current     []godo.Firewall
+		wantCreated bool
+		wantUpdated bool
+		wantDeleted bool
+	}{
+		{
+			name:     "nothing to do",
+			services: []*v1.Service{newNodeSelectorFirewallService("all", "", 80)},
+		},
+		{
+			name:        "create firewall",
+			services:    []*v1.Service{newNodeSelectorFirewallService("web", "pool=web", 8001)},
+			wantCreated: true,
+		},
+		{
+			name:     "update firewall",
+			services: []*v1.Service{newNodeSelectorFirewallService("web", "pool=web", 8001)},
+			current: []godo.Firewall{
+				{ID: "web-id", Name: webName, DropletIDs: []int{100, 101}},
+			},
+			wantUpdated: true,
+		},
+		{
+			name:     "delete stale firewall",
+			services: []*v1.Service{newNodeSelectorFirewallService("all", "", 80)},
+			current: []godo.Firewall{
+				{ID: "stale-id", Name: staleName, DropletIDs: []int{100}},
+				{Name: testWorkerFWName},
+			},
+			wantDeleted: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotCreated, gotUpdated, gotDeleted bool
+			fake := createFakeFirewallService(fakeFirewallService{
+				listFunc: func(context.Context, *godo.ListOptions) ([]godo.Firewall, *godo.Response, error) {
+					return test.current, newFakeOKResponse(), nil
+				},
+				createFunc: func(_ context.Context, fr *godo.FirewallRequest) (*godo.Firewall, *godo.Response, error) {
+					gotCreated = true
+					return &godo.Firewall{ID: "web-id", Name: fr.Name}, newFakeOKResponse(), nil
+				},
+				updateFunc: func(_ context.Context, id string, fr *godo.FirewallRequest) (*godo.Firewall, *godo.Response, error) {
+					gotUpdated = true
+					return &godo.Firewall{ID: id, Name: fr.Name}, newFakeOKResponse(), nil
+				},
+				deleteFunc: func(_ context.Context, id string) (*godo.Response, error) {
+					if id != "stale-id" {
+						t.Errorf("got deletion of firewall %q, want %q", id, "stale-id")
+					}
+					gotDeleted = true
+					return newFakeOKResponse(), nil
+				},
+			})
+			fc := &FirewallController{
+				fwManager:  newFakeFirewallManager(newFakeGodoClient(fake), newFakeFirewallCacheEmpty()),
+				nodeLister: newNodeLister(t, nodes...),
+			}
+
+			changed, err := fc.ensureNodeSelectorFirewalls(context.Background(), test.services)
+			if err != nil {
+				t.Fatalf("got error %s", err)
+			}
+			if gotCreated != test.wantCreated {
+				t.Errorf("got created %t, want %t", gotCreated, test.wantCreated)
+			}
+			if gotUpdated != test.wantUpdated {
+				t.Errorf("got updated %t, want %t", gotUpdated, test.wantUpdated)
+			}
+			if gotDeleted != test.wantDeleted {
+				t.Errorf("got deleted %t, want %t", gotDeleted, test.wantDeleted)
+			}
+			wantChanged := test.wantCreated || test.wantUpdated || test.wantDeleted
+			if changed != wantChanged {
+				t.Errorf("got changed %t, want %t", changed, wantChanged)
+			}
+		})
+	}
+}
@@ -194,7 +194,7 @@ func TestFirewallController_Set(t *testing.T) {
 			gclient := newFakeGodoClient(fake)
 
 			fwManager := newFakeFirewallManager(gclient, newFakeFirewallCache())
-			fc := NewFirewallController(kclient, gclient, inf.Core().V1().Services(), fwManager)
+			fc := NewFirewallController(kclient, gclient, inf.Core().V1().Services(), nil, fwManager)
 
 			firewallRequest := &godo.FirewallRequest{
 				Name:          testWorkerFWName,
@@ -242,7 +242,7 @@ func TestFirewallController_ConcurrentUsage(t *testing.T) {
 		}),
 	)
 	fwManager := newFakeFirewallManager(gclient, newFakeFirewallCacheEmpty())
-	fc := NewFirewallController(kclient, gclient, inf.Core().V1().Services(), fwManager)
+	fc := NewFirewallController(kclient, gclient, inf.Core().V1().Services(), nil, fwManager)
 
 	doneCtx, doneCancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer doneCancel()
@@ -639,6 +639,64 @@ func TestFirewallController_createReconciledFirewallRequest(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "reconcile firewall with global lb targeting droplets",
+			firewallRequest: &godo.FirewallRequest{
+				Name: testWorkerFWName,
+				InboundRules: []godo.InboundRule{
+					{
+						Protocol:  "tcp",
+						PortRange: "30080",
+						Sources: &godo.Sources{
+							Addresses: []string{"0.0.0.0/0", "::/0"},
+						},
+					},
+				},
+				OutboundRules: testOutboundRules,
+				Tags:          testWorkerFWTags,
+			},
+			serviceList: []*v1.Service{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "global_droplets",
+						UID:  "abc123",
+						Annotations: map[string]string{
+							annDOType: godo.LoadBalancerTypeGlobal,
+						},
+					},
+					Spec: v1.ServiceSpec{
+						Type: v1.ServiceTypeLoadBalancer,
+						Ports: []v1.ServicePort{
+							{
+								Protocol: v1.ProtocolTCP,
+								Port:     80,
+								NodePort: 30080,
+							},
+						},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "global_lbs",
+						UID:  "def456",
+						Annotations: map[string]string{
+							annDOType:                     godo.LoadBalancerTypeGlobal,
+							annDOGLBTargetLoadBalancerIDs: "lb-1,lb-2",
+						},
+					},
+					Spec: v1.ServiceSpec{
+						Type: v1.ServiceTypeLoadBalancer,
+						Ports: []v1.ServicePort{
+							{
+								Protocol: v1.ProtocolTCP,
+								Port:     80,
+								NodePort: 30090,
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "reconcile firewall with management flag",
 			firewallRequest: &godo.FirewallRequest{
@@ -910,7 +968,7 @@ func TestFirewallController_ensureReconciledFirewall(t *testing.T) {
 			}
 
 			// Run the test.
-			fc := NewFirewallController(kclient, gclient, svcInformer, fwManager)
+			fc := NewFirewallController(kclient, gclient, svcInformer, nil, fwManager)
 
 			gotSkipped, err := fc.ensureReconciledFirewall(ctx)
 			if err != nil {
@@ -194,6 +194,9 @@ func createFakeFirewallService(override fakeFirewallService) *fakeFirewallServic
 		updateFunc: func(context.Context, string, *godo.FirewallRequest) (*godo.Firewall, *godo.Response, error) {
 			return nil, newFakeNotOKResponse(), errors.New("update should not have been invoked")
 		},
+		deleteFunc: func(context.Context, string) (*godo.Response, error) {
+			return newFakeNotOKResponse(), errors.New("delete should not have been invoked")
+		},
 	}
 	if override.getFunc != nil {
 		fake.getFunc = override.getFunc
@@ -207,6 +210,9 @@ func createFakeFirewallService(override fakeFirewallService) *fakeFirewallServic
 	if override.updateFunc != nil {
 		fake.updateFunc = override.updateFunc
 	}
+	if override.deleteFunc != nil {
+		fake.deleteFunc = override.deleteFunc
+	}
 	return fake
 }
 
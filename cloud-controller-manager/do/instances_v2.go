@@ -0,0 +1,151 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+const (
+	// labelDropletID is the node label specifying the ID of the droplet
+	// backing the node.
+	labelDropletID = "kubernetes.digitalocean.com/droplet-id"
+
+	// labelVPCID is the node label specifying the ID of the VPC the droplet
+	// backing the node is attached to.
+	labelVPCID = "kubernetes.digitalocean.com/vpc-id"
+)
+
+type instancesV2 struct {
+	region    string
+	resources *resources
+}
+
+func newInstancesV2(resources *resources, region string) cloudprovider.InstancesV2 {
+	return &instancesV2{
+		resources: resources,
+		region:    region,
+	}
+}
+
+// InstanceExists returns true if the droplet backing node exists.
+func (i *instancesV2) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
+	// NOTE: when false is returned with no error, the node will be
+	// immediately deleted by the cloud controller manager. The droplet is
+	// therefore looked up from the API rather than the droplet inventory.
+
+	_, err := i.liveDropletByNode(ctx, node)
+	if err == nil {
+		return true, nil
+	}
+
+	if err == cloudprovider.InstanceNotFound {
+		return false, nil
+	}
+
+	godoErr, ok := err.(*godo.ErrorResponse)
+	if !ok {
+		return false, fmt.Errorf("unexpected error type %T from godo: %s", err, err)
+	}
+
+	if godoErr.Response.StatusCode != http.StatusNotFound {
+		return false, fmt.Errorf("error checking if instance exists: %s", err)
+	}
+
+	return false, nil
+}
+
+// InstanceShutdown returns true if the droplet backing node is turned off.
+func (i *instancesV2) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
+	droplet, err := i.liveDropletByNode(ctx, node)
+	if err != nil {
+		return false, fmt.Errorf("error getting droplet for node %q: %s", node.Name, err)
+	}
+
+	return droplet.Status == dropletShutdownStatus, nil
+}
+
+// InstanceMetadata returns the metadata of the droplet backing node.
+func (i *instancesV2) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
+	droplet, err := i.dropletByNode(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := nodeAddresses(droplet, i.resources.nodeAddresses)
+	if err != nil {
+		return nil, err
+	}
+
+	region := i.region
+	if droplet.Region != nil && droplet.Region.Slug != "" {
+		region = droplet.Region.Slug
+	}
+
+	labels := map[string]string{
+		labelDropletID: strconv.Itoa(droplet.ID),
+	}
+	if droplet.VPCUUID != "" {
+		labels[labelVPCID] = droplet.VPCUUID
+	}
+
+	return &cloudprovider.InstanceMetadata{
+		ProviderID:       providerIDFromDropletID(droplet.ID),
+		InstanceType:     droplet.SizeSlug,
+		NodeAddresses:    addresses,
+		Region:           region,
+		AdditionalLabels: labels,
+	}, nil
+}
+
+// dropletByNode returns the droplet backing node. The droplet is looked up by
+// the node's provider ID if set, and by the node's name otherwise.
+func (i *instancesV2) dropletByNode(ctx context.Context, node *v1.Node) (*godo.Droplet, error) {
+	if node.Spec.ProviderID != "" {
+		id, err := dropletIDFromProviderID(node.Spec.ProviderID)
+		if err != nil {
+			return nil, err
+		}
+
+		return i.resources.dropletByID(ctx, id)
+	}
+
+	return i.resources.dropletByName(ctx, types.NodeName(node.Name))
+}
+
+// liveDropletByNode is like dropletByNode but bypasses the droplet inventory
+// so that the current state of the droplet is returned.
+func (i *instancesV2) liveDropletByNode(ctx context.Context, node *v1.Node) (*godo.Droplet, error) {
+	if node.Spec.ProviderID != "" {
+		id, err := dropletIDFromProviderID(node.Spec.ProviderID)
+		if err != nil {
+			return nil, err
+		}
+
+		return i.resources.liveDropletByID(ctx, id)
+	}
+
+	return i.resources.liveDropletByName(ctx, types.NodeName(node.Name))
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+var _ cloudprovider.InstancesV2 = new(instancesV2)
+
+func TestInstanceExists(t *testing.T) {
+	tests := []struct {
+		name       string
+		node       *v1.Node
+		getErr     error
+		wantExists bool
+		wantErr    bool
+	}{
+		{
+			name: "found by provider ID",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "unknown"},
+				Spec:       v1.NodeSpec{ProviderID: "digitalocean://123"},
+			},
+			wantExists: true,
+		},
+		{
+			name: "not found by provider ID",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-droplet"},
+				Spec:       v1.NodeSpec{ProviderID: "digitalocean://123"},
+			},
+			getErr:     newFakeNotFoundErrorResponse(),
+			wantExists: false,
+		},
+		{
+			name: "unexpected error by provider ID",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-droplet"},
+				Spec:       v1.NodeSpec{ProviderID: "digitalocean://123"},
+			},
+			getErr:  errors.New("API unavailable"),
+			wantErr: true,
+		},
+		{
+			name: "found by name",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-droplet"},
+			},
+			wantExists: true,
+		},
+		{
+			name: "not found by name",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-droplet"},
+			},
+			wantExists: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeDropletService{}
+			fake.getFunc = func(ctx context.Context, dropletID int) (*godo.Droplet, *godo.Response, error) {
+				if test.getErr != nil {
+					return nil, newFakeNotFoundResponse(), test.getErr
+				}
+				return newFakeDroplet(), newFakeOKResponse(), nil
+			}
+			fake.listFunc = func(ctx context.Context, opt *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+				return []godo.Droplet{*newFakeDroplet()}, newFakeOKResponse(), nil
+			}
+
+			res := &resources{gclient: newFakeDropletClient(fake)}
+			instances := newInstancesV2(res, "nyc1")
+
+			exists, err := instances.InstanceExists(context.Background(), test.node)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if exists != test.wantExists {
+				t.Errorf("got exists %t, want %t", exists, test.wantExists)
+			}
+		})
+	}
+}
+
+func TestInstanceShutdown(t *testing.T) {
+	fake := &fakeDropletService{}
+	fake.getFunc = func(ctx context.Context, dropletID int) (*godo.Droplet, *godo.Response, error) {
+		return newFakeShutdownDroplet(), newFakeOKResponse(), nil
+	}
+
+	res := &resources{gclient: newFakeDropletClient(fake)}
+	instances := newInstancesV2(res, "nyc1")
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-droplet"},
+		Spec:       v1.NodeSpec{ProviderID: "digitalocean://123"},
+	}
+	shutdown, err := instances.InstanceShutdown(context.Background(), node)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !shutdown {
+		t.Errorf("expected node to be shutdown, but it wasn't")
+	}
+}
+
+func TestInstancesV2_bypassInventory(t *testing.T) {
+	droplets := []godo.Droplet{{ID: 123, Name: "test-droplet", Status: "active"}}
+	inv, calls := newCountingDropletInventory("", nil, droplets)
+	res := &resources{gclient: inv.client, dropletInventory: inv}
+	instances := newInstancesV2(res, "nyc1")
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-droplet"},
+		Spec:       v1.NodeSpec{ProviderID: "digitalocean://123"},
+	}
+	ctx := context.Background()
+	if err := inv.refresh(ctx); err != nil {
+		t.Fatalf("failed to refresh droplet inventory: %s", err)
+	}
+
+	// The droplet is powered off after the inventory was refreshed.
+	droplets[0].Status = dropletShutdownStatus
+	shutdown, err := instances.InstanceShutdown(ctx, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !shutdown {
+		t.Error("got running droplet from the inventory, want shutdown droplet")
+	}
+
+	// The droplet is deleted after the inventory was refreshed.
+	droplets[0].ID = 456
+	exists, err := instances.InstanceExists(ctx, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if exists {
+		t.Error("got existing droplet from the inventory, want none")
+	}
+	if calls.get != 2 {
+		t.Errorf("got %d get call(s), want 2", calls.get)
+	}
+
+	// Other lookups keep using the inventory, which forgot the droplet.
+	if _, err := res.dropletByID(ctx, 123); err == nil {
+		t.Error("got deleted droplet from the inventory")
+	}
+}
+
+func TestInstanceMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		node *v1.Node
+	}{
+		{
+			name: "by provider ID",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-droplet"},
+				Spec:       v1.NodeSpec{ProviderID: "digitalocean://123"},
+			},
+		},
+		{
+			name: "by name",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-droplet"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			droplet := newFakeDroplet()
+			droplet.VPCUUID = "vpc-uuid"
+
+			fake := &fakeDropletService{}
+			fake.getFunc = func(ctx context.Context, dropletID int) (*godo.Droplet, *godo.Response, error) {
+				return droplet, newFakeOKResponse(), nil
+			}
+			fake.listFunc = func(ctx context.Context, opt *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+				return []godo.Droplet{*droplet}, newFakeOKResponse(), nil
+			}
+
+			res := &resources{gclient: newFakeDropletClient(fake)}
+			instances := newInstancesV2(res, "nyc1")
+
+			want := &cloudprovider.InstanceMetadata{
+				ProviderID:   "digitalocean://123",
+				InstanceType: "2gb",
+				NodeAddresses: []v1.NodeAddress{
+					{
+						Type:    v1.NodeHostName,
+						Address: "test-droplet",
+					},
+					{
+						Type:    v1.NodeInternalIP,
+						Address: "10.0.0.0",
+					},
+					{
+						Type:    v1.NodeExternalIP,
+						Address: "99.99.99.99",
+					},
+				},
+				Region: "test1",
+				AdditionalLabels: map[string]string{
+					labelDropletID: "123",
+					labelVPCID:     "vpc-uuid",
+				},
+			}
+
+			got, err := instances.InstanceMetadata(context.Background(), test.node)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got metadata %+v, want %+v", got, want)
+			}
+		})
+	}
+}
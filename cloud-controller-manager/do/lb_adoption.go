@@ -0,0 +1,126 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"slices"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+type lbAdoptionMode string
+
+const (
+	// lbAdoptionModeObserve only reports the status of an adopted
+	// load-balancer and never mutates it.
+	lbAdoptionModeObserve lbAdoptionMode = "observe"
+	// lbAdoptionModeMerge manages an adopted load-balancer but keeps
+	// forwarding rules and tags that are not rendered from the Service.
+	lbAdoptionModeMerge lbAdoptionMode = "merge"
+	// lbAdoptionModeTakeover fully converges an adopted load-balancer to the
+	// configuration rendered from the Service.
+	lbAdoptionModeTakeover lbAdoptionMode = "takeover"
+)
+
+// getAdoptionMode returns the adoption mode of service, or an empty mode if
+// service does not adopt a load-balancer. It defaults to takeover.
+func getAdoptionMode(service *v1.Service) (lbAdoptionMode, error) {
+	if service.Annotations[annDOAdoptLB] == "" {
+		return "", nil
+	}
+
+	mode, ok := service.Annotations[annDOAdoptionMode]
+	if !ok {
+		return lbAdoptionModeTakeover, nil
+	}
+
+	switch m := lbAdoptionMode(mode); m {
+	case lbAdoptionModeObserve, lbAdoptionModeMerge, lbAdoptionModeTakeover:
+		return m, nil
+	default:
+		return "", invalidAnnotation(annDOAdoptionMode, "invalid adoption mode %q specified in annotation %q, options are %q, %q, and %q", mode, annDOAdoptionMode, lbAdoptionModeObserve, lbAdoptionModeMerge, lbAdoptionModeTakeover)
+	}
+}
+
+// observesLoadBalancer returns whether service only observes the
+// load-balancer it adopts.
+func observesLoadBalancer(service *v1.Service) bool {
+	mode, _ := getAdoptionMode(service)
+	return mode == lbAdoptionModeObserve
+}
+
+// retrieveAdoptedLoadBalancer returns the load-balancer that service adopts
+// by name or ID.
+func (l *loadBalancers) retrieveAdoptedLoadBalancer(ctx context.Context, service *v1.Service) (*godo.LoadBalancer, error) {
+	adopt := service.Annotations[annDOAdoptLB]
+
+	lb, err := l.resources.loadBalancerByName(ctx, adopt)
+	if err != nil {
+		return nil, err
+	}
+	if lb != nil {
+		return lb, nil
+	}
+
+	return l.findLoadBalancerByID(ctx, adopt)
+}
+
+// adoptLoadBalancer records the adoption of lb by service and tags lb with
+// the cluster ID unless it is only observed.
+func (l *loadBalancers) adoptLoadBalancer(ctx context.Context, service *v1.Service, lb *godo.LoadBalancer, mode lbAdoptionMode) {
+	klog.Infof("Adopting load-balancer %s (%s) for service %s/%s in mode %s", lb.Name, lb.ID, service.Namespace, service.Name, mode)
+	l.recordEvent(service, v1.EventTypeNormal, eventReasonLBAdopted, "Adopted load-balancer %s (%s) in mode %s", lb.Name, lb.ID, mode)
+
+	if mode == lbAdoptionModeObserve || l.resources.clusterID == "" || slices.Contains(lb.Tags, buildK8sTag(l.resources.clusterID)) {
+		return
+	}
+
+	// The tags syncer retries failed tagging.
+	err := l.resources.tagWithClusterID(ctx, []godo.Resource{
+		{
+			ID:   lb.ID,
+			Type: godo.LoadBalancerResourceType,
+		},
+	})
+	if err != nil {
+		klog.Warningf("Failed to tag adopted load-balancer %s: %s", lb.ID, err)
+	}
+}
+
+// mergeUnmanagedFields adds the forwarding rules and tags of lb that are not
+// rendered from the Service to lbRequest. Forwarding rules are identified by
+// their entry port, which must be unique.
+func mergeUnmanagedFields(lb *godo.LoadBalancer, lbRequest *godo.LoadBalancerRequest) {
+	managedPorts := map[int]bool{}
+	for _, rule := range lbRequest.ForwardingRules {
+		managedPorts[rule.EntryPort] = true
+	}
+	for _, rule := range lb.ForwardingRules {
+		if !managedPorts[rule.EntryPort] {
+			lbRequest.ForwardingRules = append(lbRequest.ForwardingRules, rule)
+		}
+	}
+
+	for _, tag := range lb.Tags {
+		if !slices.Contains(lbRequest.Tags, tag) {
+			lbRequest.Tags = append(lbRequest.Tags, tag)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_getAdoptionMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        lbAdoptionMode
+		wantErr     bool
+	}{
+		{
+			name: "no adoption",
+		},
+		{
+			name: "mode without adoption",
+			annotations: map[string]string{
+				annDOAdoptionMode: "observe",
+			},
+		},
+		{
+			name: "default mode",
+			annotations: map[string]string{
+				annDOAdoptLB: "hand-made",
+			},
+			want: lbAdoptionModeTakeover,
+		},
+		{
+			name: "merge",
+			annotations: map[string]string{
+				annDOAdoptLB:      "hand-made",
+				annDOAdoptionMode: "merge",
+			},
+			want: lbAdoptionModeMerge,
+		},
+		{
+			name: "invalid mode",
+			annotations: map[string]string{
+				annDOAdoptLB:      "hand-made",
+				annDOAdoptionMode: "steal",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: test.annotations,
+				},
+			}
+
+			got, err := getAdoptionMode(service)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got mode %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_mergeUnmanagedFields(t *testing.T) {
+	lb := &godo.LoadBalancer{
+		ForwardingRules: []godo.ForwardingRule{
+			{EntryProtocol: "http", EntryPort: 80, TargetProtocol: "http", TargetPort: 8080},
+			{EntryProtocol: "tcp", EntryPort: 2222, TargetProtocol: "tcp", TargetPort: 22},
+		},
+		Tags: []string{"terraform", clusterIDTag},
+	}
+	req := &godo.LoadBalancerRequest{
+		ForwardingRules: []godo.ForwardingRule{
+			{EntryProtocol: "https", EntryPort: 80, TargetProtocol: "http", TargetPort: 30000},
+		},
+		Tags: []string{clusterIDTag},
+	}
+
+	mergeUnmanagedFields(lb, req)
+
+	wantRules := []godo.ForwardingRule{
+		{EntryProtocol: "https", EntryPort: 80, TargetProtocol: "http", TargetPort: 30000},
+		{EntryProtocol: "tcp", EntryPort: 2222, TargetProtocol: "tcp", TargetPort: 22},
+	}
+	if !reflect.DeepEqual(req.ForwardingRules, wantRules) {
+		t.Errorf("got forwarding rules %v, want %v", req.ForwardingRules, wantRules)
+	}
+	wantTags := []string{clusterIDTag, "terraform"}
+	if !reflect.DeepEqual(req.Tags, wantTags) {
+		t.Errorf("got tags %v, want %v", req.Tags, wantTags)
+	}
+}
+
+func TestEnsureLoadBalancer_adoption(t *testing.T) {
+	extraRule := godo.ForwardingRule{EntryProtocol: "tcp", EntryPort: 2222, TargetProtocol: "tcp", TargetPort: 22}
+
+	tests := []struct {
+		name           string
+		adopt          string
+		mode           string
+		wantErr        bool
+		wantUpdate     bool
+		wantExtraRule  bool
+		wantTagRequest bool
+	}{
+		{
+			name:    "load-balancer to adopt missing",
+			adopt:   "missing",
+			wantErr: true,
+		},
+		{
+			name:  "observe",
+			adopt: "hand-made",
+			mode:  "observe",
+		},
+		{
+			name:           "merge",
+			adopt:          "hand-made",
+			mode:           "merge",
+			wantUpdate:     true,
+			wantExtraRule:  true,
+			wantTagRequest: true,
+		},
+		{
+			name:           "takeover by ID",
+			adopt:          "hand-made-id",
+			wantUpdate:     true,
+			wantTagRequest: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: v1.NamespaceDefault,
+					UID:       "foobar123",
+					Annotations: map[string]string{
+						annDOAdoptLB: test.adopt,
+					},
+				},
+				Spec: v1.ServiceSpec{
+					Type: v1.ServiceTypeLoadBalancer,
+					Ports: []v1.ServicePort{
+						{
+							Name:     "test",
+							Protocol: v1.ProtocolTCP,
+							Port:     80,
+							NodePort: 30000,
+						},
+					},
+				},
+			}
+			if test.mode != "" {
+				service.Annotations[annDOAdoptionMode] = test.mode
+			}
+			nodes := []*v1.Node{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+				},
+			}
+
+			handMade := godo.LoadBalancer{
+				ID:     "hand-made-id",
+				Name:   "hand-made",
+				IP:     "10.0.0.1",
+				Status: lbStatusActive,
+				ForwardingRules: []godo.ForwardingRule{
+					{EntryProtocol: "tcp", EntryPort: 80, TargetProtocol: "tcp", TargetPort: 8080},
+					extraRule,
+				},
+				Tags: []string{"terraform"},
+			}
+
+			var updateReq *godo.LoadBalancerRequest
+			fakeDroplet := &fakeDropletService{
+				listByTagFunc: func(context.Context, string, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+					return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+				},
+			}
+			fakeLB := &fakeLBService{
+				listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+					return []godo.LoadBalancer{handMade}, newFakeOKResponse(), nil
+				},
+				getFn: func(_ context.Context, id string) (*godo.LoadBalancer, *godo.Response, error) {
+					if id == handMade.ID {
+						return &handMade, newFakeOKResponse(), nil
+					}
+					return nil, newFakeNotFoundResponse(), newFakeNotFoundErrorResponse()
+				},
+				createFn: func(context.Context, *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+					t.Fatal("load-balancer must not be created")
+					return nil, nil, nil
+				},
+				updateFn: func(_ context.Context, _ string, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+					updateReq = lbr
+					lb := handMade
+					lb.ForwardingRules = lbr.ForwardingRules
+					return &lb, newFakeOKResponse(), nil
+				},
+			}
+			gclient := newFakeClient(fakeDroplet, fakeLB, nil)
+			fakeTags := newFakeTagsService(clusterIDTag)
+			gclient.Tags = fakeTags
+			fakeResources := newResources(clusterID, "", publicAccessFirewall{}, gclient)
+			fakeResources.kclient = fake.NewSimpleClientset(service)
+			lbs := &loadBalancers{
+				resources:         fakeResources,
+				region:            "nyc3",
+				lbActiveTimeout:   2,
+				lbActiveCheckTick: 1,
+			}
+
+			status, err := lbs.EnsureLoadBalancer(context.Background(), "test", service, nodes)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			if status == nil || len(status.Ingress) != 1 || status.Ingress[0].IP != handMade.IP {
+				t.Errorf("got status %v, want ingress IP %s", status, handMade.IP)
+			}
+			if got := service.Annotations[annDOLoadBalancerID]; got != handMade.ID {
+				t.Errorf("got load-balancer ID annotation %q, want %q", got, handMade.ID)
+			}
+			if (updateReq != nil) != test.wantUpdate {
+				t.Fatalf("got update request %v, want update: %t", updateReq, test.wantUpdate)
+			}
+			if updateReq != nil {
+				var hasExtraRule bool
+				for _, rule := range updateReq.ForwardingRules {
+					if rule == extraRule {
+						hasExtraRule = true
+					}
+				}
+				if hasExtraRule != test.wantExtraRule {
+					t.Errorf("got forwarding rules %v, want extra rule: %t", updateReq.ForwardingRules, test.wantExtraRule)
+				}
+			}
+			if gotTagRequest := len(fakeTags.tagRequests) > 0; gotTagRequest != test.wantTagRequest {
+				t.Errorf("got tag requests %v, want tag request: %t", fakeTags.tagRequests, test.wantTagRequest)
+			}
+		})
+	}
+}
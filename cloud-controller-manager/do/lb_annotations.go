@@ -21,6 +21,16 @@ const (
 	// used to enable fast retrievals of load-balancers from the API by UUID.
 	annDOLoadBalancerID = "kubernetes.digitalocean.com/load-balancer-id"
 
+	// annDOLoadBalancerReplacementID is the annotation specifying the ID of
+	// the load-balancer that is about to replace the current one because
+	// fields changed that cannot be updated in place.
+	annDOLoadBalancerReplacementID = "kubernetes.digitalocean.com/load-balancer-replacement-id"
+
+	// annDOLoadBalancerSharingGroup is the annotation recording the sharing
+	// group whose load-balancer the Service was last joined to. It is used to
+	// detect Services leaving a group.
+	annDOLoadBalancerSharingGroup = "kubernetes.digitalocean.com/load-balancer-sharing-group"
+
 	annDOLoadBalancerBase = "service.beta.kubernetes.io/do-loadbalancer-"
 
 	// annDOLoadBalancerName is the annotation used to specify a custom name
@@ -164,6 +174,51 @@ const (
 	// disowned. Defaults to false.
 	annDODisownLB = "service.kubernetes.io/do-loadbalancer-disown"
 
+	// annDOAdoptLB is the annotation specifying the ID or name of an
+	// existing load-balancer that should be adopted even though it is not
+	// tagged with the cluster ID or resides in a different VPC.
+	annDOAdoptLB = "service.kubernetes.io/do-loadbalancer-adopt"
+
+	// annDOAdoptionMode is the annotation specifying how a load-balancer
+	// adopted through annDOAdoptLB is managed. Options are observe, merge,
+	// and takeover. Defaults to takeover.
+	annDOAdoptionMode = "service.kubernetes.io/do-loadbalancer-adoption-mode"
+
+	// annDODeletionPolicy is the annotation specifying what happens to the
+	// load-balancer when the Service is deleted. Options are Delete and
+	// Retain. Defaults to Delete.
+	annDODeletionPolicy = "service.kubernetes.io/do-loadbalancer-deletion-policy"
+
+	// annDOSharingGroup is the annotation specifying the sharing group of the
+	// Service. Services of the same namespace and sharing group are served by
+	// a single load-balancer. The value must be a DNS label.
+	annDOSharingGroup = "service.kubernetes.io/do-loadbalancer-sharing-group"
+
+	// annDOPortConfig is the annotation specifying the forwarding rule
+	// configuration per Service port as a JSON or YAML object keyed by port
+	// name or number. It takes precedence over the protocol, port list,
+	// certificate, and TLS passthrough annotations for the ports it covers.
+	annDOPortConfig = "service.kubernetes.io/do-loadbalancer-port-config"
+
+	// annDONodeSelector is the annotation specifying a label selector of the
+	// nodes the load-balancer targets. Defaults to all nodes.
+	annDONodeSelector = "service.kubernetes.io/do-loadbalancer-node-selector"
+
+	// annDOBackendTag is the annotation specifying a droplet tag that the
+	// load-balancer targets instead of a list of droplet IDs. The droplets of
+	// the targeted nodes are tagged accordingly.
+	annDOBackendTag = "service.kubernetes.io/do-loadbalancer-backend-tag"
+
+	// annDOEndpointAwareBackends is the annotation specifying whether the
+	// load-balancer of a Service with external traffic policy Local only
+	// targets the nodes hosting ready endpoints. Defaults to false.
+	annDOEndpointAwareBackends = "service.kubernetes.io/do-loadbalancer-endpoint-aware-backends"
+
+	// annDOAppProtocols is the annotation specifying whether the protocols
+	// of forwarding rules are derived from the application protocols of the
+	// Service ports. Defaults to false.
+	annDOAppProtocols = "service.kubernetes.io/do-loadbalancer-app-protocols"
+
 	// annDOHttpIdleTimeoutSeconds is the annotation for specifying the http idle timeout configuration in seconds
 	// this defaults to 60
 	annDOHttpIdleTimeoutSeconds = annDOLoadBalancerBase + "http-idle-timeout-seconds"
@@ -178,11 +233,58 @@ const (
 	// e.g. - ip:1.2.3.4,cidr:2.3.0.0/16
 	annDOAllowRules = annDOLoadBalancerBase + "allow-rules"
 
-	// annDOType is the annotation used to specify the type of the load balancer. Either REGIONAL or REGIONAL_NETWORK (currently in closed alpha)
-	// are permitted. If no type is provided, then it will default REGIONAL.
+	// annDOType is the annotation used to specify the type of the load balancer. Either REGIONAL, REGIONAL_NETWORK (currently in closed alpha),
+	// or GLOBAL are permitted. If no type is provided, then it will default REGIONAL.
 	annDOType = annDOLoadBalancerBase + "type"
 
 	// annDONetwork is the annotation used to specify the network type of the load balancer. Either EXTERNAL or INTERNAL (currently in closed alpha)
 	// are permitted. If no network is provided, then it will default EXTERNAL.
 	annDONetwork = annDOLoadBalancerBase + "network"
+
+	// annDOGLBTargetProtocol is the annotation used to specify the protocol
+	// a global load balancer uses to reach its targets. Options are http and
+	// https. Defaults to http. Only valid for GLOBAL load balancers.
+	annDOGLBTargetProtocol = annDOLoadBalancerBase + "glb-target-protocol"
+
+	// annDOGLBTargetPort is the annotation used to specify the port a global
+	// load balancer uses to reach its targets. Defaults to 80 when targeting
+	// regional load balancers, and to the node port of the first TCP service
+	// port when targeting droplets. Only valid for GLOBAL load balancers.
+	annDOGLBTargetPort = annDOLoadBalancerBase + "glb-target-port"
+
+	// annDOGLBCDNEnabled is the annotation specifying whether the CDN should
+	// be enabled for a global load balancer. Defaults to false. Only valid for
+	// GLOBAL load balancers.
+	annDOGLBCDNEnabled = annDOLoadBalancerBase + "glb-cdn-enabled"
+
+	// annDOGLBRegionPriorities is the annotation used to specify the region
+	// priorities for active-passive failover of a global load balancer. This
+	// is a comma separated list of region:priority pairs (e.g., nyc1:1,sfo3:2).
+	// Only valid for GLOBAL load balancers.
+	annDOGLBRegionPriorities = annDOLoadBalancerBase + "glb-region-priorities"
+
+	// annDOGLBFailoverThreshold is the annotation used to specify the
+	// percentage of unhealthy targets in a region after which a global load
+	// balancer fails over to the next region. Only valid for GLOBAL load
+	// balancers.
+	annDOGLBFailoverThreshold = annDOLoadBalancerBase + "glb-failover-threshold"
+
+	// annDOGLBDomains is the annotation used to specify the domains a global
+	// load balancer accepts traffic for. This is a comma separated list of
+	// domains, each optionally followed by a certificate ID
+	// (e.g., example.com,www.example.com:<certificate-id>). Required for
+	// GLOBAL load balancers.
+	annDOGLBDomains = annDOLoadBalancerBase + "glb-domains"
+
+	// annDOGLBDomainsManaged is the annotation specifying whether the domains
+	// of a global load balancer are managed by DigitalOcean DNS. Defaults to
+	// false. Only valid for GLOBAL load balancers.
+	annDOGLBDomainsManaged = annDOLoadBalancerBase + "glb-domains-managed"
+
+	// annDOGLBTargetLoadBalancerIDs is the annotation used to specify the
+	// regional load balancers a global load balancer forwards traffic to.
+	// This is a comma separated list of load balancer IDs. If omitted, the
+	// global load balancer targets the cluster's droplets directly. Only
+	// valid for GLOBAL load balancers.
+	annDOGLBTargetLoadBalancerIDs = annDOLoadBalancerBase + "glb-target-load-balancer-ids"
 )
@@ -0,0 +1,111 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Application protocols defined by Kubernetes in addition to IANA service
+// names.
+const (
+	appProtocolH2C   = "kubernetes.io/h2c"
+	appProtocolWS    = "kubernetes.io/ws"
+	appProtocolWSS   = "kubernetes.io/wss"
+	appProtocolHTTP3 = "kubernetes.io/http3"
+)
+
+// appProtocolForwarding returns the entry protocol of the forwarding rule of
+// a port speaking appProtocol, and the target protocol if it differs from
+// the one implied by the entry protocol. terminatesTLS specifies whether the
+// load-balancer terminates TLS with a certificate, and passesTLS whether it
+// passes TLS through. ok is false for unknown application protocols, and for
+// https without a certificate or TLS passthrough, which are left to the
+// default protocol.
+//
+// The application protocol describes what the backends speak, so TLS is
+// re-encrypted towards backends speaking https, and h2c is only passed
+// through as tcp unless TLS is terminated, since DO requires TLS for http2
+// entry protocols. http3 requires a certificate and is served alongside https
+// on the same port, both forwarding to http backends.
+func appProtocolForwarding(appProtocol *string, terminatesTLS, passesTLS bool) (entry, target string, ok bool) {
+	if appProtocol == nil {
+		return "", "", false
+	}
+
+	switch strings.ToLower(*appProtocol) {
+	case protocolHTTP, appProtocolWS:
+		return protocolHTTP, "", true
+	case protocolHTTPS, appProtocolWSS:
+		switch {
+		case terminatesTLS:
+			return protocolHTTPS, protocolHTTPS, true
+		case passesTLS:
+			return protocolHTTPS, "", true
+		default:
+			return "", "", false
+		}
+	case appProtocolH2C:
+		if terminatesTLS {
+			return protocolHTTP2, protocolHTTP2, true
+		}
+		return protocolTCP, "", true
+	case appProtocolHTTP3:
+		if terminatesTLS && !passesTLS {
+			return protocolHTTPS, protocolHTTP, true
+		}
+		return "", "", false
+	default:
+		return "", "", false
+	}
+}
+
+// isHTTP3AppProtocol returns whether appProtocol asks for http3.
+func isHTTP3AppProtocol(appProtocol *string) bool {
+	return appProtocol != nil && strings.ToLower(*appProtocol) == appProtocolHTTP3
+}
+
+// getAppProtocols returns whether forwarding rules of service are derived
+// from the application protocols of its ports. Application protocols are
+// often set as mere metadata, so they are only used if the Service opts in,
+// and never override an explicitly configured protocol.
+func getAppProtocols(service *v1.Service) (bool, error) {
+	enabled, _, err := getBool(service.Annotations, annDOAppProtocols)
+	if err != nil {
+		return false, fmt.Errorf("failed to get application protocols configuration setting: %w", err)
+	}
+	_, hasProtocol := service.Annotations[annDOProtocol]
+	return enabled && !hasProtocol, nil
+}
+
+// hasKnownAppProtocol returns whether the port of service with the given
+// number specifies an application protocol that forwarding rules are derived
+// from. Application protocols must apply to service.
+func hasKnownAppProtocol(service *v1.Service, port int, terminatesTLS, passesTLS bool) bool {
+	for _, p := range service.Spec.Ports {
+		if int(p.Port) != port || p.Protocol == v1.ProtocolUDP {
+			continue
+		}
+		if _, _, ok := appProtocolForwarding(p.AppProtocol, terminatesTLS, passesTLS); ok {
+			return true
+		}
+	}
+	return false
+}
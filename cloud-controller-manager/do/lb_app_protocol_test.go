@@ -0,0 +1,197 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_buildForwardingRules_appProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		// disabled leaves out the opt-in annotation.
+		disabled  bool
+		port      v1.ServicePort
+		want      godo.ForwardingRule
+		wantHTTP3 *godo.ForwardingRule
+		wantErr   bool
+	}{
+		{
+			name:     "disabled by default",
+			disabled: true,
+			port:     v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080, AppProtocol: stringP("http")},
+			want:     godo.ForwardingRule{EntryProtocol: "tcp", EntryPort: 80, TargetProtocol: "tcp", TargetPort: 30080},
+		},
+		{
+			name: "invalid opt-in annotation",
+			annotations: map[string]string{
+				annDOAppProtocols: "maybe",
+			},
+			port:    v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080, AppProtocol: stringP("http")},
+			wantErr: true,
+		},
+		{
+			name: "http",
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080, AppProtocol: stringP("http")},
+			want: godo.ForwardingRule{EntryProtocol: "http", EntryPort: 80, TargetProtocol: "http", TargetPort: 30080},
+		},
+		{
+			name: "websocket",
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080, AppProtocol: stringP("kubernetes.io/ws")},
+			want: godo.ForwardingRule{EntryProtocol: "http", EntryPort: 80, TargetProtocol: "http", TargetPort: 30080},
+		},
+		{
+			name: "https with certificate",
+			annotations: map[string]string{
+				annDOCertificateID: "cert-a",
+			},
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443, AppProtocol: stringP("https")},
+			want: godo.ForwardingRule{EntryProtocol: "https", EntryPort: 443, TargetProtocol: "https", TargetPort: 30443, CertificateID: "cert-a"},
+		},
+		{
+			name: "https with TLS passthrough",
+			annotations: map[string]string{
+				annDOTLSPassThrough: "true",
+			},
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 8443, NodePort: 38443, AppProtocol: stringP("HTTPS")},
+			want: godo.ForwardingRule{EntryProtocol: "https", EntryPort: 8443, TargetProtocol: "https", TargetPort: 38443, TlsPassthrough: true},
+		},
+		{
+			name: "https without certificate or TLS passthrough",
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443, AppProtocol: stringP("https")},
+			want: godo.ForwardingRule{EntryProtocol: "tcp", EntryPort: 443, TargetProtocol: "tcp", TargetPort: 30443},
+		},
+		{
+			name: "h2c with certificate",
+			annotations: map[string]string{
+				annDOCertificateID: "cert-a",
+			},
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443, AppProtocol: stringP("kubernetes.io/h2c")},
+			want: godo.ForwardingRule{EntryProtocol: "http2", EntryPort: 443, TargetProtocol: "http2", TargetPort: 30443, CertificateID: "cert-a"},
+		},
+		{
+			name: "h2c without certificate",
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 8080, NodePort: 38080, AppProtocol: stringP("kubernetes.io/h2c")},
+			want: godo.ForwardingRule{EntryProtocol: "tcp", EntryPort: 8080, TargetProtocol: "tcp", TargetPort: 38080},
+		},
+		{
+			name: "http3 with certificate",
+			annotations: map[string]string{
+				annDOCertificateID: "cert-a",
+			},
+			port:      v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443, AppProtocol: stringP("kubernetes.io/http3")},
+			want:      godo.ForwardingRule{EntryProtocol: "https", EntryPort: 443, TargetProtocol: "http", TargetPort: 30443, CertificateID: "cert-a"},
+			wantHTTP3: &godo.ForwardingRule{EntryProtocol: "http3", EntryPort: 443, TargetProtocol: "http", TargetPort: 30443, CertificateID: "cert-a"},
+		},
+		{
+			name: "http3 without certificate",
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 8443, NodePort: 38443, AppProtocol: stringP("kubernetes.io/http3")},
+			want: godo.ForwardingRule{EntryProtocol: "tcp", EntryPort: 8443, TargetProtocol: "tcp", TargetPort: 38443},
+		},
+		{
+			name: "http3 with http3 port annotation",
+			annotations: map[string]string{
+				annDOCertificateID: "cert-a",
+				annDOHTTP3Port:     "443",
+			},
+			port:    v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443, AppProtocol: stringP("kubernetes.io/http3")},
+			wantErr: true,
+		},
+		{
+			name: "port annotation takes precedence",
+			annotations: map[string]string{
+				annDOHTTPPorts: "443",
+			},
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443, AppProtocol: stringP("https")},
+			want: godo.ForwardingRule{EntryProtocol: "http", EntryPort: 443, TargetProtocol: "http", TargetPort: 30443},
+		},
+		{
+			name: "protocol annotation takes precedence",
+			annotations: map[string]string{
+				annDOProtocol: "http",
+			},
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 8080, NodePort: 38080, AppProtocol: stringP("kubernetes.io/h2c")},
+			want: godo.ForwardingRule{EntryProtocol: "http", EntryPort: 8080, TargetProtocol: "http", TargetPort: 38080},
+		},
+		{
+			name: "protocol annotation takes precedence over https",
+			annotations: map[string]string{
+				annDOProtocol: "tcp",
+			},
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443, AppProtocol: stringP("https")},
+			want: godo.ForwardingRule{EntryProtocol: "tcp", EntryPort: 443, TargetProtocol: "tcp", TargetPort: 30443},
+		},
+		{
+			name: "unknown application protocol",
+			annotations: map[string]string{
+				annDOProtocol: "http",
+			},
+			port: v1.ServicePort{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080, AppProtocol: stringP("example.com/custom")},
+			want: godo.ForwardingRule{EntryProtocol: "http", EntryPort: 80, TargetProtocol: "http", TargetPort: 30080},
+		},
+		{
+			name: "UDP port",
+			port: v1.ServicePort{Protocol: v1.ProtocolUDP, Port: 53, NodePort: 30053, AppProtocol: stringP("http")},
+			want: godo.ForwardingRule{EntryProtocol: "udp", EntryPort: 53, TargetProtocol: "udp", TargetPort: 30053},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if !test.disabled {
+				annotations[annDOAppProtocols] = "true"
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					UID:         "abc123",
+					Annotations: annotations,
+				},
+				Spec: v1.ServiceSpec{
+					Ports: []v1.ServicePort{test.port},
+				},
+			}
+
+			got, err := buildForwardingRules(context.Background(), service, nil)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			want := []godo.ForwardingRule{test.want}
+			if test.wantHTTP3 != nil {
+				want = append(want, *test.wantHTTP3)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got forwarding rules %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+// tagNameRegexp matches the tag names accepted by the DO API.
+var tagNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_:\-]{1,255}$`)
+
+// getBackendTag returns the droplet tag that the load-balancer of service
+// targets, or the empty string if it targets droplet IDs.
+func getBackendTag(service *v1.Service, lbType string) (string, error) {
+	tag, ok := service.Annotations[annDOBackendTag]
+	if !ok {
+		return "", nil
+	}
+	if !tagNameRegexp.MatchString(tag) {
+		return "", invalidAnnotation(annDOBackendTag, "invalid tag %q specified in annotation %q: must consist of 1 to 255 letters, digits, colons, dashes, and underscores", tag, annDOBackendTag)
+	}
+	if lbType == godo.LoadBalancerTypeGlobal {
+		return "", invalidAnnotation(annDOBackendTag, "annotation %q is not supported for load-balancers of type %s", annDOBackendTag, lbType)
+	}
+	return tag, nil
+}
+
+// ensureBackendTag makes sure that the droplets of the selected nodes carry
+// tag, and that the droplets of all other nodes do not, so that the
+// load-balancer only targets the selected nodes. Other nodes include those
+// that the service controller does not pass, e.g., nodes excluded from
+// load-balancers. Droplets that are not nodes are left alone.
+//
+// The cluster tag is never removed since droplets without it would leave the
+// cluster as far as CCM is concerned. Load-balancers targeting the cluster
+// tag thus target all nodes and cannot select any.
+func (l *loadBalancers) ensureBackendTag(ctx context.Context, service *v1.Service, tag string, nodes, selected []*v1.Node) error {
+	clusterTag := l.resources.isClusterTag(tag)
+	if clusterTag {
+		selector, err := nodeSelectorString(service)
+		if err != nil {
+			return err
+		}
+		if selector != "" {
+			return fmt.Errorf("annotation %q cannot be combined with annotation %q when targeting the cluster tag %q", annDONodeSelector, annDOBackendTag, tag)
+		}
+	}
+	if err := l.verifyBackendTagSharing(service, tag); err != nil {
+		return err
+	}
+
+	selectedIDs, err := l.nodesToDropletIDs(ctx, selected)
+	if err != nil {
+		return err
+	}
+	var untagged []*godo.Droplet
+	for _, id := range selectedIDs {
+		droplet, err := l.resources.dropletByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get droplet %d: %s", id, err)
+		}
+		if !slices.Contains(droplet.Tags, tag) {
+			untagged = append(untagged, droplet)
+		}
+	}
+	if err := l.tagDroplets(ctx, tag, untagged); err != nil {
+		return err
+	}
+	if clusterTag {
+		return nil
+	}
+
+	if l.nodeLister != nil {
+		nodes, err = l.nodeLister.List(labels.Everything())
+		if err != nil {
+			return fmt.Errorf("failed to list nodes: %s", err)
+		}
+	}
+	nodeIDs, err := l.nodesToDropletIDs(ctx, nodes)
+	if err != nil {
+		return err
+	}
+	var tagged []*godo.Droplet
+	for _, id := range nodeIDs {
+		if slices.Contains(selectedIDs, id) {
+			continue
+		}
+		droplet, err := l.resources.dropletByID(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get droplet %d: %s", id, err)
+		}
+		if slices.Contains(droplet.Tags, tag) {
+			tagged = append(tagged, droplet)
+		}
+	}
+	return l.untagDroplets(ctx, tag, tagged)
+}
+
+// verifyBackendTagSharing returns an error if another Service targets tag
+// with a different node selector, in which case the load-balancers of both
+// Services would keep removing the tag from each other's droplets.
+func (l *loadBalancers) verifyBackendTagSharing(service *v1.Service, tag string) error {
+	if l.svcLister == nil {
+		return nil
+	}
+	selector, err := nodeSelectorString(service)
+	if err != nil {
+		return err
+	}
+
+	services, err := l.svcLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list services: %s", err)
+	}
+	for _, other := range services {
+		if other.Namespace == service.Namespace && other.Name == service.Name {
+			continue
+		}
+		if other.Spec.Type != v1.ServiceTypeLoadBalancer || other.DeletionTimestamp != nil || other.Annotations[annDOBackendTag] != tag {
+			continue
+		}
+		otherSelector, err := nodeSelectorString(other)
+		if err != nil {
+			continue
+		}
+		if otherSelector != selector {
+			return fmt.Errorf("backend tag %q is shared with service %s/%s, which selects nodes differently", tag, other.Namespace, other.Name)
+		}
+	}
+	return nil
+}
+
+// nodeSelectorString returns the canonical form of the node selector of
+// service, or the empty string if it selects all nodes.
+func nodeSelectorString(service *v1.Service) (string, error) {
+	selector, err := getNodeSelector(service)
+	if err != nil || selector == nil {
+		return "", err
+	}
+	return selector.String(), nil
+}
+
+// tagDroplets adds tag to droplets, creating the tag if it does not exist
+// yet.
+func (l *loadBalancers) tagDroplets(ctx context.Context, tag string, droplets []*godo.Droplet) error {
+	if len(droplets) == 0 {
+		return nil
+	}
+
+	req := &godo.TagResourcesRequest{
+		Resources: dropletResources(droplets),
+	}
+	resp, err := l.resources.gclient.Tags.TagResources(ctx, tag, req)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		if _, _, err := l.resources.gclient.Tags.Create(ctx, &godo.TagCreateRequest{Name: tag}); err != nil {
+			return fmt.Errorf("failed to create tag %q: %s", tag, err)
+		}
+		_, err = l.resources.gclient.Tags.TagResources(ctx, tag, req)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to tag droplets with tag %q: %s", tag, err)
+	}
+
+	for _, droplet := range droplets {
+		klog.Infof("Tagged droplet %s (%d) with load-balancer backend tag %q", droplet.Name, droplet.ID, tag)
+		droplet.Tags = append(droplet.Tags, tag)
+		l.resources.dropletChanged(droplet)
+	}
+	return nil
+}
+
+// untagDroplets removes tag from droplets.
+func (l *loadBalancers) untagDroplets(ctx context.Context, tag string, droplets []*godo.Droplet) error {
+	if len(droplets) == 0 {
+		return nil
+	}
+
+	_, err := l.resources.gclient.Tags.UntagResources(ctx, tag, &godo.UntagResourcesRequest{
+		Resources: dropletResources(droplets),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove tag %q from droplets: %s", tag, err)
+	}
+
+	for _, droplet := range droplets {
+		klog.Infof("Removed load-balancer backend tag %q from droplet %s (%d)", tag, droplet.Name, droplet.ID)
+		droplet.Tags = slices.DeleteFunc(droplet.Tags, func(t string) bool {
+			return t == tag
+		})
+		l.resources.dropletChanged(droplet)
+	}
+	return nil
+}
+
+func dropletResources(droplets []*godo.Droplet) []godo.Resource {
+	res := make([]godo.Resource, 0, len(droplets))
+	for _, droplet := range droplets {
+		res = append(res, godo.Resource{
+			ID:   strconv.Itoa(droplet.ID),
+			Type: godo.DropletResourceType,
+		})
+	}
+	return res
+}
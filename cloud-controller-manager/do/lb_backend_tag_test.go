@@ -0,0 +1,380 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+)
+
+func Test_getBackendTag(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		lbType      string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:   "no annotation",
+			lbType: godo.LoadBalancerTypeRegional,
+		},
+		{
+			name: "valid tag",
+			annotations: map[string]string{
+				annDOBackendTag: "k8s:worker-pool_1",
+			},
+			lbType: godo.LoadBalancerTypeRegional,
+			want:   "k8s:worker-pool_1",
+		},
+		{
+			name: "empty tag",
+			annotations: map[string]string{
+				annDOBackendTag: "",
+			},
+			lbType:  godo.LoadBalancerTypeRegional,
+			wantErr: true,
+		},
+		{
+			name: "invalid tag",
+			annotations: map[string]string{
+				annDOBackendTag: "web pool",
+			},
+			lbType:  godo.LoadBalancerTypeRegional,
+			wantErr: true,
+		},
+		{
+			name: "global load-balancer",
+			annotations: map[string]string{
+				annDOBackendTag: "web",
+			},
+			lbType:  godo.LoadBalancerTypeGlobal,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := getBackendTag(newTestService(test.annotations), test.lbType)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got tag %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_buildLoadBalancerRequest_backendTag(t *testing.T) {
+	const backendTag = "web-backends"
+
+	tests := []struct {
+		name string
+		// tag defaults to backendTag.
+		tag         string
+		annotations map[string]string
+		clusterID   string
+		// excludedNodes are known to the node lister but not passed by the
+		// service controller.
+		excludedNodes []*v1.Node
+		wantTagged    []string
+		wantUntagged  []string
+		wantErr       bool
+	}{
+		{
+			name: "tag untagged droplets",
+			annotations: map[string]string{
+				annDOBackendTag: backendTag,
+			},
+			wantTagged: []string{"102"},
+		},
+		{
+			name: "untag droplets of unselected nodes",
+			annotations: map[string]string{
+				annDOBackendTag:   backendTag,
+				annDONodeSelector: "pool=web",
+			},
+			wantTagged:   []string{"102"},
+			wantUntagged: []string{"101"},
+		},
+		{
+			name: "untag droplets of excluded nodes",
+			annotations: map[string]string{
+				annDOBackendTag: backendTag,
+			},
+			excludedNodes: []*v1.Node{
+				newNodeSelectorTestNode("node-4", "digitalocean://103", map[string]string{"pool": "web"}),
+			},
+			wantTagged:   []string{"102"},
+			wantUntagged: []string{"103"},
+		},
+		{
+			name: "cluster tag keeps droplets of excluded nodes tagged",
+			tag:  buildK8sTag(clusterID),
+			annotations: map[string]string{
+				annDOBackendTag: buildK8sTag(clusterID),
+			},
+			clusterID: clusterID,
+			excludedNodes: []*v1.Node{
+				newNodeSelectorTestNode("node-4", "digitalocean://103", map[string]string{"pool": "web"}),
+			},
+			wantTagged: []string{"102"},
+		},
+		{
+			name: "cluster tag with node selector",
+			tag:  buildK8sTag(clusterID),
+			annotations: map[string]string{
+				annDOBackendTag:   buildK8sTag(clusterID),
+				annDONodeSelector: "pool=web",
+			},
+			clusterID: clusterID,
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tag := test.tag
+			if tag == "" {
+				tag = backendTag
+			}
+			nodes := []*v1.Node{
+				newNodeSelectorTestNode("node-1", "digitalocean://100", map[string]string{"pool": "web"}),
+				newNodeSelectorTestNode("node-2", "digitalocean://101", map[string]string{"pool": "db"}),
+				newNodeSelectorTestNode("node-3", "digitalocean://102", map[string]string{"pool": "web"}),
+			}
+			droplets := []godo.Droplet{
+				{ID: 100, Name: "node-1", Tags: []string{tag}},
+				{ID: 101, Name: "node-2", Tags: []string{tag}},
+				{ID: 102, Name: "node-3"},
+				{ID: 103, Name: "node-4", Tags: []string{tag}},
+			}
+			fakeDroplet := &fakeDropletService{
+				listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+					return droplets, newFakeOKResponse(), nil
+				},
+				listByTagFunc: func(context.Context, string, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+					return droplets, newFakeOKResponse(), nil
+				},
+			}
+			gclient := newFakeClient(fakeDroplet, &fakeLBService{}, nil)
+			fakeTags := newFakeTagsService()
+			gclient.Tags = fakeTags
+			lbs := &loadBalancers{
+				resources:         newResources(test.clusterID, "", publicAccessFirewall{}, gclient),
+				region:            "nyc3",
+				lbActiveTimeout:   2,
+				lbActiveCheckTick: 1,
+				nodeLister:        newNodeLister(t, append(slices.Clone(nodes), test.excludedNodes...)...),
+			}
+			service := newTestService(test.annotations)
+
+			req, err := lbs.buildLoadBalancerRequest(context.Background(), service, nodes)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			if req.Tag != tag {
+				t.Errorf("got tag %q, want %q", req.Tag, tag)
+			}
+			if len(req.DropletIDs) > 0 {
+				t.Errorf("got droplet IDs %v, want none", req.DropletIDs)
+			}
+			if !fakeTags.tags[tag] {
+				t.Errorf("tag %q was not created", tag)
+			}
+
+			var gotTagged, gotUntagged []string
+			for _, r := range fakeTags.tagRequests {
+				for _, res := range r.Resources {
+					gotTagged = append(gotTagged, res.ID)
+				}
+			}
+			for _, r := range fakeTags.untagRequests {
+				for _, res := range r.Resources {
+					gotUntagged = append(gotUntagged, res.ID)
+				}
+			}
+			if !reflect.DeepEqual(gotTagged, test.wantTagged) {
+				t.Errorf("got tagged droplets %v, want %v", gotTagged, test.wantTagged)
+			}
+			if !reflect.DeepEqual(gotUntagged, test.wantUntagged) {
+				t.Errorf("got untagged droplets %v, want %v", gotUntagged, test.wantUntagged)
+			}
+
+			// Droplets that are tagged already are not tagged again.
+			tagRequests := len(fakeTags.tagRequests)
+			if _, err := lbs.buildLoadBalancerRequest(context.Background(), service, nodes); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(fakeTags.tagRequests) != tagRequests {
+				t.Errorf("got %d tag request(s) after rebuilding the request, want %d", len(fakeTags.tagRequests), tagRequests)
+			}
+		})
+	}
+}
+
+func Test_loadBalancerRequestEqual_backendTag(t *testing.T) {
+	tests := []struct {
+		name       string
+		liveTag    string
+		liveIDs    []int
+		desiredTag string
+		desiredIDs []int
+		wantEqual  bool
+	}{
+		{
+			name:       "tag unchanged with droplets added",
+			liveTag:    "web",
+			liveIDs:    []int{100, 101},
+			desiredTag: "web",
+			wantEqual:  true,
+		},
+		{
+			name:       "migrate from droplet IDs to tag",
+			liveIDs:    []int{100, 101},
+			desiredTag: "web",
+		},
+		{
+			name:       "migrate from tag to droplet IDs",
+			liveTag:    "web",
+			liveIDs:    []int{100, 101},
+			desiredIDs: []int{100, 101},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lb := &godo.LoadBalancer{
+				Name:       "lb",
+				Region:     &godo.Region{Slug: "nyc3"},
+				Tag:        test.liveTag,
+				DropletIDs: test.liveIDs,
+			}
+			req := &godo.LoadBalancerRequest{
+				Name:       "lb",
+				Region:     "nyc3",
+				Tag:        test.desiredTag,
+				DropletIDs: test.desiredIDs,
+			}
+
+			if gotEqual, diff := loadBalancerRequestEqual(lb, req); gotEqual != test.wantEqual {
+				t.Errorf("got equal %t, want %t (diff: %s)", gotEqual, test.wantEqual, diff)
+			}
+		})
+	}
+}
+
+func Test_verifyBackendTagSharing(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{
+			name: "different tag",
+			annotations: map[string]string{
+				annDOBackendTag: "other",
+			},
+		},
+		{
+			name: "same tag and selector",
+			annotations: map[string]string{
+				annDOBackendTag:   "web",
+				annDONodeSelector: "tier=frontend,pool=web",
+			},
+		},
+		{
+			name: "same tag with different selector",
+			annotations: map[string]string{
+				annDOBackendTag:   "web",
+				annDONodeSelector: "pool=db",
+			},
+			wantErr: true,
+		},
+		{
+			name: "same tag without selector",
+			annotations: map[string]string{
+				annDOBackendTag: "web",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newTestService(map[string]string{
+				annDOBackendTag:   "web",
+				annDONodeSelector: "pool=web,tier=frontend",
+			})
+			other := newTestService(test.annotations)
+			other.Name = "other"
+
+			lbs := &loadBalancers{svcLister: newServiceLister(t, service, other)}
+
+			err := lbs.verifyBackendTagSharing(service, "web")
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnsureLoadBalancerDeleted_sharingBackendTag(t *testing.T) {
+	const tag = "web-backends"
+
+	first := newSharingTestService("first", 2*time.Hour, "web", 80)
+	second := newSharingTestService("second", time.Hour, "web", 443)
+	for _, service := range []*v1.Service{first, second} {
+		service.Annotations[annDOBackendTag] = tag
+	}
+	store := map[string]*godo.LoadBalancer{}
+	lbs, _ := newSharingTestLoadBalancers(t, store, first, second)
+	fakeTags := newFakeTagsService()
+	lbs.resources.gclient.Tags = fakeTags
+	node := newNodeSelectorTestNode("node-1", "digitalocean://100", nil)
+	lbs.nodeLister = newNodeLister(t, node)
+	nodes := []*v1.Node{node}
+
+	for _, service := range []*v1.Service{first, second} {
+		if _, err := lbs.EnsureLoadBalancer(context.Background(), "test", service, nodes); err != nil {
+			t.Fatalf("failed to ensure load-balancer for service %s: %s", service.Name, err)
+		}
+	}
+	if len(fakeTags.tagRequests) != 1 {
+		t.Fatalf("got %d tag request(s), want 1", len(fakeTags.tagRequests))
+	}
+
+	// The droplets of the remaining member keep the tag.
+	deleteSharingTestService(t, lbs, first)
+	if err := lbs.EnsureLoadBalancerDeleted(context.Background(), "test", first); err != nil {
+		t.Fatalf("failed to delete load-balancer for first service: %s", err)
+	}
+	if len(fakeTags.untagRequests) != 0 {
+		t.Errorf("got %d untag request(s) after a member left, want none", len(fakeTags.untagRequests))
+	}
+}
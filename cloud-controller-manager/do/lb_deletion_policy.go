@@ -0,0 +1,109 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+type lbDeletionPolicy string
+
+const (
+	// lbDeletionPolicyDelete deletes the load-balancer together with the
+	// Service.
+	lbDeletionPolicyDelete lbDeletionPolicy = "Delete"
+	// lbDeletionPolicyRetain keeps the load-balancer and its IP address when
+	// the Service is deleted so that it can be adopted later on.
+	lbDeletionPolicyRetain lbDeletionPolicy = "Retain"
+)
+
+// getDeletionPolicy returns the deletion policy of service. It defaults to
+// Delete.
+func getDeletionPolicy(service *v1.Service) (lbDeletionPolicy, error) {
+	policy, ok := service.Annotations[annDODeletionPolicy]
+	if !ok {
+		return lbDeletionPolicyDelete, nil
+	}
+
+	switch p := lbDeletionPolicy(policy); p {
+	case lbDeletionPolicyDelete, lbDeletionPolicyRetain:
+		return p, nil
+	default:
+		return "", invalidAnnotation(annDODeletionPolicy, "invalid deletion policy %q specified in annotation %q, options are %q and %q", policy, annDODeletionPolicy, lbDeletionPolicyDelete, lbDeletionPolicyRetain)
+	}
+}
+
+// retainLoadBalancer releases lb instead of deleting it: the droplets are
+// removed from lb and the cluster ID tag is stripped so that neither this
+// cluster's garbage collector nor its ownership checks claim lb anymore.
+func (l *loadBalancers) retainLoadBalancer(ctx context.Context, service *v1.Service, lb *godo.LoadBalancer) error {
+	// Droplets cannot be removed from load-balancers targeting a tag, so the
+	// tag is dropped instead.
+	if lb.Tag != "" {
+		lbRequest := lb.AsRequest()
+		lbRequest.Tag = ""
+		lbRequest.DropletIDs = nil
+		_, resp, err := l.resources.gclient.LoadBalancers.Update(ctx, lb.ID, lbRequest)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				l.resources.loadBalancerDeleted(lb.ID)
+				return nil
+			}
+			l.recordAPIRejection(service, "backend removal", err)
+			return fmt.Errorf("failed to remove backend tag from retained load-balancer: %s", err)
+		}
+	} else if len(lb.DropletIDs) > 0 {
+		resp, err := l.resources.gclient.LoadBalancers.RemoveDroplets(ctx, lb.ID, lb.DropletIDs...)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				l.resources.loadBalancerDeleted(lb.ID)
+				return nil
+			}
+			l.recordAPIRejection(service, "backend removal", err)
+			return fmt.Errorf("failed to remove droplets from retained load-balancer: %s", err)
+		}
+	}
+
+	tag := buildK8sTag(l.resources.clusterID)
+	if l.resources.clusterID != "" && slices.Contains(lb.Tags, tag) {
+		_, err := l.resources.gclient.Tags.UntagResources(ctx, tag, &godo.UntagResourcesRequest{
+			Resources: []godo.Resource{
+				{
+					ID:   lb.ID,
+					Type: godo.LoadBalancerResourceType,
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to remove tag %q from retained load-balancer: %s", tag, err)
+		}
+	}
+	// The droplets and tags of the load-balancer changed.
+	l.resources.invalidateLoadBalancers()
+
+	klog.Infof("Retained load-balancer %s (%s) of deleted service %s/%s", lb.Name, lb.ID, service.Namespace, service.Name)
+	l.recordEvent(service, v1.EventTypeNormal, eventReasonLBRetained, "Retained load-balancer %s (%s) with IP %s", lb.Name, lb.ID, lb.IP)
+
+	return nil
+}
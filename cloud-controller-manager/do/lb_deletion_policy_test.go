@@ -0,0 +1,159 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_getDeletionPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *string
+		want    lbDeletionPolicy
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: lbDeletionPolicyDelete,
+		},
+		{
+			name:   "retain",
+			policy: stringP("Retain"),
+			want:   lbDeletionPolicyRetain,
+		},
+		{
+			name:    "invalid policy",
+			policy:  stringP("retain"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: map[string]string{},
+				},
+			}
+			if test.policy != nil {
+				service.Annotations[annDODeletionPolicy] = *test.policy
+			}
+
+			got, err := getDeletionPolicy(service)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got policy %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnsureLoadBalancerDeleted_retain(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		wantDeleted bool
+		wantRemoved []int
+		wantUntag   bool
+	}{
+		{
+			name:        "delete",
+			policy:      "Delete",
+			wantDeleted: true,
+		},
+		{
+			name:        "retain",
+			policy:      "Retain",
+			wantRemoved: []int{100, 101},
+			wantUntag:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: v1.NamespaceDefault,
+					UID:       "foobar123",
+					Annotations: map[string]string{
+						annDOLoadBalancerID: "load-balancer-id",
+						annDODeletionPolicy: test.policy,
+					},
+				},
+			}
+
+			var (
+				deleted bool
+				removed []int
+			)
+			fakeLB := &fakeLBService{
+				getFn: func(context.Context, string) (*godo.LoadBalancer, *godo.Response, error) {
+					return &godo.LoadBalancer{
+						ID:         "load-balancer-id",
+						Name:       "afoobar123",
+						IP:         "10.0.0.1",
+						Status:     lbStatusActive,
+						DropletIDs: []int{100, 101},
+						Tags:       []string{clusterIDTag},
+					}, newFakeOKResponse(), nil
+				},
+				deleteFn: func(context.Context, string) (*godo.Response, error) {
+					deleted = true
+					return newFakeOKResponse(), nil
+				},
+				removeDropletsFn: func(_ context.Context, _ string, dropletIDs ...int) (*godo.Response, error) {
+					removed = dropletIDs
+					return newFakeOKResponse(), nil
+				},
+			}
+			gclient := newFakeLBClient(fakeLB)
+			fakeTags := newFakeTagsService(clusterIDTag)
+			gclient.Tags = fakeTags
+			lbs := &loadBalancers{
+				resources:         newResources(clusterID, "", publicAccessFirewall{}, gclient),
+				region:            "nyc3",
+				lbActiveTimeout:   2,
+				lbActiveCheckTick: 1,
+			}
+
+			if err := lbs.EnsureLoadBalancerDeleted(context.Background(), "test", service); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if deleted != test.wantDeleted {
+				t.Errorf("got deleted %t, want %t", deleted, test.wantDeleted)
+			}
+			if !reflect.DeepEqual(removed, test.wantRemoved) {
+				t.Errorf("got removed droplets %v, want %v", removed, test.wantRemoved)
+			}
+			if gotUntag := len(fakeTags.untagRequests) > 0; gotUntag != test.wantUntag {
+				t.Errorf("got untag requests %v, want untag request: %t", fakeTags.untagRequests, test.wantUntag)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/digitalocean/godo"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// loadBalancerRequestEqual returns whether updating lb with req would be a
+// no-op. If not, a human-readable diff (-live +desired) is returned as well.
+func loadBalancerRequestEqual(lb *godo.LoadBalancer, req *godo.LoadBalancerRequest) (bool, string) {
+	equal, diff, _ := loadBalancerRequestDiff(lb, req)
+	return equal, diff
+}
+
+// loadBalancerRequestDiff is like loadBalancerRequestEqual but additionally
+// returns the sorted names of the top-level request fields that differ.
+func loadBalancerRequestDiff(lb *godo.LoadBalancer, req *godo.LoadBalancerRequest) (bool, string, []string) {
+	// Equality when both variables are empty.
+	if lb == nil && req == nil {
+		return true, "", nil
+	}
+
+	// Non-equality when exactly one of two variables is empty.
+	if lb == nil || req == nil {
+		return false, "", nil
+	}
+
+	live := normalizeLoadBalancerRequest(lb.AsRequest())
+	desired := normalizeLoadBalancerRequest(copyLoadBalancerRequest(req))
+	ignoreUnsetDefaults(live, desired)
+
+	// Define custom sorters to guard against non-deterministic sort orders.
+	sorterDropletIDs := cmpopts.SortSlices(func(id1, id2 int) bool {
+		return id1 < id2
+	})
+	sorterForwardingRules := cmpopts.SortSlices(func(r1, r2 godo.ForwardingRule) bool {
+		return r1.String() < r2.String()
+	})
+	sorterDomains := cmpopts.SortSlices(func(d1, d2 *godo.LBDomain) bool {
+		return d1.Name < d2.Name
+	})
+	// String slices (i.e., firewall rules and target load-balancer IDs) are
+	// unordered sets.
+	sorterStrings := cmpopts.SortSlices(func(s1, s2 string) bool {
+		return s1 < s2
+	})
+
+	// Tags are managed by the resources controller, and the remaining
+	// ignored fields are never set by us.
+	ignoredFields := cmpopts.IgnoreFields(godo.LoadBalancerRequest{}, "Tags", "ValidateOnly", "ProjectID")
+
+	opts := []cmp.Option{sorterDropletIDs, sorterForwardingRules, sorterDomains, sorterStrings, ignoredFields, cmpopts.EquateEmpty()}
+	diff := cmp.Diff(live, desired, opts...)
+	if diff == "" {
+		return true, "", nil
+	}
+
+	reporter := &fieldDiffReporter{fields: map[string]bool{}}
+	cmp.Equal(live, desired, append(opts, cmp.Reporter(reporter))...)
+	fields := make([]string, 0, len(reporter.fields))
+	for field := range reporter.fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return false, diff, fields
+}
+
+// fieldDiffReporter is a cmp.Reporter collecting the names of the top-level
+// struct fields that differ.
+type fieldDiffReporter struct {
+	path   cmp.Path
+	fields map[string]bool
+}
+
+func (r *fieldDiffReporter) PushStep(ps cmp.PathStep) {
+	r.path = append(r.path, ps)
+}
+
+func (r *fieldDiffReporter) Report(rs cmp.Result) {
+	if rs.Equal() {
+		return
+	}
+	for _, ps := range r.path {
+		if sf, ok := ps.(cmp.StructField); ok {
+			r.fields[sf.Name()] = true
+			return
+		}
+	}
+}
+
+func (r *fieldDiffReporter) PopStep() {
+	r.path = r.path[:len(r.path)-1]
+}
+
+// copyLoadBalancerRequest returns a copy of req that can be normalized
+// without modifying req. Only fields that normalization modifies are copied
+// deeply.
+func copyLoadBalancerRequest(req *godo.LoadBalancerRequest) *godo.LoadBalancerRequest {
+	reqCopy := *req
+	reqCopy.ForwardingRules = append([]godo.ForwardingRule(nil), req.ForwardingRules...)
+	if req.HealthCheck != nil {
+		hc := *req.HealthCheck
+		reqCopy.HealthCheck = &hc
+	}
+	if req.GLBSettings != nil {
+		glb := *req.GLBSettings
+		reqCopy.GLBSettings = &glb
+	}
+	return &reqCopy
+}
+
+// normalizeLoadBalancerRequest maps values the API treats alike to a single
+// representation.
+func normalizeLoadBalancerRequest(req *godo.LoadBalancerRequest) *godo.LoadBalancerRequest {
+	if req.Type == "" {
+		req.Type = godo.LoadBalancerTypeRegional
+	}
+	if req.Network == "" {
+		req.Network = godo.LoadBalancerNetworkTypeExternal
+	}
+	if req.DisableLetsEncryptDNSRecords == nil {
+		req.DisableLetsEncryptDNSRecords = godo.PtrTo(false)
+	}
+	if req.Firewall == nil {
+		req.Firewall = &godo.LBFirewall{}
+	}
+
+	for i, rule := range req.ForwardingRules {
+		rule.EntryProtocol = strings.ToLower(rule.EntryProtocol)
+		rule.TargetProtocol = strings.ToLower(rule.TargetProtocol)
+		req.ForwardingRules[i] = rule
+	}
+
+	if req.HealthCheck != nil {
+		req.HealthCheck.Protocol = strings.ToLower(req.HealthCheck.Protocol)
+		// TCP health checks do not have a path.
+		if req.HealthCheck.Protocol == protocolTCP {
+			req.HealthCheck.Path = ""
+		}
+	}
+
+	// Only compare the domain fields that we manage; the rest is status
+	// information.
+	domains := make([]*godo.LBDomain, 0, len(req.Domains))
+	for _, d := range req.Domains {
+		domains = append(domains, &godo.LBDomain{
+			Name:          d.Name,
+			IsManaged:     d.IsManaged,
+			CertificateID: d.CertificateID,
+		})
+	}
+	req.Domains = domains
+
+	if req.GLBSettings != nil && req.GLBSettings.CDN == nil {
+		req.GLBSettings.CDN = &godo.CDNSettings{}
+	}
+
+	return req
+}
+
+// ignoreUnsetDefaults resets fields on live that desired leaves unset,
+// deferring to whatever default the API chose.
+func ignoreUnsetDefaults(live, desired *godo.LoadBalancerRequest) {
+	// Size slug and unit are mutually exclusive, but the API reports both.
+	switch {
+	case desired.SizeSlug == "" && desired.SizeUnit == 0:
+		live.SizeSlug = ""
+		live.SizeUnit = 0
+	case desired.SizeSlug == "":
+		live.SizeSlug = ""
+	case desired.SizeUnit == 0:
+		live.SizeUnit = 0
+	}
+
+	// The API reports the droplets carrying the tag of load-balancers
+	// targeting a tag.
+	if desired.Tag != "" {
+		live.DropletIDs = nil
+	}
+
+	// Global load-balancers are not bound to a region.
+	if desired.Region == "" {
+		live.Region = ""
+	}
+
+	// Load-balancers without a VPC are placed in the default VPC of their
+	// region.
+	if desired.VPCUUID == "" {
+		live.VPCUUID = ""
+	}
+
+	if desired.HTTPIdleTimeoutSeconds == nil {
+		live.HTTPIdleTimeoutSeconds = nil
+	}
+
+	// An unset PROXY protocol health check setting keeps the value on the
+	// load-balancer.
+	if live.HealthCheck != nil && desired.HealthCheck != nil && desired.HealthCheck.ProxyProtocol == nil {
+		live.HealthCheck.ProxyProtocol = nil
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newDiffTestLoadBalancerRequest() *godo.LoadBalancerRequest {
+	return &godo.LoadBalancerRequest{
+		Name:       "afoobar123",
+		Type:       godo.LoadBalancerTypeRegional,
+		Network:    godo.LoadBalancerNetworkTypeExternal,
+		Region:     "nyc3",
+		DropletIDs: []int{100, 101, 102},
+		ForwardingRules: []godo.ForwardingRule{
+			{EntryProtocol: "http", EntryPort: 80, TargetProtocol: "http", TargetPort: 30000},
+			{EntryProtocol: "https", EntryPort: 443, TargetProtocol: "http", TargetPort: 30000, CertificateID: "cert"},
+		},
+		HealthCheck:                  defaultHealthCheck(kubeProxyHealthPort),
+		StickySessions:               &godo.StickySessions{Type: "none"},
+		Algorithm:                    "round_robin",
+		DisableLetsEncryptDNSRecords: godo.PtrTo(false),
+		Firewall:                     &godo.LBFirewall{Allow: []string{"ip:1.2.3.4", "cidr:2.3.0.0/16"}},
+		Tags:                         []string{buildK8sTag(clusterID)},
+	}
+}
+
+// newLiveLoadBalancer returns the load-balancer the API reports after
+// applying req.
+func newLiveLoadBalancer(req *godo.LoadBalancerRequest) *godo.LoadBalancer {
+	lb := &godo.LoadBalancer{
+		ID:                           "load-balancer-id",
+		Name:                         req.Name,
+		Type:                         req.Type,
+		Network:                      req.Network,
+		Region:                       &godo.Region{Slug: req.Region},
+		SizeSlug:                     "lb-small",
+		SizeUnit:                     1,
+		Algorithm:                    req.Algorithm,
+		Status:                       lbStatusActive,
+		DropletIDs:                   append([]int(nil), req.DropletIDs...),
+		ForwardingRules:              append([]godo.ForwardingRule(nil), req.ForwardingRules...),
+		StickySessions:               req.StickySessions,
+		DisableLetsEncryptDNSRecords: req.DisableLetsEncryptDNSRecords,
+		HTTPIdleTimeoutSeconds:       godo.PtrTo(uint64(60)),
+		Firewall:                     req.Firewall,
+		Tags:                         req.Tags,
+		Tag:                          req.Tag,
+	}
+	hc := *req.HealthCheck
+	lb.HealthCheck = &hc
+	return lb
+}
+
+func Test_loadBalancerRequestEqual(t *testing.T) {
+	tests := []struct {
+		name      string
+		mutateLB  func(*godo.LoadBalancer)
+		mutateReq func(*godo.LoadBalancerRequest)
+		wantEqual bool
+		wantDiff  string
+	}{
+		{
+			name:      "unchanged",
+			wantEqual: true,
+		},
+		{
+			name: "different ordering",
+			mutateLB: func(lb *godo.LoadBalancer) {
+				lb.DropletIDs = []int{102, 100, 101}
+				lb.ForwardingRules[0], lb.ForwardingRules[1] = lb.ForwardingRules[1], lb.ForwardingRules[0]
+				lb.Firewall = &godo.LBFirewall{Allow: []string{"cidr:2.3.0.0/16", "ip:1.2.3.4"}}
+			},
+			wantEqual: true,
+		},
+		{
+			name: "API defaults",
+			mutateLB: func(lb *godo.LoadBalancer) {
+				lb.Tags = nil
+				lb.DisableLetsEncryptDNSRecords = nil
+				lb.Firewall = nil
+			},
+			mutateReq: func(req *godo.LoadBalancerRequest) {
+				req.Firewall = &godo.LBFirewall{}
+			},
+			wantEqual: true,
+		},
+		{
+			name: "unset PROXY protocol health check setting",
+			mutateLB: func(lb *godo.LoadBalancer) {
+				lb.HealthCheck.ProxyProtocol = godo.PtrTo(true)
+			},
+			mutateReq: func(req *godo.LoadBalancerRequest) {
+				req.HealthCheck.ProxyProtocol = nil
+			},
+			wantEqual: true,
+		},
+		{
+			name: "unset VPC",
+			mutateLB: func(lb *godo.LoadBalancer) {
+				lb.VPCUUID = "some-default-vpc"
+			},
+			mutateReq: func(req *godo.LoadBalancerRequest) {
+				req.VPCUUID = ""
+			},
+			wantEqual: true,
+		},
+		{
+			name: "VPC changed",
+			mutateLB: func(lb *godo.LoadBalancer) {
+				lb.VPCUUID = "some-default-vpc"
+			},
+			mutateReq: func(req *godo.LoadBalancerRequest) {
+				req.VPCUUID = "cluster-vpc"
+			},
+			wantDiff: "VPCUUID",
+		},
+		{
+			name: "droplet removed",
+			mutateReq: func(req *godo.LoadBalancerRequest) {
+				req.DropletIDs = []int{100, 101}
+			},
+			wantDiff: "102",
+		},
+		{
+			name: "size unit changed",
+			mutateReq: func(req *godo.LoadBalancerRequest) {
+				req.SizeUnit = 3
+			},
+			wantDiff: "SizeUnit",
+		},
+		{
+			name: "health check changed",
+			mutateReq: func(req *godo.LoadBalancerRequest) {
+				req.HealthCheck.CheckIntervalSeconds = 30
+			},
+			wantDiff: "CheckIntervalSeconds",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := newDiffTestLoadBalancerRequest()
+			lb := newLiveLoadBalancer(req)
+			if test.mutateLB != nil {
+				test.mutateLB(lb)
+			}
+			if test.mutateReq != nil {
+				test.mutateReq(req)
+			}
+			before := req.String()
+
+			equal, diff := loadBalancerRequestEqual(lb, req)
+			if equal != test.wantEqual {
+				t.Errorf("got equal %t, want %t (diff: %s)", equal, test.wantEqual, diff)
+			}
+			if !strings.Contains(diff, test.wantDiff) {
+				t.Errorf("got diff %q, want it to contain %q", diff, test.wantDiff)
+			}
+			if after := req.String(); after != before {
+				t.Errorf("request was modified\nbefore: %s\nafter:  %s", before, after)
+			}
+		})
+	}
+}
+
+func Test_updateLoadBalancerSkipsNoOp(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			UID:  "foobar123",
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{
+				{
+					Name:     "test",
+					Protocol: v1.ProtocolTCP,
+					Port:     80,
+					NodePort: 30000,
+				},
+			},
+		},
+	}
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		},
+	}
+
+	var updates int
+	fakeDroplet := &fakeDropletService{
+		listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+			return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+		},
+	}
+	fakeLB := &fakeLBService{
+		updateFn: func(_ context.Context, lbID string, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+			updates++
+			return newLiveLoadBalancer(lbr), newFakeOKResponse(), nil
+		},
+	}
+	fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(fakeDroplet, fakeLB, nil))
+	lbs := &loadBalancers{
+		resources:         fakeResources,
+		region:            "nyc3",
+		lbActiveTimeout:   2,
+		lbActiveCheckTick: 1,
+	}
+
+	req, err := lbs.buildLoadBalancerRequest(context.Background(), service, nodes)
+	if err != nil {
+		t.Fatalf("failed to build load-balancer request: %s", err)
+	}
+	live := newLiveLoadBalancer(req)
+
+	if _, err := lbs.updateLoadBalancer(context.Background(), live, service, nodes); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if updates != 0 {
+		t.Errorf("got %d update(s) for unchanged load-balancer, want 0", updates)
+	}
+
+	service.Annotations = map[string]string{annDOAlgorithm: "least_connections"}
+	if _, err := lbs.updateLoadBalancer(context.Background(), live, service, nodes); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if updates != 1 {
+		t.Errorf("got %d update(s) for changed load-balancer, want 1", updates)
+	}
+}
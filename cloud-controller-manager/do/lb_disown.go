@@ -0,0 +1,114 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// lbDisownedStatusSyncPeriod is the interval at which the status of
+	// disowned Services is synced with their load-balancers.
+	lbDisownedStatusSyncPeriod = 1 * time.Minute
+
+	lbDisownedStatusSyncTimeout = 1 * time.Minute
+)
+
+// observeDisownedLoadBalancer returns the status of a disowned service,
+// keeping it in sync with the load-balancer referenced by the load-balancer
+// ID annotation. The load-balancer is never mutated. The status is cleared if
+// the load-balancer was deleted, and kept if it cannot be observed otherwise.
+func (l *loadBalancers) observeDisownedLoadBalancer(ctx context.Context, service *v1.Service) (*v1.LoadBalancerStatus, error) {
+	current := &service.Status.LoadBalancer
+
+	// Looking up load-balancers by name could pick up a load-balancer the
+	// Service never referenced.
+	id := getLoadBalancerID(service)
+	if id == "" {
+		return current, nil
+	}
+
+	lb, err := l.findLoadBalancerByID(ctx, id)
+	if err != nil {
+		if err == errLBNotFound {
+			if len(current.Ingress) > 0 {
+				klog.Warningf("Disowned load-balancer %s of service %s/%s no longer exists", id, service.Namespace, service.Name)
+				l.recordEvent(service, v1.EventTypeWarning, eventReasonLBMissing, "Disowned load-balancer %s no longer exists", id)
+			}
+			return &v1.LoadBalancerStatus{}, nil
+		}
+		return nil, err
+	}
+	if lb.Status != lbStatusActive {
+		return current, nil
+	}
+
+	status := loadBalancerStatus(service, lb)
+	if !reflect.DeepEqual(status.Ingress, current.Ingress) {
+		klog.Infof("Updating status of service %s/%s from disowned load-balancer %s", service.Namespace, service.Name, lb.ID)
+	}
+	return status, nil
+}
+
+// syncDisownedStatuses keeps the status of all disowned Services in sync with
+// their load-balancers. The service controller only calls
+// EnsureLoadBalancer when Services or nodes change, which would leave the
+// status stale when the load-balancer changes on its own.
+func (l *loadBalancers) syncDisownedStatuses() error {
+	ctx, cancel := context.WithTimeout(context.Background(), lbDisownedStatusSyncTimeout)
+	defer cancel()
+
+	services, err := l.svcLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list services: %s", err)
+	}
+
+	var errs []error
+	for _, service := range services {
+		if service.Spec.Type != v1.ServiceTypeLoadBalancer || service.DeletionTimestamp != nil {
+			continue
+		}
+		if disowned, err := getDisownLB(service); err != nil || !disowned {
+			continue
+		}
+
+		status, err := l.observeDisownedLoadBalancer(ctx, service)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to observe disowned load-balancer of service %s/%s: %s", service.Namespace, service.Name, err))
+			continue
+		}
+		if reflect.DeepEqual(*status, service.Status.LoadBalancer) {
+			continue
+		}
+
+		updated := service.DeepCopy()
+		updated.Status.LoadBalancer = *status
+		if _, err := l.resources.kclient.CoreV1().Services(service.Namespace).UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update status of service %s/%s: %s", service.Namespace, service.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEnsureLoadBalancer_disowned(t *testing.T) {
+	staleStatus := v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{
+			{IP: "10.0.0.1"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		lb          *godo.LoadBalancer
+		wantStatus  v1.LoadBalancerStatus
+		wantEvent   string
+	}{
+		{
+			name:       "no load-balancer ID",
+			wantStatus: staleStatus,
+		},
+		{
+			name: "IP changed",
+			annotations: map[string]string{
+				annDOLoadBalancerID: "load-balancer-id",
+			},
+			lb: &godo.LoadBalancer{ID: "load-balancer-id", IP: "10.0.0.2", Status: lbStatusActive},
+			wantStatus: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{
+					{IP: "10.0.0.2"},
+				},
+			},
+		},
+		{
+			name: "hostname",
+			annotations: map[string]string{
+				annDOLoadBalancerID: "load-balancer-id",
+				annDOHostname:       "example.com",
+			},
+			lb: &godo.LoadBalancer{ID: "load-balancer-id", IP: "10.0.0.2", Status: lbStatusActive},
+			wantStatus: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{
+					{Hostname: "example.com"},
+				},
+			},
+		},
+		{
+			name: "not active",
+			annotations: map[string]string{
+				annDOLoadBalancerID: "load-balancer-id",
+			},
+			lb:         &godo.LoadBalancer{ID: "load-balancer-id", IP: "10.0.0.2", Status: lbStatusErrored},
+			wantStatus: staleStatus,
+		},
+		{
+			name: "load-balancer deleted",
+			annotations: map[string]string{
+				annDOLoadBalancerID: "load-balancer-id",
+			},
+			wantEvent: eventReasonLBMissing,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{
+				annDODisownLB: "true",
+			}
+			for k, v := range test.annotations {
+				annotations[k] = v
+			}
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   v1.NamespaceDefault,
+					UID:         "foobar123",
+					Annotations: annotations,
+				},
+				Spec: v1.ServiceSpec{
+					Type: v1.ServiceTypeLoadBalancer,
+				},
+				Status: v1.ServiceStatus{
+					LoadBalancer: staleStatus,
+				},
+			}
+
+			// Mutating calls are not faked and would panic.
+			fakeLB := &fakeLBService{
+				getFn: func(context.Context, string) (*godo.LoadBalancer, *godo.Response, error) {
+					if test.lb == nil {
+						return nil, newFakeNotFoundResponse(), newFakeNotFoundErrorResponse()
+					}
+					return test.lb, newFakeOKResponse(), nil
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			lbs := &loadBalancers{
+				resources:         newResources("", "", publicAccessFirewall{}, newFakeLBClient(fakeLB)),
+				region:            "nyc3",
+				lbActiveTimeout:   2,
+				lbActiveCheckTick: 1,
+				recorder:          recorder,
+			}
+
+			status, err := lbs.EnsureLoadBalancer(context.Background(), "test", service, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(*status, test.wantStatus) {
+				t.Errorf("got status %v, want %v", *status, test.wantStatus)
+			}
+
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			switch {
+			case test.wantEvent == "" && len(events) > 0:
+				t.Errorf("got events %q, want none", events)
+			case test.wantEvent != "" && (len(events) == 0 || !strings.Contains(events[0], test.wantEvent)):
+				t.Errorf("got events %q, want %s event", events, test.wantEvent)
+			}
+		})
+	}
+}
+
+func Test_syncDisownedStatuses(t *testing.T) {
+	staleStatus := v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{
+			{IP: "10.0.0.1"},
+		},
+	}
+	newService := func(name, lbID string, disowned bool) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: v1.NamespaceDefault,
+				UID:       types.UID(name + "-uid"),
+				Annotations: map[string]string{
+					annDODisownLB:       strconv.FormatBool(disowned),
+					annDOLoadBalancerID: lbID,
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Type: v1.ServiceTypeLoadBalancer,
+			},
+			Status: v1.ServiceStatus{
+				LoadBalancer: staleStatus,
+			},
+		}
+	}
+	moved := newService("moved", "moved-id", true)
+	deleted := newService("deleted", "deleted-id", true)
+	owned := newService("owned", "owned-id", false)
+
+	// Mutating calls are not faked and would panic.
+	fakeLB := &fakeLBService{
+		getFn: func(_ context.Context, id string) (*godo.LoadBalancer, *godo.Response, error) {
+			switch id {
+			case "moved-id":
+				return &godo.LoadBalancer{ID: id, IP: "10.0.0.2", Status: lbStatusActive}, newFakeOKResponse(), nil
+			case "owned-id":
+				return &godo.LoadBalancer{ID: id, IP: "10.0.0.3", Status: lbStatusActive}, newFakeOKResponse(), nil
+			}
+			return nil, newFakeNotFoundResponse(), newFakeNotFoundErrorResponse()
+		},
+	}
+	fakeResources := newResources("", "", publicAccessFirewall{}, newFakeLBClient(fakeLB))
+	fakeResources.kclient = fake.NewSimpleClientset(moved, deleted, owned)
+	recorder := record.NewFakeRecorder(10)
+	lbs := &loadBalancers{
+		resources: fakeResources,
+		region:    "nyc3",
+		recorder:  recorder,
+		svcLister: newServiceLister(t, moved, deleted, owned),
+	}
+
+	if err := lbs.syncDisownedStatuses(); err != nil {
+		t.Fatalf("failed to sync: %s", err)
+	}
+
+	wantStatuses := map[string]v1.LoadBalancerStatus{
+		"moved": {
+			Ingress: []v1.LoadBalancerIngress{
+				{IP: "10.0.0.2"},
+			},
+		},
+		"deleted": {},
+		"owned":   staleStatus,
+	}
+	for name, want := range wantStatuses {
+		svc, err := fakeResources.kclient.CoreV1().Services(v1.NamespaceDefault).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get service %s: %s", name, err)
+		}
+		if !reflect.DeepEqual(svc.Status.LoadBalancer, want) {
+			t.Errorf("got status %v for service %s, want %v", svc.Status.LoadBalancer, name, want)
+		}
+	}
+
+	close(recorder.Events)
+	var events []string
+	for event := range recorder.Events {
+		events = append(events, event)
+	}
+	if len(events) != 1 || !strings.Contains(events[0], eventReasonLBMissing) {
+		t.Errorf("got events %q, want one %s event", events, eventReasonLBMissing)
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	v1informers "k8s.io/client-go/informers/core/v1"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// lbDrainSyncPeriod is the interval at which draining nodes are
+	// removed from load-balancers.
+	lbDrainSyncPeriod = 5 * time.Second
+
+	lbDrainTimeout = 2 * time.Minute
+
+	// lbDrainFinalizer holds the deletion of draining nodes until their
+	// drain period elapsed.
+	lbDrainFinalizer = "kubernetes.digitalocean.com/load-balancer-drain"
+
+	// annDONodeDrainStartedAt is the node annotation recording when the
+	// node started draining from load-balancers.
+	annDONodeDrainStartedAt = "kubernetes.digitalocean.com/load-balancer-drain-started-at"
+)
+
+// isDrainingNode returns whether node is about to go away and should not
+// receive new load-balancer connections anymore: it is marked for deletion by
+// the cluster autoscaler or being deleted. Cordoned nodes keep serving.
+func isDrainingNode(node *v1.Node) bool {
+	return !node.DeletionTimestamp.IsZero() || hasTaint(node, toBeDeletedTaint)
+}
+
+// excludeDrainingNodes returns the nodes that are not draining if draining is
+// enabled. All nodes are returned if all of them are draining so that the
+// load-balancer does not lose all of its droplets.
+func (l *loadBalancers) excludeDrainingNodes(nodes []*v1.Node) []*v1.Node {
+	if l.drainPeriod <= 0 {
+		return nodes
+	}
+
+	var active []*v1.Node
+	for _, node := range nodes {
+		if !isDrainingNode(node) {
+			active = append(active, node)
+		}
+	}
+	if len(active) == 0 {
+		return nodes
+	}
+	return active
+}
+
+// lbDrainCoordinator removes the droplets of draining nodes from all managed
+// load-balancers ahead of the service controller, and holds the deletion of
+// draining nodes until the drain period elapsed. If draining is disabled, it
+// only releases nodes still held from an earlier configuration.
+type lbDrainCoordinator struct {
+	lbs        *loadBalancers
+	svcLister  v1lister.ServiceLister
+	nodeLister v1lister.NodeLister
+	now        func() time.Time
+}
+
+func newLBDrainCoordinator(lbs *loadBalancers, svcInf v1informers.ServiceInformer, nodeInf v1informers.NodeInformer) *lbDrainCoordinator {
+	return &lbDrainCoordinator{
+		lbs:        lbs,
+		svcLister:  svcInf.Lister(),
+		nodeLister: nodeInf.Lister(),
+		now:        time.Now,
+	}
+}
+
+// sync drains the draining nodes from the load-balancers and releases the
+// nodes whose drain completed.
+func (d *lbDrainCoordinator) sync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), lbDrainTimeout)
+	defer cancel()
+
+	nodes, err := d.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %s", err)
+	}
+
+	var (
+		draining []*v1.Node
+		errs     []error
+	)
+	for _, node := range nodes {
+		if d.lbs.drainPeriod > 0 && isDrainingNode(node) {
+			draining = append(draining, node)
+			continue
+		}
+		// Nodes that are not draining anymore, e.g. because the cluster
+		// autoscaler changed its mind or draining was disabled, are
+		// released.
+		if err := d.finishDrain(ctx, node, true); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(draining) == 0 {
+		lbNodesDraining.Set(0)
+		return utilerrors.NewAggregate(errs)
+	}
+
+	for i, node := range draining {
+		started, err := d.startDrain(ctx, node)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		draining[i] = started
+	}
+
+	dropletIDs, err := d.lbs.nodesToDropletIDs(ctx, draining)
+	if err != nil {
+		return fmt.Errorf("failed to get droplets of draining nodes: %s", err)
+	}
+	removeErr := d.removeFromLoadBalancers(ctx, dropletIDs, loadBalancerNodes(nodes))
+	if removeErr != nil {
+		errs = append(errs, removeErr)
+	}
+
+	var waiting int
+	for _, node := range draining {
+		startedAt, err := time.Parse(time.RFC3339, node.Annotations[annDONodeDrainStartedAt])
+		if err != nil || removeErr != nil || d.now().Sub(startedAt) < d.lbs.drainPeriod {
+			waiting++
+			continue
+		}
+		if err := d.finishDrain(ctx, node, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	lbNodesDraining.Set(float64(waiting))
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// startDrain records the start of the drain on node and adds the finalizer
+// holding its deletion. The updated node is returned.
+func (d *lbDrainCoordinator) startDrain(ctx context.Context, node *v1.Node) (*v1.Node, error) {
+	if _, ok := node.Annotations[annDONodeDrainStartedAt]; ok {
+		return node, nil
+	}
+	// Finalizers cannot be added to nodes that are being deleted already.
+	deleting := !node.DeletionTimestamp.IsZero()
+
+	updated := node.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[annDONodeDrainStartedAt] = d.now().UTC().Format(time.RFC3339)
+	if !deleting && !slices.Contains(updated.Finalizers, lbDrainFinalizer) {
+		updated.Finalizers = append(updated.Finalizers, lbDrainFinalizer)
+	}
+	updated, err := d.lbs.resources.kclient.CoreV1().Nodes().Update(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start drain of node %s: %s", node.Name, err)
+	}
+	klog.Infof("Draining node %s from load-balancers for %s", node.Name, d.lbs.drainPeriod)
+	return updated, nil
+}
+
+// finishDrain removes the finalizer from node so that its deletion may
+// proceed. If reset is set, the drain start is forgotten as well.
+func (d *lbDrainCoordinator) finishDrain(ctx context.Context, node *v1.Node, reset bool) error {
+	_, started := node.Annotations[annDONodeDrainStartedAt]
+	hasFinalizer := slices.Contains(node.Finalizers, lbDrainFinalizer)
+	if !hasFinalizer && (!reset || !started) {
+		return nil
+	}
+
+	updated := node.DeepCopy()
+	updated.Finalizers = slices.DeleteFunc(updated.Finalizers, func(f string) bool {
+		return f == lbDrainFinalizer
+	})
+	if reset {
+		delete(updated.Annotations, annDONodeDrainStartedAt)
+	}
+	if _, err := d.lbs.resources.kclient.CoreV1().Nodes().Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to finish drain of node %s: %s", node.Name, err)
+	}
+
+	if reset {
+		klog.Infof("Stopped draining node %s from load-balancers", node.Name)
+	} else {
+		klog.Infof("Drained node %s from load-balancers", node.Name)
+		lbNodeDrainsTotal.Inc()
+	}
+	return nil
+}
+
+// removeFromLoadBalancers updates all managed load-balancers targeting any of
+// dropletIDs. Load-balancers are rendered from nodes, which excludes draining
+// nodes.
+func (d *lbDrainCoordinator) removeFromLoadBalancers(ctx context.Context, dropletIDs []int, nodes []*v1.Node) error {
+	services, err := d.svcLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list services: %s", err)
+	}
+
+	var errs []error
+	groups := map[string]bool{}
+	for _, service := range services {
+		if service.Spec.Type != v1.ServiceTypeLoadBalancer || service.DeletionTimestamp != nil {
+			continue
+		}
+		if disowned, err := getDisownLB(service); err != nil || disowned {
+			continue
+		}
+		// Shared load-balancers are only updated once per group.
+		group := joinedSharingGroup(service)
+		if group != "" {
+			key := service.Namespace + "/" + group
+			if groups[key] {
+				continue
+			}
+			groups[key] = true
+		}
+
+		if err := d.removeFromLoadBalancer(ctx, service.DeepCopy(), group, dropletIDs, nodes); err != nil {
+			errs = append(errs, fmt.Errorf("failed to drain load-balancer of service %s/%s: %s", service.Namespace, service.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (d *lbDrainCoordinator) removeFromLoadBalancer(ctx context.Context, service *v1.Service, group string, dropletIDs []int, nodes []*v1.Node) (err error) {
+	lb, err := d.lbs.lookupLoadBalancer(ctx, service)
+	if err != nil {
+		if err == errLBNotFound {
+			return nil
+		}
+		return err
+	}
+	if !slices.ContainsFunc(lb.DropletIDs, func(id int) bool { return slices.Contains(dropletIDs, id) }) {
+		return nil
+	}
+	if lb.Status != lbStatusActive || d.lbs.resources.verifyLoadBalancerOwnership(service, lb) != nil {
+		return nil
+	}
+	adoptionMode, err := getAdoptionMode(service)
+	if err != nil || adoptionMode == lbAdoptionModeObserve {
+		return err
+	}
+
+	// Droplets leave load-balancers targeting a tag by losing the tag. The
+	// cluster tag is kept, so those load-balancers target draining nodes
+	// until they are gone.
+	if d.lbs.resources.isClusterTag(lb.Tag) {
+		return nil
+	}
+	if lb.Tag != "" {
+		var tagged []*godo.Droplet
+		for _, id := range dropletIDs {
+			droplet, err := d.lbs.resources.dropletByID(ctx, id)
+			if err != nil {
+				return fmt.Errorf("failed to get droplet %d: %s", id, err)
+			}
+			if slices.Contains(droplet.Tags, lb.Tag) {
+				tagged = append(tagged, droplet)
+			}
+		}
+		return d.lbs.untagDroplets(ctx, lb.Tag, tagged)
+	}
+
+	patcher := newServicePatcher(d.lbs.resources.kclient, service)
+	defer func() { err = patcher.Patch(ctx, err) }()
+
+	if group != "" {
+		_, err = d.lbs.ensureSharedLoadBalancer(ctx, service, group, nodes)
+		return err
+	}
+	_, err = d.lbs.updateLoadBalancer(ctx, lb, service, nodes)
+	return err
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	v1lister "k8s.io/client-go/listers/core/v1"
+)
+
+func Test_isDrainingNode(t *testing.T) {
+	now := metav1.Now()
+
+	tests := []struct {
+		name string
+		node *v1.Node
+		want bool
+	}{
+		{
+			name: "ready node",
+			node: &v1.Node{},
+		},
+		{
+			name: "cordoned node",
+			node: &v1.Node{
+				Spec: v1.NodeSpec{Unschedulable: true},
+			},
+		},
+		{
+			name: "node to be deleted by the cluster autoscaler",
+			node: &v1.Node{
+				Spec: v1.NodeSpec{
+					Taints: []v1.Taint{{Key: toBeDeletedTaint, Effect: v1.TaintEffectNoSchedule}},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "node being deleted",
+			node: &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &now},
+			},
+			want: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isDrainingNode(test.node); got != test.want {
+				t.Errorf("got draining %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_excludeDrainingNodes(t *testing.T) {
+	ready := newNodeSelectorTestNode("node-1", "digitalocean://100", nil)
+	draining := newNodeSelectorTestNode("node-2", "digitalocean://101", nil)
+	draining.Spec.Taints = []v1.Taint{{Key: toBeDeletedTaint, Effect: v1.TaintEffectNoSchedule}}
+
+	tests := []struct {
+		name        string
+		drainPeriod time.Duration
+		nodes       []*v1.Node
+		want        []*v1.Node
+	}{
+		{
+			name:  "draining disabled",
+			nodes: []*v1.Node{ready, draining},
+			want:  []*v1.Node{ready, draining},
+		},
+		{
+			name:        "draining node excluded",
+			drainPeriod: time.Minute,
+			nodes:       []*v1.Node{ready, draining},
+			want:        []*v1.Node{ready},
+		},
+		{
+			name:        "all nodes draining",
+			drainPeriod: time.Minute,
+			nodes:       []*v1.Node{draining},
+			want:        []*v1.Node{draining},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lbs := &loadBalancers{drainPeriod: test.drainPeriod}
+			if got := lbs.excludeDrainingNodes(test.nodes); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got nodes %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLBDrainCoordinator(t *testing.T) {
+	const drainPeriod = time.Minute
+
+	service := newTestService(map[string]string{
+		annDOLoadBalancerID: "load-balancer-id",
+	})
+	ready := newNodeSelectorTestNode("node-1", "digitalocean://100", nil)
+	draining := newNodeSelectorTestNode("node-2", "digitalocean://101", nil)
+	draining.Spec.Taints = []v1.Taint{{Key: toBeDeletedTaint, Effect: v1.TaintEffectNoSchedule}}
+	nodes := []*v1.Node{ready, draining}
+
+	var updates []*godo.LoadBalancerRequest
+	live := &godo.LoadBalancer{}
+	fakeLB := &fakeLBService{
+		listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+			return []godo.LoadBalancer{*live}, newFakeOKResponse(), nil
+		},
+		updateFn: func(_ context.Context, _ string, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+			updates = append(updates, lbr)
+			live = newLiveLoadBalancer(lbr)
+			live.ID = "load-balancer-id"
+			return live, newFakeOKResponse(), nil
+		},
+	}
+	fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(&fakeDropletService{}, fakeLB, nil))
+	kclient := fake.NewSimpleClientset(service, ready, draining)
+	fakeResources.kclient = kclient
+	lbs := &loadBalancers{
+		resources:         fakeResources,
+		region:            "nyc3",
+		lbActiveTimeout:   2,
+		lbActiveCheckTick: 1,
+	}
+
+	// Start with a load-balancer targeting all nodes.
+	req, err := lbs.buildLoadBalancerRequest(context.Background(), service, nodes)
+	if err != nil {
+		t.Fatalf("failed to build load-balancer request: %s", err)
+	}
+	live = newLiveLoadBalancer(req)
+	live.ID = "load-balancer-id"
+	lbs.drainPeriod = drainPeriod
+
+	sharedInformer := informers.NewSharedInformerFactory(kclient, 0)
+	d := newLBDrainCoordinator(lbs, sharedInformer.Core().V1().Services(), sharedInformer.Core().V1().Nodes())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return start }
+	sharedInformer.Start(nil)
+	sharedInformer.WaitForCacheSync(nil)
+
+	// The draining node is removed from the load-balancer and its deletion
+	// is held.
+	if err := d.sync(); err != nil {
+		t.Fatalf("failed to sync: %s", err)
+	}
+	if len(updates) != 1 {
+		t.Fatalf("got %d update(s), want 1", len(updates))
+	}
+	if want := []int{100}; !reflect.DeepEqual(updates[0].DropletIDs, want) {
+		t.Errorf("got droplet IDs %v, want %v", updates[0].DropletIDs, want)
+	}
+	node := waitForNode(t, d.nodeLister, "node-2", func(node *v1.Node) bool {
+		return slices.Contains(node.Finalizers, lbDrainFinalizer)
+	})
+	if got, want := node.Annotations[annDONodeDrainStartedAt], start.Format(time.RFC3339); got != want {
+		t.Errorf("got drain start %q, want %q", got, want)
+	}
+
+	// The finalizer is kept until the drain period elapsed.
+	d.now = func() time.Time { return start.Add(drainPeriod / 2) }
+	if err := d.sync(); err != nil {
+		t.Fatalf("failed to sync: %s", err)
+	}
+	if len(updates) != 1 {
+		t.Errorf("got %d update(s) after draining the node, want 1", len(updates))
+	}
+	node, err = kclient.CoreV1().Nodes().Get(context.Background(), "node-2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %s", err)
+	}
+	if !slices.Contains(node.Finalizers, lbDrainFinalizer) {
+		t.Error("finalizer was removed before the drain period elapsed")
+	}
+
+	// The finalizer is removed once the drain period elapsed.
+	d.now = func() time.Time { return start.Add(drainPeriod) }
+	if err := d.sync(); err != nil {
+		t.Fatalf("failed to sync: %s", err)
+	}
+	waitForNode(t, d.nodeLister, "node-2", func(node *v1.Node) bool {
+		return !slices.Contains(node.Finalizers, lbDrainFinalizer)
+	})
+}
+
+func TestLBDrainCoordinator_releasedNode(t *testing.T) {
+	now := metav1.Now()
+
+	tests := []struct {
+		name        string
+		drainPeriod time.Duration
+		deleting    bool
+	}{
+		{
+			name:        "node not draining anymore",
+			drainPeriod: time.Minute,
+		},
+		{
+			name:     "draining disabled",
+			deleting: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			node := newNodeSelectorTestNode("node-1", "digitalocean://100", nil)
+			node.Finalizers = []string{lbDrainFinalizer}
+			node.Annotations = map[string]string{
+				annDONodeDrainStartedAt: "2024-01-01T00:00:00Z",
+			}
+			if test.deleting {
+				node.DeletionTimestamp = &now
+			}
+
+			kclient := fake.NewSimpleClientset(node)
+			fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(&fakeDropletService{}, &fakeLBService{}, nil))
+			fakeResources.kclient = kclient
+			lbs := &loadBalancers{
+				resources:   fakeResources,
+				region:      "nyc3",
+				drainPeriod: test.drainPeriod,
+			}
+
+			sharedInformer := informers.NewSharedInformerFactory(kclient, 0)
+			d := newLBDrainCoordinator(lbs, sharedInformer.Core().V1().Services(), sharedInformer.Core().V1().Nodes())
+			sharedInformer.Start(nil)
+			sharedInformer.WaitForCacheSync(nil)
+
+			if err := d.sync(); err != nil {
+				t.Fatalf("failed to sync: %s", err)
+			}
+			got := waitForNode(t, d.nodeLister, "node-1", func(node *v1.Node) bool {
+				return len(node.Finalizers) == 0
+			})
+			if _, ok := got.Annotations[annDONodeDrainStartedAt]; ok {
+				t.Error("drain start annotation was not removed")
+			}
+		})
+	}
+}
+
+func waitForNode(t *testing.T, lister v1lister.NodeLister, name string, cond func(*v1.Node) bool) *v1.Node {
+	t.Helper()
+	var node *v1.Node
+	err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		var err error
+		node, err = lister.Get(name)
+		if err != nil {
+			return false, nil
+		}
+		return cond(node), nil
+	})
+	if err != nil {
+		t.Fatalf("node %s did not reach the expected state", name)
+	}
+	return node
+}
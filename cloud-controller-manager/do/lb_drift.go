@@ -0,0 +1,238 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	v1informers "k8s.io/client-go/informers/core/v1"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultLBDriftDetectionInterval is the default interval at which
+	// load-balancers are checked for drift. Drift detection is disabled by
+	// default.
+	defaultLBDriftDetectionInterval time.Duration = 0
+
+	lbDriftDetectionTimeout = 5 * time.Minute
+
+	// lbDriftEventMaxDiffLength limits the length of the diff included in
+	// drift events.
+	lbDriftEventMaxDiffLength = 1024
+
+	// toBeDeletedTaint is the taint the cluster autoscaler adds to nodes it
+	// is about to delete. The service controller removes such nodes from
+	// load-balancers.
+	toBeDeletedTaint = "ToBeDeletedByClusterAutoscaler"
+)
+
+// lbDriftDetector periodically compares the load-balancers of all Services
+// with the configuration rendered from the Services in order to detect
+// changes made outside of the cloud controller manager.
+type lbDriftDetector struct {
+	lbs         *loadBalancers
+	svcLister   v1lister.ServiceLister
+	nodeLister  v1lister.NodeLister
+	autoCorrect bool
+
+	// reported holds the Services a drift gauge was reported for.
+	reported map[types.NamespacedName]bool
+}
+
+func newLBDriftDetector(lbs *loadBalancers, svcInf v1informers.ServiceInformer, nodeInf v1informers.NodeInformer, autoCorrect bool) *lbDriftDetector {
+	return &lbDriftDetector{
+		lbs:         lbs,
+		svcLister:   svcInf.Lister(),
+		nodeLister:  nodeInf.Lister(),
+		autoCorrect: autoCorrect,
+		reported:    map[types.NamespacedName]bool{},
+	}
+}
+
+// detect checks the load-balancers of all Services for drift.
+func (d *lbDriftDetector) detect() error {
+	ctx, cancel := context.WithTimeout(context.Background(), lbDriftDetectionTimeout)
+	defer cancel()
+
+	services, err := d.svcLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list services: %s", err)
+	}
+	nodes, err := d.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %s", err)
+	}
+	nodes = loadBalancerNodes(nodes)
+
+	var errs []error
+	seen := map[types.NamespacedName]bool{}
+	for _, service := range services {
+		if service.Spec.Type != v1.ServiceTypeLoadBalancer || service.DeletionTimestamp != nil {
+			continue
+		}
+		disowned, err := getDisownLB(service)
+		if err != nil || disowned {
+			continue
+		}
+		// Shared load-balancers are rendered from all members of their
+		// group, not from a single Service.
+		if joinedSharingGroup(service) != "" {
+			continue
+		}
+
+		key := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+		seen[key] = true
+
+		drifted, err := d.detectService(ctx, service, nodes)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to detect drift of load-balancer for service %s: %s", key, err))
+			continue
+		}
+
+		var value float64
+		if drifted {
+			value = 1
+		}
+		lbDrift.WithLabelValues(service.Namespace, service.Name).Set(value)
+		d.reported[key] = true
+	}
+
+	for key := range d.reported {
+		if !seen[key] {
+			lbDrift.DeleteLabelValues(key.Namespace, key.Name)
+			delete(d.reported, key)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// detectService returns whether the load-balancer of service has drifted. If
+// auto-correction is enabled, drifted load-balancers are updated and not
+// reported as drifted anymore.
+func (d *lbDriftDetector) detectService(ctx context.Context, service *v1.Service, nodes []*v1.Node) (bool, error) {
+	// Objects returned by the lister must not be modified.
+	svc := service.DeepCopy()
+
+	// Load-balancers are looked up in the inventory so that checking all of
+	// them does not get each one from the API.
+	lb, err := d.lbs.lookupLoadBalancer(ctx, svc)
+	if err != nil {
+		if err == errLBNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	// Load-balancers that are not active yet (or anymore) are handled by the
+	// service controller, and those of other clusters are none of our
+	// business.
+	if lb.Status != lbStatusActive || d.lbs.resources.verifyLoadBalancerOwnership(svc, lb) != nil {
+		return false, nil
+	}
+
+	// Observed load-balancers are never corrected, so they cannot drift.
+	adoptionMode, err := getAdoptionMode(svc)
+	if err != nil || adoptionMode == lbAdoptionModeObserve {
+		return false, err
+	}
+
+	// Detection must not change anything, e.g., tag droplets, so only
+	// auto-correction goes through the regular update.
+	lbRequest, err := d.lbs.desiredLoadBalancerRequest(ctx, svc, nodes)
+	if err != nil {
+		return false, fmt.Errorf("failed to build load-balancer request: %s", err)
+	}
+	if adoptionMode == lbAdoptionModeMerge {
+		mergeUnmanagedFields(lb, lbRequest)
+	}
+	// Pending replacements are not drift.
+	preserveImmutableFields(lb, lbRequest)
+
+	equal, diff, fields := loadBalancerRequestDiff(lb, lbRequest)
+	if equal {
+		return false, nil
+	}
+
+	for _, field := range fields {
+		lbDriftFieldsTotal.WithLabelValues(field).Inc()
+	}
+	klog.Warningf("Detected drift of load-balancer %s for service %s/%s in field(s) %s\ndiff (-live +desired):\n%s", lb.ID, service.Namespace, service.Name, strings.Join(fields, ", "), diff)
+	d.lbs.recordEvent(service, v1.EventTypeWarning, eventReasonLBDrift, "Load-balancer %s deviates from the Service in field(s) %s\ndiff (-live +desired):\n%s", lb.ID, strings.Join(fields, ", "), truncateDiff(diff))
+
+	if !d.autoCorrect {
+		return true, nil
+	}
+	if err := d.correct(ctx, svc, lb, nodes); err != nil {
+		return true, fmt.Errorf("failed to correct drift: %s", err)
+	}
+	klog.Infof("Corrected drift of load-balancer %s for service %s/%s", lb.ID, service.Namespace, service.Name)
+	return false, nil
+}
+
+// correct updates lb to match the configuration rendered from service.
+func (d *lbDriftDetector) correct(ctx context.Context, service *v1.Service, lb *godo.LoadBalancer, nodes []*v1.Node) (err error) {
+	patcher := newServicePatcher(d.lbs.resources.kclient, service)
+	defer func() { err = patcher.Patch(ctx, err) }()
+
+	_, err = d.lbs.updateLoadBalancer(ctx, lb, service, nodes)
+	return err
+}
+
+// loadBalancerNodes returns the nodes the service controller balances
+// traffic across.
+func loadBalancerNodes(nodes []*v1.Node) []*v1.Node {
+	var lbNodes []*v1.Node
+	for _, node := range nodes {
+		if !node.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if _, ok := node.Labels[v1.LabelNodeExcludeBalancers]; ok {
+			continue
+		}
+		if hasTaint(node, toBeDeletedTaint) {
+			continue
+		}
+		lbNodes = append(lbNodes, node)
+	}
+	return lbNodes
+}
+
+func hasTaint(node *v1.Node, key string) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateDiff(diff string) string {
+	if len(diff) <= lbDriftEventMaxDiffLength {
+		return diff
+	}
+	return diff[:lbDriftEventMaxDiffLength] + "\n[truncated]"
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_loadBalancerRequestDiffFields(t *testing.T) {
+	req := newDiffTestLoadBalancerRequest()
+	lb := newLiveLoadBalancer(req)
+	lb.Algorithm = "least_connections"
+	lb.HealthCheck.CheckIntervalSeconds = 30
+	lb.DropletIDs = []int{100}
+
+	equal, _, fields := loadBalancerRequestDiff(lb, req)
+	if equal {
+		t.Fatal("got equal, want difference")
+	}
+	want := []string{"Algorithm", "DropletIDs", "HealthCheck"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got fields %v, want %v", fields, want)
+	}
+}
+
+func Test_loadBalancerNodes(t *testing.T) {
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "regular"}},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "excluded",
+				Labels: map[string]string{v1.LabelNodeExcludeBalancers: ""},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "scaled-down"},
+			Spec: v1.NodeSpec{
+				Taints: []v1.Taint{{Key: toBeDeletedTaint, Effect: v1.TaintEffectNoSchedule}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "deleted",
+				DeletionTimestamp: &metav1.Time{Time: time.Now()},
+			},
+		},
+	}
+
+	got := loadBalancerNodes(nodes)
+	if len(got) != 1 || got[0].Name != "regular" {
+		t.Errorf("got nodes %v, want only node regular", got)
+	}
+}
+
+func TestLBDriftDetector_detect(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		mutateLB    func(*godo.LoadBalancer)
+		autoCorrect bool
+		wantDrift   float64
+		wantEvent   string
+		wantUpdates int
+	}{
+		{
+			name:      "no drift",
+			wantDrift: 0,
+		},
+		{
+			name: "backend tag not applied",
+			annotations: map[string]string{
+				annDOBackendTag: "web",
+			},
+			wantDrift: 0,
+		},
+		{
+			name: "drift",
+			mutateLB: func(lb *godo.LoadBalancer) {
+				lb.Algorithm = "least_connections"
+			},
+			wantDrift: 1,
+			wantEvent: "Warning LoadBalancerDrift Load-balancer load-balancer-id deviates from the Service in field(s) Algorithm",
+		},
+		{
+			name: "drift corrected",
+			mutateLB: func(lb *godo.LoadBalancer) {
+				lb.Algorithm = "least_connections"
+			},
+			autoCorrect: true,
+			wantDrift:   0,
+			wantEvent:   "Warning LoadBalancerDrift",
+			wantUpdates: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: v1.NamespaceDefault,
+					UID:       "foobar123",
+					Annotations: map[string]string{
+						annDOLoadBalancerID: "load-balancer-id",
+					},
+				},
+				Spec: v1.ServiceSpec{
+					Type: v1.ServiceTypeLoadBalancer,
+					Ports: []v1.ServicePort{
+						{
+							Name:     "test",
+							Protocol: v1.ProtocolTCP,
+							Port:     80,
+							NodePort: 30000,
+						},
+					},
+				},
+			}
+			for key, value := range test.annotations {
+				service.Annotations[key] = value
+			}
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			}
+
+			var live *godo.LoadBalancer
+			var updates int
+			fakeDroplet := &fakeDropletService{
+				listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+					return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+				},
+			}
+			// Load-balancers are only read from the inventory.
+			fakeLB := &fakeLBService{
+				listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+					return []godo.LoadBalancer{*live}, newFakeOKResponse(), nil
+				},
+				updateFn: func(_ context.Context, _ string, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+					updates++
+					live = newLiveLoadBalancer(lbr)
+					return live, newFakeOKResponse(), nil
+				},
+			}
+			gclient := newFakeClient(fakeDroplet, fakeLB, nil)
+			fakeTags := newFakeTagsService()
+			gclient.Tags = fakeTags
+			fakeResources := newResources("", "", publicAccessFirewall{}, gclient)
+			kclient := fake.NewSimpleClientset(service, node)
+			fakeResources.kclient = kclient
+
+			recorder := record.NewFakeRecorder(10)
+			lbs := &loadBalancers{
+				resources:         fakeResources,
+				region:            "nyc3",
+				lbActiveTimeout:   2,
+				lbActiveCheckTick: 1,
+				recorder:          recorder,
+			}
+
+			req, err := lbs.desiredLoadBalancerRequest(context.Background(), service, []*v1.Node{node})
+			if err != nil {
+				t.Fatalf("failed to build load-balancer request: %s", err)
+			}
+			live = newLiveLoadBalancer(req)
+			if test.mutateLB != nil {
+				test.mutateLB(live)
+			}
+
+			sharedInformer := informers.NewSharedInformerFactory(kclient, 0)
+			detector := newLBDriftDetector(lbs, sharedInformer.Core().V1().Services(), sharedInformer.Core().V1().Nodes(), test.autoCorrect)
+			sharedInformer.Start(nil)
+			sharedInformer.WaitForCacheSync(nil)
+
+			if err := detector.detect(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := testutil.ToFloat64(lbDrift.WithLabelValues(service.Namespace, service.Name)); got != test.wantDrift {
+				t.Errorf("got drift %v, want %v", got, test.wantDrift)
+			}
+			if updates != test.wantUpdates {
+				t.Errorf("got %d update(s), want %d", updates, test.wantUpdates)
+			}
+			if len(fakeTags.tagRequests) != 0 {
+				t.Errorf("got %d tag request(s) during detection, want 0", len(fakeTags.tagRequests))
+			}
+
+			close(recorder.Events)
+			var events []string
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			switch {
+			case test.wantEvent == "" && len(events) > 0:
+				t.Errorf("got events %q, want none", events)
+			case test.wantEvent != "" && (len(events) == 0 || !strings.HasPrefix(events[0], test.wantEvent)):
+				t.Errorf("got events %q, want first event with prefix %q", events, test.wantEvent)
+			}
+		})
+	}
+}
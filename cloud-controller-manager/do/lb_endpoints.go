@@ -0,0 +1,269 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	v1informers "k8s.io/client-go/informers/core/v1"
+	discoveryinformers "k8s.io/client-go/informers/discovery/v1"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// lbEndpointsDebouncePeriod is the time EndpointSlice changes of a
+	// Service are collected for before its load-balancer is updated.
+	lbEndpointsDebouncePeriod = 5 * time.Second
+
+	lbEndpointsSyncTimeout = 2 * time.Minute
+)
+
+// getEndpointAwareBackends returns whether the load-balancer of service only
+// targets the nodes hosting ready endpoints of service.
+func getEndpointAwareBackends(service *v1.Service) (bool, error) {
+	enabled, _, err := getBool(service.Annotations, annDOEndpointAwareBackends)
+	if err != nil {
+		return false, fmt.Errorf("failed to get endpoint-aware backends configuration setting: %w", err)
+	}
+	return enabled, nil
+}
+
+// validateEndpointAwareBackends checks that endpoint-aware backends are only
+// enabled for Services that route external traffic to node-local endpoints
+// and have a load-balancer of their own that targets droplet IDs.
+func validateEndpointAwareBackends(service *v1.Service, backendTag string) error {
+	enabled, err := getEndpointAwareBackends(service)
+	if err != nil || !enabled {
+		return err
+	}
+	if service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyLocal {
+		return invalidAnnotation(annDOEndpointAwareBackends, "annotation %q requires external traffic policy %s", annDOEndpointAwareBackends, v1.ServiceExternalTrafficPolicyLocal)
+	}
+	if backendTag != "" {
+		return invalidAnnotation(annDOEndpointAwareBackends, "annotation %q cannot be combined with annotation %q", annDOEndpointAwareBackends, annDOBackendTag)
+	}
+	// Shared load-balancers target the nodes of all members of their group.
+	if group, _ := getSharingGroup(service); group != "" {
+		return invalidAnnotation(annDOEndpointAwareBackends, "annotation %q cannot be combined with annotation %q", annDOEndpointAwareBackends, annDOSharingGroup)
+	}
+	return nil
+}
+
+// readyEndpointNodeNames returns the names of the nodes hosting ready
+// endpoints of service.
+func readyEndpointNodeNames(lister discoverylisters.EndpointSliceLister, service *v1.Service) (map[string]bool, error) {
+	endpointSlices, err := lister.EndpointSlices(service.Namespace).List(labels.SelectorFromSet(labels.Set{
+		discoveryv1.LabelServiceName: service.Name,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, slice := range endpointSlices {
+		for _, endpoint := range slice.Endpoints {
+			// A missing ready condition means ready.
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			if endpoint.NodeName != nil {
+				names[*endpoint.NodeName] = true
+			}
+		}
+	}
+	return names, nil
+}
+
+// endpointNodes returns the nodes among nodes hosting ready endpoints of
+// service if service has endpoint-aware backends enabled. All nodes are
+// returned if none of them hosts a ready endpoint so that the load-balancer
+// does not lose all of its droplets, e.g., during a rollout.
+func (l *loadBalancers) endpointNodes(service *v1.Service, nodes []*v1.Node) []*v1.Node {
+	if l.endpointSliceLister == nil || service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyLocal {
+		return nodes
+	}
+	enabled, err := getEndpointAwareBackends(service)
+	if err != nil || !enabled {
+		return nodes
+	}
+
+	names, err := readyEndpointNodeNames(l.endpointSliceLister, service)
+	if err != nil {
+		klog.Warningf("Failed to list endpoint slices of service %s/%s, targeting all nodes: %s", service.Namespace, service.Name, err)
+		return nodes
+	}
+
+	var hosting []*v1.Node
+	for _, node := range nodes {
+		if names[node.Name] {
+			hosting = append(hosting, node)
+		}
+	}
+	if len(hosting) == 0 {
+		klog.V(2).Infof("No node hosts ready endpoints of service %s/%s, targeting all nodes", service.Namespace, service.Name)
+		return nodes
+	}
+	return hosting
+}
+
+// lbEndpointsController updates the load-balancers of Services with
+// endpoint-aware backends when their EndpointSlices change. Changes are
+// debounced so that pod churn results in few load-balancer updates.
+type lbEndpointsController struct {
+	lbs        *loadBalancers
+	svcLister  v1lister.ServiceLister
+	nodeLister v1lister.NodeLister
+	queue      workqueue.RateLimitingInterface
+	debounce   time.Duration
+}
+
+func newLBEndpointsController(lbs *loadBalancers, svcInf v1informers.ServiceInformer, nodeInf v1informers.NodeInformer, epInf discoveryinformers.EndpointSliceInformer) *lbEndpointsController {
+	c := &lbEndpointsController{
+		lbs:        lbs,
+		svcLister:  svcInf.Lister(),
+		nodeLister: nodeInf.Lister(),
+		queue:      workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "lb-endpoints"),
+		debounce:   lbEndpointsDebouncePeriod,
+	}
+
+	epInf.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueue,
+		UpdateFunc: func(old, cur interface{}) {
+			c.enqueue(cur)
+		},
+		DeleteFunc: c.enqueue,
+	})
+	lbs.endpointSliceLister = epInf.Lister()
+
+	return c
+}
+
+// enqueue schedules a sync of the Service owning the given EndpointSlice.
+// Further changes before the sync do not postpone it.
+func (c *lbEndpointsController) enqueue(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	slice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return
+	}
+	name := slice.Labels[discoveryv1.LabelServiceName]
+	if name == "" {
+		return
+	}
+	c.queue.AddAfter(slice.Namespace+"/"+name, c.debounce)
+}
+
+// Run processes the queue until stopCh is closed.
+func (c *lbEndpointsController) Run(stopCh <-chan struct{}) {
+	defer c.queue.ShutDown()
+	go wait.Until(c.runWorker, time.Second, stopCh)
+	<-stopCh
+}
+
+func (c *lbEndpointsController) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *lbEndpointsController) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), lbEndpointsSyncTimeout)
+	defer cancel()
+	if err := c.sync(ctx, key.(string)); err != nil {
+		klog.Errorf("Failed to sync load-balancer backends of service %s with its endpoints: %s", key, err)
+		c.queue.AddRateLimited(key)
+	} else {
+		c.queue.Forget(key)
+	}
+	return true
+}
+
+// sync updates the load-balancer of the Service identified by key if its
+// endpoint-aware backends changed.
+func (c *lbEndpointsController) sync(ctx context.Context, key string) (err error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	service, err := c.svcLister.Services(namespace).Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || service.DeletionTimestamp != nil || service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyLocal {
+		return nil
+	}
+	enabled, err := getEndpointAwareBackends(service)
+	if err != nil || !enabled {
+		return nil
+	}
+	// Disowned load-balancers are not updated. Building the load-balancer
+	// request rejects endpoint-aware backends for members of a sharing
+	// group, which may still be joined to the group they just left.
+	if disowned, err := getDisownLB(service); err != nil || disowned {
+		return nil
+	}
+	if joinedSharingGroup(service) != "" {
+		return nil
+	}
+
+	// Objects returned by the lister must not be modified.
+	svc := service.DeepCopy()
+	lb, err := c.lbs.lookupLoadBalancer(ctx, svc)
+	if err != nil {
+		if err == errLBNotFound {
+			return nil
+		}
+		return err
+	}
+	// Load-balancers that are not active are left to the service
+	// controller.
+	if lb.Status != lbStatusActive || c.lbs.resources.verifyLoadBalancerOwnership(svc, lb) != nil {
+		return nil
+	}
+
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %s", err)
+	}
+
+	patcher := newServicePatcher(c.lbs.resources.kclient, svc)
+	defer func() { err = patcher.Patch(ctx, err) }()
+
+	_, err = c.lbs.updateLoadBalancer(ctx, lb, svc, loadBalancerNodes(nodes))
+	return err
+}
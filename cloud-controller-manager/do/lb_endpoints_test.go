@@ -0,0 +1,299 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func newTestEndpointSlice(name string, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: v1.NamespaceDefault,
+			Labels: map[string]string{
+				discoveryv1.LabelServiceName: "test",
+			},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   endpoints,
+	}
+}
+
+func newTestEndpoint(nodeName string, ready *bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Addresses:  []string{"10.244.0.1"},
+		NodeName:   ptr.To(nodeName),
+		Conditions: discoveryv1.EndpointConditions{Ready: ready},
+	}
+}
+
+func newEndpointTestNodes() []*v1.Node {
+	return []*v1.Node{
+		newNodeSelectorTestNode("node-1", "digitalocean://100", nil),
+		newNodeSelectorTestNode("node-2", "digitalocean://101", nil),
+		newNodeSelectorTestNode("node-3", "digitalocean://102", nil),
+	}
+}
+
+func Test_validateEndpointAwareBackends(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      v1.ServiceExternalTrafficPolicy
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{
+			name:   "not enabled",
+			policy: v1.ServiceExternalTrafficPolicyCluster,
+		},
+		{
+			name:   "enabled with policy Local",
+			policy: v1.ServiceExternalTrafficPolicyLocal,
+			annotations: map[string]string{
+				annDOEndpointAwareBackends: "true",
+			},
+		},
+		{
+			name:   "enabled with policy Cluster",
+			policy: v1.ServiceExternalTrafficPolicyCluster,
+			annotations: map[string]string{
+				annDOEndpointAwareBackends: "true",
+			},
+			wantErr: true,
+		},
+		{
+			name:   "enabled with backend tag",
+			policy: v1.ServiceExternalTrafficPolicyLocal,
+			annotations: map[string]string{
+				annDOEndpointAwareBackends: "true",
+				annDOBackendTag:            "web",
+			},
+			wantErr: true,
+		},
+		{
+			name:   "enabled with sharing group",
+			policy: v1.ServiceExternalTrafficPolicyLocal,
+			annotations: map[string]string{
+				annDOEndpointAwareBackends: "true",
+				annDOSharingGroup:          "web",
+			},
+			wantErr: true,
+		},
+		{
+			name:   "invalid value",
+			policy: v1.ServiceExternalTrafficPolicyLocal,
+			annotations: map[string]string{
+				annDOEndpointAwareBackends: "sometimes",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := newTestService(test.annotations)
+			service.Spec.ExternalTrafficPolicy = test.policy
+			err := validateEndpointAwareBackends(service, service.Annotations[annDOBackendTag])
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func Test_buildLoadBalancerRequest_endpointAwareBackends(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         v1.ServiceExternalTrafficPolicy
+		enabled        bool
+		slices         []*discoveryv1.EndpointSlice
+		wantDropletIDs []int
+	}{
+		{
+			name:    "ready endpoints",
+			policy:  v1.ServiceExternalTrafficPolicyLocal,
+			enabled: true,
+			slices: []*discoveryv1.EndpointSlice{
+				newTestEndpointSlice("test-a", newTestEndpoint("node-1", nil), newTestEndpoint("node-2", ptr.To(false))),
+				newTestEndpointSlice("test-b", newTestEndpoint("node-3", ptr.To(true))),
+			},
+			wantDropletIDs: []int{100, 102},
+		},
+		{
+			name:    "no ready endpoints",
+			policy:  v1.ServiceExternalTrafficPolicyLocal,
+			enabled: true,
+			slices: []*discoveryv1.EndpointSlice{
+				newTestEndpointSlice("test-a", newTestEndpoint("node-1", ptr.To(false))),
+			},
+			wantDropletIDs: []int{100, 101, 102},
+		},
+		{
+			name:   "not enabled",
+			policy: v1.ServiceExternalTrafficPolicyLocal,
+			slices: []*discoveryv1.EndpointSlice{
+				newTestEndpointSlice("test-a", newTestEndpoint("node-1", nil)),
+			},
+			wantDropletIDs: []int{100, 101, 102},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if test.enabled {
+				annotations[annDOEndpointAwareBackends] = "true"
+			}
+			service := newTestService(annotations)
+			service.Spec.ExternalTrafficPolicy = test.policy
+
+			var objs []runtime.Object
+			for _, slice := range test.slices {
+				objs = append(objs, slice)
+			}
+			kclient := fake.NewSimpleClientset(objs...)
+			sharedInformer := informers.NewSharedInformerFactory(kclient, 0)
+			epInformer := sharedInformer.Discovery().V1().EndpointSlices()
+			epInformer.Informer()
+			sharedInformer.Start(nil)
+			sharedInformer.WaitForCacheSync(nil)
+
+			fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(&fakeDropletService{}, &fakeLBService{}, nil))
+			lbs := &loadBalancers{
+				resources:           fakeResources,
+				region:              "nyc3",
+				lbActiveTimeout:     2,
+				lbActiveCheckTick:   1,
+				endpointSliceLister: epInformer.Lister(),
+			}
+
+			req, err := lbs.buildLoadBalancerRequest(context.Background(), service, newEndpointTestNodes())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(req.DropletIDs, test.wantDropletIDs) {
+				t.Errorf("got droplet IDs %v, want %v", req.DropletIDs, test.wantDropletIDs)
+			}
+		})
+	}
+}
+
+func TestLBEndpointsController(t *testing.T) {
+	service := newTestService(map[string]string{
+		annDOLoadBalancerID:        "load-balancer-id",
+		annDOEndpointAwareBackends: "true",
+	})
+	service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyLocal
+	slice := newTestEndpointSlice("test-a", newTestEndpoint("node-1", nil), newTestEndpoint("node-3", nil))
+	objs := []runtime.Object{service, slice}
+	for _, node := range newEndpointTestNodes() {
+		objs = append(objs, node)
+	}
+
+	var updates []*godo.LoadBalancerRequest
+	live := &godo.LoadBalancer{}
+	fakeLB := &fakeLBService{
+		// Load-balancers are looked up from the inventory rather than
+		// retrieved one by one.
+		listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+			return []godo.LoadBalancer{*live}, newFakeOKResponse(), nil
+		},
+		updateFn: func(_ context.Context, _ string, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+			updates = append(updates, lbr)
+			live = newLiveLoadBalancer(lbr)
+			return live, newFakeOKResponse(), nil
+		},
+	}
+	fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(&fakeDropletService{}, fakeLB, nil))
+	kclient := fake.NewSimpleClientset(objs...)
+	fakeResources.kclient = kclient
+	lbs := &loadBalancers{
+		resources:         fakeResources,
+		region:            "nyc3",
+		lbActiveTimeout:   2,
+		lbActiveCheckTick: 1,
+	}
+
+	// Start with a load-balancer targeting all nodes.
+	req, err := lbs.buildLoadBalancerRequest(context.Background(), service, newEndpointTestNodes())
+	if err != nil {
+		t.Fatalf("failed to build load-balancer request: %s", err)
+	}
+	live = newLiveLoadBalancer(req)
+	live.ID = "load-balancer-id"
+
+	sharedInformer := informers.NewSharedInformerFactory(kclient, 0)
+	c := newLBEndpointsController(lbs, sharedInformer.Core().V1().Services(), sharedInformer.Core().V1().Nodes(), sharedInformer.Discovery().V1().EndpointSlices())
+	c.debounce = 50 * time.Millisecond
+	sharedInformer.Start(nil)
+	sharedInformer.WaitForCacheSync(nil)
+
+	// The initial EndpointSlice is synced once the debounce period elapsed.
+	waitForQueuedItem(t, c)
+	c.processNextItem()
+	if len(updates) != 1 {
+		t.Fatalf("got %d update(s), want 1", len(updates))
+	}
+	// Nodes are listed in no particular order.
+	slices.Sort(updates[0].DropletIDs)
+	if want := []int{100, 102}; !reflect.DeepEqual(updates[0].DropletIDs, want) {
+		t.Errorf("got droplet IDs %v, want %v", updates[0].DropletIDs, want)
+	}
+
+	// Repeated changes within the debounce period result in a single sync.
+	c.debounce = time.Second
+	c.enqueue(slice)
+	c.enqueue(slice)
+	if got := c.queue.Len(); got != 0 {
+		t.Fatalf("got %d queued item(s) before the debounce period elapsed, want 0", got)
+	}
+	waitForQueuedItem(t, c)
+	if got := c.queue.Len(); got != 1 {
+		t.Fatalf("got %d queued item(s) after the debounce period elapsed, want 1", got)
+	}
+
+	// Syncing unchanged endpoints does not update the load-balancer.
+	c.processNextItem()
+	if len(updates) != 1 {
+		t.Errorf("got %d update(s) after syncing unchanged endpoints, want 1", len(updates))
+	}
+}
+
+func waitForQueuedItem(t *testing.T, c *lbEndpointsController) {
+	t.Helper()
+	err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		return c.queue.Len() > 0, nil
+	})
+	if err != nil {
+		t.Fatal("no item was queued after the debounce period elapsed")
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+)
+
+type lbGCMode string
+
+const (
+	// lbGCModeDisabled turns off garbage collection of orphaned
+	// load-balancers.
+	lbGCModeDisabled lbGCMode = "disabled"
+	// lbGCModeDryRun reports orphaned load-balancers without deleting them.
+	lbGCModeDryRun lbGCMode = "dry-run"
+	// lbGCModeEnabled deletes orphaned load-balancers.
+	lbGCModeEnabled lbGCMode = "enabled"
+)
+
+const (
+	controllerGCLoadBalancersPeriod = 10 * time.Minute
+	gcLoadBalancersTimeout          = 2 * time.Minute
+
+	// defaultLBGCGracePeriod is the default time a load-balancer must be
+	// orphaned before it is garbage-collected.
+	defaultLBGCGracePeriod = 1 * time.Hour
+
+	// lbGCProtectTag protects load-balancers carrying it from being
+	// garbage-collected.
+	lbGCProtectTag = "k8s:gc-protect"
+
+	lbGCResultDeleted = "deleted"
+	lbGCResultFailed  = "failed"
+	lbGCResultDryRun  = "dry_run"
+)
+
+// lbGCConfig configures the garbage collection of orphaned load-balancers.
+type lbGCConfig struct {
+	mode        lbGCMode
+	gracePeriod time.Duration
+}
+
+func (c lbGCConfig) enabled() bool {
+	return c.mode == lbGCModeDryRun || c.mode == lbGCModeEnabled
+}
+
+func parseLBGCMode(raw string) (lbGCMode, error) {
+	switch mode := lbGCMode(raw); mode {
+	case lbGCModeDisabled, lbGCModeDryRun, lbGCModeEnabled:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid load-balancer garbage collection mode %q, options are %q, %q, and %q", raw, lbGCModeDisabled, lbGCModeDryRun, lbGCModeEnabled)
+	}
+}
+
+// gcLoadBalancers deletes (or, in dry-run mode, reports) load-balancers
+// tagged with the cluster ID that do not belong to any LoadBalancer-typed
+// Service anymore and have been orphaned for at least the grace period.
+func (r *ResourcesController) gcLoadBalancers() error {
+	ctx, cancel := context.WithTimeout(context.Background(), gcLoadBalancersTimeout)
+	defer cancel()
+
+	svcs, err := r.svcLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed to list services: %s", err)
+	}
+
+	lbs, err := r.resources.allLoadBalancers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list load-balancers: %s", err)
+	}
+
+	// Collect the IDs and names of all load-balancers that Services may own.
+	ownedIDs := map[string]bool{}
+	ownedNames := map[string]bool{}
+	for _, svc := range svcs {
+		if svc.Spec.Type != corev1.ServiceTypeLoadBalancer {
+			continue
+		}
+		if id := getLoadBalancerID(svc); id != "" {
+			ownedIDs[id] = true
+		}
+		if id := svc.Annotations[annDOLoadBalancerReplacementID]; id != "" {
+			ownedIDs[id] = true
+		}
+		for _, name := range r.resources.loadBalancerNameCandidates(svc) {
+			ownedNames[name] = true
+		}
+		if group := joinedSharingGroup(svc); group != "" {
+			ownedNames[r.resources.sharedLoadBalancerName(svc.Namespace, group)] = true
+		}
+	}
+
+	if r.orphanedLBs == nil {
+		r.orphanedLBs = map[string]time.Time{}
+	}
+
+	clusterTag := buildK8sTag(r.resources.clusterID)
+	gracePeriod := r.resources.lbGC.gracePeriod
+	now := time.Now()
+	orphaned := map[string]bool{}
+	var errs []error
+	for _, lb := range lbs {
+		if !slices.Contains(lb.Tags, clusterTag) || ownedIDs[lb.ID] || ownedNames[lb.Name] {
+			continue
+		}
+		orphaned[lb.ID] = true
+
+		orphanedSince, ok := r.orphanedLBs[lb.ID]
+		if !ok {
+			klog.Infof("Found orphaned load-balancer %s (%s)", lb.Name, lb.ID)
+			orphanedSince = now
+			r.orphanedLBs[lb.ID] = now
+		}
+
+		if slices.Contains(lb.Tags, lbGCProtectTag) {
+			klog.V(2).Infof("Not garbage-collecting orphaned load-balancer %s (%s) because it is tagged with %q", lb.Name, lb.ID, lbGCProtectTag)
+			continue
+		}
+
+		// Load-balancers are tagged on creation, so recently created ones may
+		// not be associated with their Service yet.
+		if now.Sub(orphanedSince) < gracePeriod || createdWithin(lb.Created, gracePeriod, now) {
+			continue
+		}
+
+		if r.resources.lbGC.mode == lbGCModeDryRun {
+			klog.Infof("Would delete orphaned load-balancer %s (%s) (dry run)", lb.Name, lb.ID)
+			lbGCDeletionsTotal.WithLabelValues(lbGCResultDryRun).Inc()
+			continue
+		}
+
+		klog.Infof("Deleting orphaned load-balancer %s (%s)", lb.Name, lb.ID)
+		resp, err := r.resources.gclient.LoadBalancers.Delete(ctx, lb.ID)
+		if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+			lbGCDeletionsTotal.WithLabelValues(lbGCResultFailed).Inc()
+			errs = append(errs, fmt.Errorf("failed to delete orphaned load-balancer %s: %s", lb.ID, err))
+			continue
+		}
+		lbGCDeletionsTotal.WithLabelValues(lbGCResultDeleted).Inc()
+		r.resources.loadBalancerDeleted(lb.ID)
+		delete(r.orphanedLBs, lb.ID)
+		delete(orphaned, lb.ID)
+	}
+
+	// Forget load-balancers that are gone or have been claimed in the
+	// meantime.
+	for id := range r.orphanedLBs {
+		if !orphaned[id] {
+			delete(r.orphanedLBs, id)
+		}
+	}
+	lbGCOrphans.Set(float64(len(orphaned)))
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// createdWithin returns whether the RFC 3339 timestamp created lies within
+// period before now. Unparseable timestamps are ignored.
+func createdWithin(created string, period time.Duration, now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		return false
+	}
+	return now.Sub(t) < period
+}
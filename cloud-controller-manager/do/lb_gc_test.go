@@ -0,0 +1,167 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResourcesController_gcLoadBalancers(t *testing.T) {
+	clusterTag := buildK8sTag(clusterID)
+	longAgo := time.Now().Add(-24 * time.Hour)
+
+	lbs := []godo.LoadBalancer{
+		{ID: "owned-by-id", Name: "renamed", Tags: []string{clusterTag}},
+		{ID: "owned-by-name", Name: "afoobar123", Tags: []string{clusterTag}},
+		{ID: "foreign", Name: "foreign", Tags: []string{"other"}},
+		{ID: "protected", Name: "protected", Tags: []string{clusterTag, lbGCProtectTag}},
+		{ID: "fresh-orphan", Name: "fresh-orphan", Tags: []string{clusterTag}},
+		{ID: "old-orphan", Name: "old-orphan", Tags: []string{clusterTag}},
+		{ID: "recently-created", Name: "recently-created", Tags: []string{clusterTag}, Created: time.Now().Format(time.RFC3339)},
+	}
+	services := []*corev1.Service{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "by-id",
+				UID:  "abc123",
+				Annotations: map[string]string{
+					annDOLoadBalancerID: "owned-by-id",
+				},
+			},
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "by-name",
+				UID:  "foobar123",
+			},
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "cluster-ip",
+				UID:  "xyz",
+				Annotations: map[string]string{
+					annDOLoadBalancerID: "old-orphan",
+				},
+			},
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		mode        lbGCMode
+		wantDeleted []string
+		wantDryRun  float64
+	}{
+		{
+			name:        "enabled",
+			mode:        lbGCModeEnabled,
+			wantDeleted: []string{"old-orphan"},
+		},
+		{
+			name:       "dry run",
+			mode:       lbGCModeDryRun,
+			wantDryRun: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var deleted []string
+			fakeLB := &fakeLBService{
+				listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+					return lbs, newFakeOKResponse(), nil
+				},
+				deleteFn: func(_ context.Context, lbID string) (*godo.Response, error) {
+					deleted = append(deleted, lbID)
+					return newFakeOKResponse(), nil
+				},
+			}
+			fakeResources := newResources(clusterID, "", publicAccessFirewall{}, newFakeLBClient(fakeLB))
+			fakeResources.lbGC = lbGCConfig{mode: test.mode, gracePeriod: time.Hour}
+
+			kclient := fake.NewSimpleClientset()
+			for _, svc := range services {
+				if _, err := kclient.CoreV1().Services(corev1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{}); err != nil {
+					t.Fatalf("failed to create service: %s", err)
+				}
+			}
+			sharedInformer := informers.NewSharedInformerFactory(kclient, 0)
+			res := NewResourcesController(fakeResources, sharedInformer.Core().V1().Services(), kclient)
+			sharedInformer.Start(nil)
+			sharedInformer.WaitForCacheSync(nil)
+
+			// The old orphan was found during a previous run already.
+			res.orphanedLBs["old-orphan"] = longAgo
+			res.orphanedLBs["gone"] = longAgo
+			dryRunsBefore := testutil.ToFloat64(lbGCDeletionsTotal.WithLabelValues(lbGCResultDryRun))
+
+			if err := res.gcLoadBalancers(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			sort.Strings(deleted)
+			if !reflect.DeepEqual(deleted, test.wantDeleted) {
+				t.Errorf("got deleted load-balancers %v, want %v", deleted, test.wantDeleted)
+			}
+			if got := testutil.ToFloat64(lbGCDeletionsTotal.WithLabelValues(lbGCResultDryRun)) - dryRunsBefore; got != test.wantDryRun {
+				t.Errorf("got %v dry-run deletion(s), want %v", got, test.wantDryRun)
+			}
+
+			wantOrphans := []string{"fresh-orphan", "protected", "recently-created"}
+			if test.mode == lbGCModeDryRun {
+				wantOrphans = append(wantOrphans, "old-orphan")
+			}
+			var orphans []string
+			for id := range res.orphanedLBs {
+				orphans = append(orphans, id)
+			}
+			sort.Strings(orphans)
+			sort.Strings(wantOrphans)
+			if !reflect.DeepEqual(orphans, wantOrphans) {
+				t.Errorf("got tracked orphans %v, want %v", orphans, wantOrphans)
+			}
+			if got := testutil.ToFloat64(lbGCOrphans); got != float64(len(wantOrphans)) {
+				t.Errorf("got orphans gauge %v, want %d", got, len(wantOrphans))
+			}
+		})
+	}
+}
+
+func Test_parseLBGCMode(t *testing.T) {
+	for _, raw := range []string{"disabled", "dry-run", "enabled"} {
+		if _, err := parseLBGCMode(raw); err != nil {
+			t.Errorf("unexpected error for mode %q: %s", raw, err)
+		}
+	}
+	if _, err := parseLBGCMode("yes"); err == nil {
+		t.Error("expected error for invalid mode but got none")
+	}
+}
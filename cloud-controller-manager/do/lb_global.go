@@ -0,0 +1,238 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultGLBTargetPort is the port global load balancers use to reach
+	// regional load balancers if not specified otherwise.
+	defaultGLBTargetPort = 80
+)
+
+// glbAnnotations lists the annotations that are only valid for global load
+// balancers.
+var glbAnnotations = []string{
+	annDOGLBTargetProtocol,
+	annDOGLBTargetPort,
+	annDOGLBCDNEnabled,
+	annDOGLBRegionPriorities,
+	annDOGLBFailoverThreshold,
+	annDOGLBDomains,
+	annDOGLBDomainsManaged,
+	annDOGLBTargetLoadBalancerIDs,
+}
+
+// validateGLBAnnotations returns an error if service specifies global load
+// balancer annotations without being of type GLOBAL.
+func validateGLBAnnotations(service *v1.Service, lbType string) error {
+	if lbType == godo.LoadBalancerTypeGlobal {
+		return nil
+	}
+
+	for _, ann := range glbAnnotations {
+		if _, ok := service.Annotations[ann]; ok {
+			return fmt.Errorf("annotation %q is only supported for LB type %s", ann, godo.LoadBalancerTypeGlobal)
+		}
+	}
+	return nil
+}
+
+// buildGLBSettings returns the global load balancer settings of service.
+func buildGLBSettings(service *v1.Service) (*godo.GLBSettings, error) {
+	targetProtocol, err := getGLBTargetProtocol(service)
+	if err != nil {
+		return nil, err
+	}
+
+	targetPort, err := getGLBTargetPort(service)
+	if err != nil {
+		return nil, err
+	}
+
+	cdnEnabled, _, err := getBool(service.Annotations, annDOGLBCDNEnabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GLB CDN configuration setting: %w", err)
+	}
+
+	regionPriorities, err := getGLBRegionPriorities(service)
+	if err != nil {
+		return nil, err
+	}
+
+	failoverThreshold, err := getGLBFailoverThreshold(service)
+	if err != nil {
+		return nil, err
+	}
+
+	if failoverThreshold > 0 && len(regionPriorities) == 0 {
+		return nil, fmt.Errorf("annotation %q requires annotation %q to be set", annDOGLBFailoverThreshold, annDOGLBRegionPriorities)
+	}
+
+	return &godo.GLBSettings{
+		TargetProtocol:    targetProtocol,
+		TargetPort:        targetPort,
+		CDN:               &godo.CDNSettings{IsEnabled: cdnEnabled},
+		RegionPriorities:  regionPriorities,
+		FailoverThreshold: failoverThreshold,
+	}, nil
+}
+
+// getGLBTargetProtocol returns the protocol a global load balancer uses to
+// reach its targets. http is returned if not specified.
+func getGLBTargetProtocol(service *v1.Service) (string, error) {
+	protocol, ok := service.Annotations[annDOGLBTargetProtocol]
+	if !ok || protocol == "" {
+		return protocolHTTP, nil
+	}
+
+	switch protocol {
+	case protocolHTTP, protocolHTTPS:
+	default:
+		return "", invalidAnnotation(annDOGLBTargetProtocol, "invalid protocol %q specified in annotation %q", protocol, annDOGLBTargetProtocol)
+	}
+
+	return protocol, nil
+}
+
+// getGLBTargetPort returns the port a global load balancer uses to reach its
+// targets.
+func getGLBTargetPort(service *v1.Service) (uint32, error) {
+	portStr, ok := service.Annotations[annDOGLBTargetPort]
+	if ok && portStr != "" {
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil || port == 0 {
+			return 0, invalidAnnotation(annDOGLBTargetPort, "invalid port %q specified in annotation %q", portStr, annDOGLBTargetPort)
+		}
+		return uint32(port), nil
+	}
+
+	if len(getGLBTargetLoadBalancerIDs(service)) > 0 {
+		return defaultGLBTargetPort, nil
+	}
+
+	// Droplets are targeted directly, so traffic must be sent to a node port.
+	for _, port := range service.Spec.Ports {
+		if port.Protocol == v1.ProtocolTCP && port.NodePort != 0 {
+			return uint32(port.NodePort), nil
+		}
+	}
+
+	return 0, fmt.Errorf("no node port of protocol TCP found to target, and no annotation %q given", annDOGLBTargetPort)
+}
+
+// getGLBRegionPriorities returns the region priorities of a global load
+// balancer.
+func getGLBRegionPriorities(service *v1.Service) (map[string]uint32, error) {
+	pairs := getStrings(service, annDOGLBRegionPriorities)
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	priorities := make(map[string]uint32, len(pairs))
+	for _, pair := range pairs {
+		region, priorityStr, found := strings.Cut(pair, ":")
+		if !found || region == "" {
+			return nil, invalidAnnotation(annDOGLBRegionPriorities, "invalid region priority %q in annotation %q (expected format is <region>:<priority>)", pair, annDOGLBRegionPriorities)
+		}
+
+		priority, err := strconv.ParseUint(priorityStr, 10, 32)
+		if err != nil {
+			return nil, invalidAnnotation(annDOGLBRegionPriorities, "invalid priority %q for region %q in annotation %q: %s", priorityStr, region, annDOGLBRegionPriorities, err)
+		}
+
+		if _, ok := priorities[region]; ok {
+			return nil, invalidAnnotation(annDOGLBRegionPriorities, "region %q specified more than once in annotation %q", region, annDOGLBRegionPriorities)
+		}
+		priorities[region] = uint32(priority)
+	}
+
+	return priorities, nil
+}
+
+// getGLBFailoverThreshold returns the failover threshold percentage of a
+// global load balancer. 0 is returned if not specified.
+func getGLBFailoverThreshold(service *v1.Service) (uint32, error) {
+	thresholdStr, ok := service.Annotations[annDOGLBFailoverThreshold]
+	if !ok || thresholdStr == "" {
+		return 0, nil
+	}
+
+	threshold, err := strconv.ParseUint(thresholdStr, 10, 32)
+	if err != nil {
+		return 0, invalidAnnotation(annDOGLBFailoverThreshold, "failed to parse GLB failover threshold annotation %q: %s", annDOGLBFailoverThreshold, err)
+	}
+
+	if threshold < 1 || threshold > 99 {
+		return 0, invalidAnnotation(annDOGLBFailoverThreshold, "GLB failover threshold must be between 1 and 99, got %d", threshold)
+	}
+
+	return uint32(threshold), nil
+}
+
+// buildGLBDomains returns the domains a global load balancer accepts traffic
+// for.
+func buildGLBDomains(service *v1.Service) ([]*godo.LBDomain, error) {
+	specs := getStrings(service, annDOGLBDomains)
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("annotation %q is required for LB type %s", annDOGLBDomains, godo.LoadBalancerTypeGlobal)
+	}
+
+	managed, _, err := getBool(service.Annotations, annDOGLBDomainsManaged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GLB managed domains configuration setting: %w", err)
+	}
+
+	var domains []*godo.LBDomain
+	for _, spec := range specs {
+		name, certificateID, _ := strings.Cut(spec, ":")
+		if name == "" {
+			return nil, errors.New("GLB domain name must not be empty")
+		}
+		domains = append(domains, &godo.LBDomain{
+			Name:          name,
+			IsManaged:     managed,
+			CertificateID: certificateID,
+		})
+	}
+
+	return domains, nil
+}
+
+// getGLBTargetLoadBalancerIDs returns the IDs of the regional load balancers
+// a global load balancer forwards traffic to.
+func getGLBTargetLoadBalancerIDs(service *v1.Service) []string {
+	return getStrings(service, annDOGLBTargetLoadBalancerIDs)
+}
+
+// setLoadBalancerPlacement sets the region and VPC of req. Global load
+// balancers are neither bound to a region nor to a VPC.
+func setLoadBalancerPlacement(req *godo.LoadBalancerRequest, region, vpcID string) {
+	if req.Type == godo.LoadBalancerTypeGlobal {
+		return
+	}
+	req.Region = region
+	req.VPCUUID = vpcID
+}
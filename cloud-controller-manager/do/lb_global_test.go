@@ -0,0 +1,288 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	"github.com/google/go-cmp/cmp"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_validateGLBAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		lbType      string
+		wantErr     bool
+	}{
+		{
+			name:   "regional without GLB annotations",
+			lbType: godo.LoadBalancerTypeRegional,
+		},
+		{
+			name:        "regional with GLB annotation",
+			annotations: map[string]string{annDOGLBDomains: "example.com"},
+			lbType:      godo.LoadBalancerTypeRegional,
+			wantErr:     true,
+		},
+		{
+			name:        "global with GLB annotation",
+			annotations: map[string]string{annDOGLBDomains: "example.com"},
+			lbType:      godo.LoadBalancerTypeGlobal,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateGLBAnnotations(newTestService(test.annotations), test.lbType)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("got error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func Test_buildGLBSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *godo.GLBSettings
+		wantErr     bool
+	}{
+		{
+			name: "defaults targeting droplets",
+			want: &godo.GLBSettings{
+				TargetProtocol: "http",
+				TargetPort:     30000,
+				CDN:            &godo.CDNSettings{},
+			},
+		},
+		{
+			name: "defaults targeting load balancers",
+			annotations: map[string]string{
+				annDOGLBTargetLoadBalancerIDs: "lb-1,lb-2",
+			},
+			want: &godo.GLBSettings{
+				TargetProtocol: "http",
+				TargetPort:     80,
+				CDN:            &godo.CDNSettings{},
+			},
+		},
+		{
+			name: "all settings",
+			annotations: map[string]string{
+				annDOGLBTargetProtocol:    "https",
+				annDOGLBTargetPort:        "443",
+				annDOGLBCDNEnabled:        "true",
+				annDOGLBRegionPriorities:  "nyc1:1,ams3:2",
+				annDOGLBFailoverThreshold: "50",
+			},
+			want: &godo.GLBSettings{
+				TargetProtocol: "https",
+				TargetPort:     443,
+				CDN:            &godo.CDNSettings{IsEnabled: true},
+				RegionPriorities: map[string]uint32{
+					"nyc1": 1,
+					"ams3": 2,
+				},
+				FailoverThreshold: 50,
+			},
+		},
+		{
+			name:        "invalid target protocol",
+			annotations: map[string]string{annDOGLBTargetProtocol: "tcp"},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid target port",
+			annotations: map[string]string{annDOGLBTargetPort: "0"},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid region priority",
+			annotations: map[string]string{annDOGLBRegionPriorities: "nyc1"},
+			wantErr:     true,
+		},
+		{
+			name:        "duplicate region priority",
+			annotations: map[string]string{annDOGLBRegionPriorities: "nyc1:1,nyc1:2"},
+			wantErr:     true,
+		},
+		{
+			name: "failover threshold out of range",
+			annotations: map[string]string{
+				annDOGLBRegionPriorities:  "nyc1:1",
+				annDOGLBFailoverThreshold: "100",
+			},
+			wantErr: true,
+		},
+		{
+			name:        "failover threshold without region priorities",
+			annotations: map[string]string{annDOGLBFailoverThreshold: "50"},
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := buildGLBSettings(newTestService(test.annotations))
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GLB settings mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_buildGLBDomains(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        []*godo.LBDomain
+		wantErr     bool
+	}{
+		{
+			name:    "missing domains",
+			wantErr: true,
+		},
+		{
+			name: "domains with and without certificate",
+			annotations: map[string]string{
+				annDOGLBDomains: "example.com:cert-1,www.example.com",
+			},
+			want: []*godo.LBDomain{
+				{Name: "example.com", CertificateID: "cert-1"},
+				{Name: "www.example.com"},
+			},
+		},
+		{
+			name: "managed domains",
+			annotations: map[string]string{
+				annDOGLBDomains:        "example.com",
+				annDOGLBDomainsManaged: "true",
+			},
+			want: []*godo.LBDomain{
+				{Name: "example.com", IsManaged: true},
+			},
+		},
+		{
+			name: "empty domain name",
+			annotations: map[string]string{
+				annDOGLBDomains: ":cert-1",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := buildGLBDomains(newTestService(test.annotations))
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("GLB domains mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_buildLoadBalancerRequestGlobal(t *testing.T) {
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		wantDropletIDs []int
+		wantTargetLBs  []string
+		wantErr        bool
+	}{
+		{
+			name: "targeting droplets",
+			annotations: map[string]string{
+				annDOType:       godo.LoadBalancerTypeGlobal,
+				annDOGLBDomains: "example.com",
+			},
+			wantDropletIDs: []int{100},
+		},
+		{
+			name: "targeting load balancers",
+			annotations: map[string]string{
+				annDOType:                     godo.LoadBalancerTypeGlobal,
+				annDOGLBDomains:               "example.com",
+				annDOGLBTargetLoadBalancerIDs: "lb-1,lb-2",
+			},
+			wantTargetLBs: []string{"lb-1", "lb-2"},
+		},
+		{
+			name: "internal network",
+			annotations: map[string]string{
+				annDOType:       godo.LoadBalancerTypeGlobal,
+				annDOGLBDomains: "example.com",
+				annDONetwork:    godo.LoadBalancerNetworkTypeInternal,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClient := newFakeDropletClient(
+				&fakeDropletService{
+					listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+						return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+					},
+				},
+			)
+			fakeResources := newResources("", "vpc_uuid", publicAccessFirewall{}, fakeClient)
+			lb := &loadBalancers{
+				resources: fakeResources,
+				region:    "nyc3",
+			}
+			nodes := []*v1.Node{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+				},
+			}
+
+			lbr, err := lb.buildLoadBalancerRequest(context.Background(), newTestService(test.annotations), nodes)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if lbr.Region != "" || lbr.VPCUUID != "" {
+				t.Errorf("got region %q and VPC %q, want neither to be set", lbr.Region, lbr.VPCUUID)
+			}
+			if lbr.GLBSettings == nil {
+				t.Errorf("expected GLB settings to be set")
+			}
+			if diff := cmp.Diff(test.wantDropletIDs, lbr.DropletIDs); diff != "" {
+				t.Errorf("droplet IDs mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.wantTargetLBs, lbr.TargetLoadBalancerIDs); diff != "" {
+				t.Errorf("target load balancer IDs mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
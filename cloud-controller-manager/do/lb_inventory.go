@@ -0,0 +1,296 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// lbInventoryRefreshPeriod is the interval at which the load-balancer
+	// inventory is refreshed in the background. It is also the maximum age
+	// of the inventory before lookups refresh it on demand.
+	lbInventoryRefreshPeriod = 5 * time.Minute
+
+	// lbInventoryMinRefreshInterval is the minimum time between two
+	// refreshes triggered by lookup misses. It keeps bursts of lookups for
+	// load-balancers that do not exist yet (e.g., on controller startup)
+	// from each listing all load-balancers.
+	lbInventoryMinRefreshInterval = 10 * time.Second
+
+	// lbInventoryBackgroundRefreshTimeout is the timeout of refreshes that
+	// are not waited for.
+	lbInventoryBackgroundRefreshTimeout = time.Minute
+)
+
+// lbInventory is an in-memory inventory of all load-balancers in the account,
+// indexed by ID and name. It is shared by all consumers that would otherwise
+// need to list all load-balancers. Names are not unique, so all load-balancers
+// of a name are kept in the order they were listed in.
+type lbInventory struct {
+	client *godo.Client
+	maxAge time.Duration
+
+	// refreshMu serializes refreshes so that concurrent lookups do not
+	// list the load-balancers more than once.
+	refreshMu sync.Mutex
+
+	mu          sync.RWMutex
+	byID        map[string]*godo.LoadBalancer
+	byName      map[string][]*godo.LoadBalancer
+	lastRefresh time.Time
+	stale       bool
+}
+
+func newLBInventory(client *godo.Client, maxAge time.Duration) *lbInventory {
+	return &lbInventory{
+		client: client,
+		maxAge: maxAge,
+		byID:   map[string]*godo.LoadBalancer{},
+		byName: map[string][]*godo.LoadBalancer{},
+		stale:  true,
+	}
+}
+
+// refresh lists all load-balancers and rebuilds the inventory.
+func (i *lbInventory) refresh(ctx context.Context) error {
+	i.refreshMu.Lock()
+	defer i.refreshMu.Unlock()
+	return i.refreshLocked(ctx)
+}
+
+func (i *lbInventory) refreshLocked(ctx context.Context) error {
+	lbs, err := allLoadBalancerList(ctx, i.client)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]*godo.LoadBalancer, len(lbs))
+	byName := make(map[string][]*godo.LoadBalancer, len(lbs))
+	for idx := range lbs {
+		lb := &lbs[idx]
+		byID[lb.ID] = lb
+		byName[lb.Name] = append(byName[lb.Name], lb)
+		if len(byName[lb.Name]) == 2 {
+			klog.Warningf("Found multiple load-balancers named %s, lookups by name resolve to %s", lb.Name, byName[lb.Name][0].ID)
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.byID = byID
+	i.byName = byName
+	i.lastRefresh = time.Now()
+	i.stale = false
+
+	klog.V(5).Infof("Refreshed load-balancer inventory with %d load-balancer(s)", len(lbs))
+	return nil
+}
+
+// ensureFresh refreshes the inventory if it is stale or older than
+// minAge. Concurrent callers wait for a single refresh.
+func (i *lbInventory) ensureFresh(ctx context.Context, minAge time.Duration) error {
+	if !i.needsRefresh(minAge) {
+		return nil
+	}
+
+	i.refreshMu.Lock()
+	defer i.refreshMu.Unlock()
+	// Another caller may have refreshed the inventory while we were waiting.
+	if !i.needsRefresh(minAge) {
+		return nil
+	}
+	return i.refreshLocked(ctx)
+}
+
+func (i *lbInventory) needsRefresh(minAge time.Duration) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.stale || time.Since(i.lastRefresh) >= minAge
+}
+
+// list returns all load-balancers of the inventory, ordered by ID.
+func (i *lbInventory) list(ctx context.Context) ([]godo.LoadBalancer, error) {
+	if err := i.ensureFresh(ctx, i.maxAge); err != nil {
+		return nil, err
+	}
+
+	i.mu.RLock()
+	lbs := make([]godo.LoadBalancer, 0, len(i.byID))
+	for _, lb := range i.byID {
+		lbs = append(lbs, *lb)
+	}
+	i.mu.RUnlock()
+
+	sort.Slice(lbs, func(a, b int) bool {
+		return lbs[a].ID < lbs[b].ID
+	})
+	return lbs, nil
+}
+
+// getByName returns the first load-balancer matching one of names, in order.
+// Like the API, the first load-balancer listed is returned for names shared by
+// multiple load-balancers.
+// On a miss, the inventory is refreshed once unless it was refreshed only
+// recently. nil is returned if no load-balancer matches.
+func (i *lbInventory) getByName(ctx context.Context, names ...string) (*godo.LoadBalancer, error) {
+	if err := i.ensureFresh(ctx, i.maxAge); err != nil {
+		return nil, err
+	}
+	if lb := i.lookupName(names); lb != nil {
+		return lb, nil
+	}
+
+	if err := i.ensureFresh(ctx, lbInventoryMinRefreshInterval); err != nil {
+		return nil, err
+	}
+	return i.lookupName(names), nil
+}
+
+func (i *lbInventory) lookupName(names []string) *godo.LoadBalancer {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, name := range names {
+		if lbs := i.byName[name]; len(lbs) > 0 {
+			lbCopy := *lbs[0]
+			return &lbCopy
+		}
+	}
+	return nil
+}
+
+// lookupAllByName returns all load-balancers named name, in the order they
+// were listed in, without refreshing the inventory.
+func (i *lbInventory) lookupAllByName(name string) []godo.LoadBalancer {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	lbs := make([]godo.LoadBalancer, 0, len(i.byName[name]))
+	for _, lb := range i.byName[name] {
+		lbs = append(lbs, *lb)
+	}
+	return lbs
+}
+
+// refreshInBackground refreshes the inventory without waiting for it if it is
+// stale or older than the maximum age, unless a refresh is in progress
+// already.
+func (i *lbInventory) refreshInBackground() {
+	if !i.needsRefresh(i.maxAge) || !i.refreshMu.TryLock() {
+		return
+	}
+
+	go func() {
+		defer i.refreshMu.Unlock()
+		if !i.needsRefresh(i.maxAge) {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), lbInventoryBackgroundRefreshTimeout)
+		defer cancel()
+		if err := i.refreshLocked(ctx); err != nil {
+			klog.Errorf("Failed to refresh load-balancer inventory: %s", err)
+		}
+	}()
+}
+
+// getByID returns the load-balancer with the given ID. On a miss, the
+// inventory is refreshed once unless it was refreshed only recently. nil is
+// returned if no load-balancer matches.
+func (i *lbInventory) getByID(ctx context.Context, id string) (*godo.LoadBalancer, error) {
+	if err := i.ensureFresh(ctx, i.maxAge); err != nil {
+		return nil, err
+	}
+	if lb := i.lookupID(id); lb != nil {
+		return lb, nil
+	}
+
+	if err := i.ensureFresh(ctx, lbInventoryMinRefreshInterval); err != nil {
+		return nil, err
+	}
+	return i.lookupID(id), nil
+}
+
+func (i *lbInventory) lookupID(id string) *godo.LoadBalancer {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	lb, ok := i.byID[id]
+	if !ok {
+		return nil
+	}
+	lbCopy := *lb
+	return &lbCopy
+}
+
+// put adds or replaces lb in the inventory. It is called after load-balancers
+// are created, updated, or retrieved by ID.
+func (i *lbInventory) put(lb *godo.LoadBalancer) {
+	if lb == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	lbCopy := *lb
+	if old, ok := i.byID[lb.ID]; ok && old.Name == lb.Name {
+		// Keep the position of lb among load-balancers of the same name.
+		idx := slices.Index(i.byName[lb.Name], old)
+		i.byName[lb.Name][idx] = &lbCopy
+	} else {
+		if ok {
+			i.removeNameLocked(old)
+		}
+		i.byName[lb.Name] = append(i.byName[lb.Name], &lbCopy)
+	}
+	i.byID[lb.ID] = &lbCopy
+}
+
+// remove deletes the load-balancer with the given ID from the inventory.
+func (i *lbInventory) remove(id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if lb, ok := i.byID[id]; ok {
+		i.removeNameLocked(lb)
+		delete(i.byID, id)
+	}
+}
+
+// removeNameLocked removes lb from the name index. i.mu must be held.
+func (i *lbInventory) removeNameLocked(lb *godo.LoadBalancer) {
+	lbs := slices.DeleteFunc(i.byName[lb.Name], func(other *godo.LoadBalancer) bool {
+		return other == lb
+	})
+	if len(lbs) == 0 {
+		delete(i.byName, lb.Name)
+		return
+	}
+	i.byName[lb.Name] = lbs
+}
+
+// invalidate marks the inventory as stale, forcing the next lookup to refresh
+// it. It is called when a mutation fails and the outcome is unknown.
+func (i *lbInventory) invalidate() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stale = true
+}
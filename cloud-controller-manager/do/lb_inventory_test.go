@@ -0,0 +1,232 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func newCountingLBInventory(lbs []godo.LoadBalancer, maxAge time.Duration) (*lbInventory, *int32) {
+	var listCalls int32
+	gclient := newFakeLBClient(
+		&fakeLBService{
+			listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+				atomic.AddInt32(&listCalls, 1)
+				return lbs, newFakeOKResponse(), nil
+			},
+		},
+	)
+	return newLBInventory(gclient, maxAge), &listCalls
+}
+
+func TestLBInventory_getByName(t *testing.T) {
+	inv, listCalls := newCountingLBInventory([]godo.LoadBalancer{
+		{ID: "1", Name: "one"},
+		{ID: "2", Name: "two"},
+	}, time.Hour)
+	ctx := context.Background()
+
+	for _, name := range []string{"one", "two", "one"} {
+		lb, err := inv.getByName(ctx, name)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if lb == nil || lb.Name != name {
+			t.Fatalf("got load-balancer %v, want name %q", lb, name)
+		}
+	}
+	if got := atomic.LoadInt32(listCalls); got != 1 {
+		t.Errorf("got %d list call(s), want 1", got)
+	}
+
+	// Candidates are matched in order.
+	lb, err := inv.getByName(ctx, "missing", "two")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if lb == nil || lb.ID != "2" {
+		t.Errorf("got load-balancer %v, want ID 2", lb)
+	}
+
+	// A miss right after a refresh must not list again.
+	lb, err = inv.getByName(ctx, "missing")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if lb != nil {
+		t.Errorf("got load-balancer %v, want none", lb)
+	}
+	if got := atomic.LoadInt32(listCalls); got != 1 {
+		t.Errorf("got %d list call(s) after miss, want 1", got)
+	}
+}
+
+func TestLBInventory_missRefreshesOldInventory(t *testing.T) {
+	inv, listCalls := newCountingLBInventory(nil, time.Hour)
+	ctx := context.Background()
+
+	if err := inv.refresh(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	inv.lastRefresh = time.Now().Add(-2 * lbInventoryMinRefreshInterval)
+
+	if _, err := inv.getByName(ctx, "missing"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := atomic.LoadInt32(listCalls); got != 2 {
+		t.Errorf("got %d list call(s), want 2", got)
+	}
+}
+
+func TestLBInventory_mutations(t *testing.T) {
+	inv, listCalls := newCountingLBInventory([]godo.LoadBalancer{
+		{ID: "1", Name: "one"},
+	}, time.Hour)
+	ctx := context.Background()
+
+	if err := inv.refresh(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	inv.put(&godo.LoadBalancer{ID: "1", Name: "renamed"})
+	inv.put(&godo.LoadBalancer{ID: "2", Name: "two"})
+
+	if lb := inv.lookupName([]string{"one"}); lb != nil {
+		t.Errorf("got load-balancer %v by old name, want none", lb)
+	}
+	if lb := inv.lookupName([]string{"renamed"}); lb == nil || lb.ID != "1" {
+		t.Errorf("got load-balancer %v, want ID 1", lb)
+	}
+
+	inv.remove("2")
+	lbs, err := inv.list(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(lbs) != 1 || lbs[0].ID != "1" {
+		t.Errorf("got load-balancers %v, want only ID 1", lbs)
+	}
+	if got := atomic.LoadInt32(listCalls); got != 1 {
+		t.Errorf("got %d list call(s), want 1", got)
+	}
+
+	inv.invalidate()
+	if _, err := inv.list(ctx); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := atomic.LoadInt32(listCalls); got != 2 {
+		t.Errorf("got %d list call(s) after invalidation, want 2", got)
+	}
+}
+
+func TestLBInventory_duplicateNames(t *testing.T) {
+	inv, _ := newCountingLBInventory([]godo.LoadBalancer{
+		{ID: "3", Name: "dup"},
+		{ID: "1", Name: "dup"},
+		{ID: "2", Name: "other"},
+	}, time.Hour)
+	ctx := context.Background()
+
+	// Like the API, the first load-balancer listed wins.
+	lb, err := inv.getByName(ctx, "dup")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if lb == nil || lb.ID != "3" {
+		t.Errorf("got load-balancer %v, want ID 3", lb)
+	}
+
+	// Updates keep the order, and removals keep the other load-balancers of
+	// the name.
+	inv.put(&godo.LoadBalancer{ID: "3", Name: "dup", IP: "10.0.0.1"})
+	if lb := inv.lookupName([]string{"dup"}); lb == nil || lb.ID != "3" || lb.IP != "10.0.0.1" {
+		t.Errorf("got load-balancer %v after update, want updated ID 3", lb)
+	}
+	inv.remove("3")
+	if lb := inv.lookupName([]string{"dup"}); lb == nil || lb.ID != "1" {
+		t.Errorf("got load-balancer %v after removal, want ID 1", lb)
+	}
+	inv.put(&godo.LoadBalancer{ID: "1", Name: "renamed"})
+	if lb := inv.lookupName([]string{"dup"}); lb != nil {
+		t.Errorf("got load-balancer %v after rename, want none", lb)
+	}
+}
+
+func TestLBInventory_listOrder(t *testing.T) {
+	inv, _ := newCountingLBInventory([]godo.LoadBalancer{
+		{ID: "c", Name: "three"},
+		{ID: "a", Name: "one"},
+		{ID: "b", Name: "two"},
+	}, time.Hour)
+
+	lbs, err := inv.list(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var ids []string
+	for _, lb := range lbs {
+		ids = append(ids, lb.ID)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got IDs %v, want %v", ids, want)
+	}
+}
+
+func TestLBInventory_refreshInBackground(t *testing.T) {
+	release := make(chan struct{})
+	var listCalls int32
+	gclient := newFakeLBClient(
+		&fakeLBService{
+			listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+				atomic.AddInt32(&listCalls, 1)
+				<-release
+				return []godo.LoadBalancer{{ID: "1", Name: "one"}}, newFakeOKResponse(), nil
+			},
+		},
+	)
+	inv := newLBInventory(gclient, time.Hour)
+
+	// Refreshes in progress are not waited for nor started again.
+	inv.refreshInBackground()
+	inv.refreshInBackground()
+	if lbs := inv.lookupAllByName("one"); len(lbs) != 0 {
+		t.Errorf("got load-balancers %v before the refresh completed, want none", lbs)
+	}
+	close(release)
+
+	err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		return !inv.needsRefresh(time.Hour), nil
+	})
+	if err != nil {
+		t.Fatalf("inventory was not refreshed: %s", err)
+	}
+	if lbs := inv.lookupAllByName("one"); len(lbs) != 1 || lbs[0].ID != "1" {
+		t.Errorf("got load-balancers %v, want ID 1", lbs)
+	}
+
+	inv.refreshInBackground()
+	if got := atomic.LoadInt32(&listCalls); got != 1 {
+		t.Errorf("got %d list call(s), want 1", got)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// maxLoadBalancerNameLength is the maximum length of load-balancer names
+// accepted by the DO API.
+const maxLoadBalancerNameLength = 255
+
+// lbNameRegexp matches valid load-balancer names: alphanumeric characters,
+// dots, and dashes, starting with an alphanumeric character and not ending
+// with a dash.
+var lbNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9.])?$`)
+
+// validateLoadBalancerName returns an error if name is not a valid
+// load-balancer name.
+func validateLoadBalancerName(name string) error {
+	if len(name) > maxLoadBalancerNameLength {
+		return fmt.Errorf("name must not be longer than %d characters", maxLoadBalancerNameLength)
+	}
+	if !lbNameRegexp.MatchString(name) {
+		return errors.New("name must consist of alphanumeric characters, dots, and dashes, start with an alphanumeric character, and not end with a dash")
+	}
+	return nil
+}
+
+// validateLoadBalancerNameAnnotation validates the custom load-balancer name
+// of service, if any.
+func validateLoadBalancerNameAnnotation(service *v1.Service) error {
+	name, ok := service.Annotations[annDOLoadBalancerName]
+	if !ok {
+		return nil
+	}
+	if err := validateLoadBalancerName(name); err != nil {
+		return invalidAnnotation(annDOLoadBalancerName, "invalid load-balancer name %q specified in annotation %q: %s", name, annDOLoadBalancerName, err)
+	}
+	return nil
+}
+
+// lbNameTemplateData is passed to load-balancer name templates.
+type lbNameTemplateData struct {
+	ClusterID string
+	Namespace string
+	Name      string
+}
+
+// parseLBNameTemplate parses a load-balancer name template. The template
+// must render valid names that are unique for each Service.
+func parseLBNameTemplate(raw string) (*template.Template, error) {
+	tmpl, err := template.New("lb-name").Option("missingkey=error").Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := []lbNameTemplateData{
+		{ClusterID: "cluster", Namespace: "namespace-a", Name: "service-a"},
+		{ClusterID: "cluster", Namespace: "namespace-a", Name: "service-b"},
+		{ClusterID: "cluster", Namespace: "namespace-b", Name: "service-a"},
+	}
+	rendered := map[string]bool{}
+	for _, sample := range samples {
+		name, err := renderLBNameTemplate(tmpl, sample)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateLoadBalancerName(name); err != nil {
+			return nil, fmt.Errorf("template renders invalid name %q: %s", name, err)
+		}
+		rendered[name] = true
+	}
+	if len(rendered) != len(samples) {
+		return nil, errors.New("template must render unique names per Service and thus reference both .Namespace and .Name")
+	}
+
+	return tmpl, nil
+}
+
+func renderLBNameTemplate(tmpl *template.Template, data lbNameTemplateData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render template: %s", err)
+	}
+	return sb.String(), nil
+}
+
+// loadBalancerName returns the desired name of the load-balancer of service:
+// the custom name annotation if set, the name rendered from the load-balancer
+// name template if configured, and the legacy name otherwise.
+func (r *resources) loadBalancerName(service *v1.Service) string {
+	if name := service.Annotations[annDOLoadBalancerName]; name != "" {
+		return name
+	}
+	if name := r.templatedLoadBalancerName(service); name != "" {
+		return name
+	}
+	return getLoadBalancerLegacyName(service)
+}
+
+// templatedLoadBalancerName returns the name rendered from the load-balancer
+// name template for service, or an empty string if no template is configured
+// or the rendered name is invalid.
+func (r *resources) templatedLoadBalancerName(service *v1.Service) string {
+	if r == nil || r.lbNameTemplate == nil {
+		return ""
+	}
+
+	name, err := renderLBNameTemplate(r.lbNameTemplate, lbNameTemplateData{
+		ClusterID: r.clusterID,
+		Namespace: service.Namespace,
+		Name:      service.Name,
+	})
+	if err == nil {
+		err = validateLoadBalancerName(name)
+	}
+	if err != nil {
+		klog.Errorf("Failed to build templated load-balancer name for service %s/%s, falling back to legacy name: %s", service.Namespace, service.Name, err)
+		return ""
+	}
+	return name
+}
+
+// loadBalancerNameCandidates returns the names a load-balancer of service may
+// go by, in order of preference.
+func (r *resources) loadBalancerNameCandidates(service *v1.Service) []string {
+	var candidates []string
+	for _, name := range []string{
+		r.loadBalancerName(service),
+		r.templatedLoadBalancerName(service),
+		getLoadBalancerLegacyName(service),
+	} {
+		if name != "" && !slices.Contains(candidates, name) {
+			candidates = append(candidates, name)
+		}
+	}
+	return candidates
+}
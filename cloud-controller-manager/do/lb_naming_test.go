@@ -0,0 +1,276 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_validateLoadBalancerName(t *testing.T) {
+	tests := []struct {
+		name    string
+		lbName  string
+		wantErr bool
+	}{
+		{
+			name:   "valid name",
+			lbName: "prod-default.web-1",
+		},
+		{
+			name:   "single character",
+			lbName: "a",
+		},
+		{
+			name:    "empty",
+			lbName:  "",
+			wantErr: true,
+		},
+		{
+			name:    "leading dash",
+			lbName:  "-web",
+			wantErr: true,
+		},
+		{
+			name:   "trailing dot",
+			lbName: "web.",
+		},
+		{
+			name:    "trailing dash",
+			lbName:  "web-",
+			wantErr: true,
+		},
+		{
+			name:    "invalid character",
+			lbName:  "default_web",
+			wantErr: true,
+		},
+		{
+			name:    "too long",
+			lbName:  strings.Repeat("a", maxLoadBalancerNameLength+1),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateLoadBalancerName(test.lbName)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseLBNameTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{
+			name:     "valid template",
+			template: "prod-{{.Namespace}}-{{.Name}}",
+		},
+		{
+			name:     "cluster ID",
+			template: "{{.ClusterID}}-{{.Namespace}}-{{.Name}}",
+		},
+		{
+			name:     "syntax error",
+			template: "{{.Namespace}-{{.Name}}",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown field",
+			template: "{{.Cluster}}-{{.Namespace}}-{{.Name}}",
+			wantErr:  true,
+		},
+		{
+			name:     "not unique",
+			template: "prod-{{.Name}}",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid name",
+			template: "{{.Namespace}}_{{.Name}}",
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseLBNameTemplate(test.template)
+			if (err != nil) != test.wantErr {
+				t.Errorf("got error %v, want error: %t", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func Test_loadBalancerName(t *testing.T) {
+	tmpl, err := parseLBNameTemplate("{{.ClusterID}}-{{.Namespace}}-{{.Name}}")
+	if err != nil {
+		t.Fatalf("failed to parse template: %s", err)
+	}
+	legacyName := "afoobar123"
+	templatedName := clusterID + "-default-web"
+
+	tests := []struct {
+		name           string
+		template       bool
+		customName     string
+		wantName       string
+		wantCandidates []string
+	}{
+		{
+			name:           "legacy name",
+			wantName:       legacyName,
+			wantCandidates: []string{legacyName},
+		},
+		{
+			name:           "templated name",
+			template:       true,
+			wantName:       templatedName,
+			wantCandidates: []string{templatedName, legacyName},
+		},
+		{
+			name:           "custom name",
+			template:       true,
+			customName:     "custom",
+			wantName:       "custom",
+			wantCandidates: []string{"custom", templatedName, legacyName},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "web",
+					Namespace:   v1.NamespaceDefault,
+					UID:         "foobar123",
+					Annotations: map[string]string{},
+				},
+			}
+			if test.customName != "" {
+				service.Annotations[annDOLoadBalancerName] = test.customName
+			}
+			res := newResources(clusterID, "", publicAccessFirewall{}, nil)
+			if test.template {
+				res.lbNameTemplate = tmpl
+			}
+
+			if got := res.loadBalancerName(service); got != test.wantName {
+				t.Errorf("got name %q, want %q", got, test.wantName)
+			}
+			if got := res.loadBalancerNameCandidates(service); !reflect.DeepEqual(got, test.wantCandidates) {
+				t.Errorf("got candidates %v, want %v", got, test.wantCandidates)
+			}
+		})
+	}
+}
+
+func TestUpdateLoadBalancer_rename(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: v1.NamespaceDefault,
+			UID:       "foobar123",
+			Annotations: map[string]string{
+				annDOLoadBalancerID: "load-balancer-id",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{
+				{
+					Name:     "test",
+					Protocol: v1.ProtocolTCP,
+					Port:     80,
+					NodePort: 30000,
+				},
+			},
+		},
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+	}
+
+	var live, updated *godo.LoadBalancer
+	fakeDroplet := &fakeDropletService{
+		listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+			return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+		},
+	}
+	fakeLB := &fakeLBService{
+		getFn: func(context.Context, string) (*godo.LoadBalancer, *godo.Response, error) {
+			return live, newFakeOKResponse(), nil
+		},
+		updateFn: func(_ context.Context, _ string, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+			updated = newLiveLoadBalancer(lbr)
+			return updated, newFakeOKResponse(), nil
+		},
+	}
+	fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(fakeDroplet, fakeLB, nil))
+	fakeResources.kclient = fake.NewSimpleClientset(service)
+	fakeResources.lbNameTemplate, _ = parseLBNameTemplate("prod-{{.Namespace}}-{{.Name}}")
+
+	recorder := record.NewFakeRecorder(10)
+	lbs := &loadBalancers{
+		resources:         fakeResources,
+		region:            "nyc3",
+		lbActiveTimeout:   2,
+		lbActiveCheckTick: 1,
+		recorder:          recorder,
+	}
+
+	req, err := lbs.buildLoadBalancerRequest(context.Background(), service, []*v1.Node{node})
+	if err != nil {
+		t.Fatalf("failed to build load-balancer request: %s", err)
+	}
+	// The load-balancer was created before the template was configured.
+	live = newLiveLoadBalancer(req)
+	live.Name = getLoadBalancerLegacyName(service)
+
+	if err := lbs.UpdateLoadBalancer(context.Background(), "test", service, []*v1.Node{node}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if updated == nil || updated.Name != "prod-default-web" {
+		t.Fatalf("got updated load-balancer %v, want name %q", updated, "prod-default-web")
+	}
+
+	close(recorder.Events)
+	var renamed bool
+	for event := range recorder.Events {
+		if strings.Contains(event, eventReasonLBRenamed) {
+			renamed = true
+		}
+	}
+	if !renamed {
+		t.Errorf("got no %s event", eventReasonLBRenamed)
+	}
+}
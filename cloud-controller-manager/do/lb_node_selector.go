@@ -0,0 +1,74 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// getNodeSelector returns the selector of the nodes that the load-balancer of
+// service targets, or nil if it targets all nodes.
+func getNodeSelector(service *v1.Service) (labels.Selector, error) {
+	raw, ok := service.Annotations[annDONodeSelector]
+	if !ok {
+		return nil, nil
+	}
+	if strings.TrimSpace(raw) == "" {
+		return nil, invalidAnnotation(annDONodeSelector, "annotation %q must not be empty", annDONodeSelector)
+	}
+
+	selector, err := labels.Parse(raw)
+	if err != nil {
+		return nil, invalidAnnotation(annDONodeSelector, "failed to parse node selector %q specified in annotation %q: %s", raw, annDONodeSelector, err)
+	}
+	return selector, nil
+}
+
+// filterNodes returns the nodes matching selector.
+func filterNodes(nodes []*v1.Node, selector labels.Selector) []*v1.Node {
+	var selected []*v1.Node
+	for _, node := range nodes {
+		if selector.Matches(labels.Set(node.Labels)) {
+			selected = append(selected, node)
+		}
+	}
+	return selected
+}
+
+// selectNodes returns the nodes among nodes that the load-balancer of service
+// targets. An error is returned if service selects none of them so that the
+// load-balancer keeps its current droplets rather than losing all of them,
+// which is also recorded as an event if record is set.
+func (l *loadBalancers) selectNodes(service *v1.Service, nodes []*v1.Node, record bool) ([]*v1.Node, error) {
+	selector, err := getNodeSelector(service)
+	if err != nil || selector == nil || len(nodes) == 0 {
+		return nodes, err
+	}
+
+	selected := filterNodes(nodes, selector)
+	if len(selected) == 0 {
+		if record {
+			l.recordEvent(service, v1.EventTypeWarning, eventReasonLBNoNodesSelected, "None of the %d node(s) match node selector %q, keeping the current droplets", len(nodes), selector)
+		}
+		return nil, fmt.Errorf("none of the %d node(s) match node selector %q", len(nodes), selector)
+	}
+	return selected, nil
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func newNodeSelectorTestNode(name, providerID string, nodeLabels map[string]string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: nodeLabels,
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: providerID,
+		},
+	}
+}
+
+func Test_getNodeSelector(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+		wantNil     bool
+		wantErr     bool
+	}{
+		{
+			name:    "no annotation",
+			wantNil: true,
+		},
+		{
+			name: "equality selector",
+			annotations: map[string]string{
+				annDONodeSelector: "pool=web",
+			},
+			want: "pool=web",
+		},
+		{
+			name: "set-based selector",
+			annotations: map[string]string{
+				annDONodeSelector: "pool in (web,api),!draining",
+			},
+			want: "!draining,pool in (api,web)",
+		},
+		{
+			name: "empty annotation",
+			annotations: map[string]string{
+				annDONodeSelector: " ",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid selector",
+			annotations: map[string]string{
+				annDONodeSelector: "pool in (web",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: test.annotations,
+				},
+			}
+
+			got, err := getNodeSelector(service)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if test.wantNil {
+				if got != nil {
+					t.Errorf("got selector %q, want nil", got)
+				}
+				return
+			}
+			if got.String() != test.want {
+				t.Errorf("got selector %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_buildLoadBalancerRequest_nodeSelector(t *testing.T) {
+	nodes := []*v1.Node{
+		newNodeSelectorTestNode("node-1", "digitalocean://100", map[string]string{"pool": "web"}),
+		newNodeSelectorTestNode("node-2", "digitalocean://101", map[string]string{"pool": "db"}),
+		newNodeSelectorTestNode("node-3", "digitalocean://102", map[string]string{"pool": "web"}),
+	}
+
+	tests := []struct {
+		name           string
+		selector       string
+		wantDropletIDs []int
+		wantErr        bool
+		wantEvent      bool
+	}{
+		{
+			name:           "no selector",
+			wantDropletIDs: []int{100, 101, 102},
+		},
+		{
+			name:           "matching nodes",
+			selector:       "pool=web",
+			wantDropletIDs: []int{100, 102},
+		},
+		{
+			name:      "no matching nodes",
+			selector:  "pool=cache",
+			wantErr:   true,
+			wantEvent: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Namespace:   "default",
+					UID:         "abc123",
+					Annotations: map[string]string{},
+				},
+				Spec: v1.ServiceSpec{
+					Type: v1.ServiceTypeLoadBalancer,
+					Ports: []v1.ServicePort{
+						{Name: "test", Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30000},
+					},
+				},
+			}
+			if test.selector != "" {
+				service.Annotations[annDONodeSelector] = test.selector
+			}
+
+			recorder := record.NewFakeRecorder(10)
+			fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(&fakeDropletService{}, &fakeLBService{}, nil))
+			lbs := &loadBalancers{
+				resources:         fakeResources,
+				region:            "nyc3",
+				lbActiveTimeout:   2,
+				lbActiveCheckTick: 1,
+				recorder:          recorder,
+			}
+
+			req, err := lbs.buildLoadBalancerRequest(context.Background(), service, nodes)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(req.DropletIDs, test.wantDropletIDs) {
+				t.Errorf("got droplet IDs %v, want %v", req.DropletIDs, test.wantDropletIDs)
+			}
+
+			close(recorder.Events)
+			var gotEvent bool
+			for event := range recorder.Events {
+				if strings.Contains(event, eventReasonLBNoNodesSelected) {
+					gotEvent = true
+				}
+			}
+			if gotEvent != test.wantEvent {
+				t.Errorf("got %s event: %t, want %t", eventReasonLBNoNodesSelected, gotEvent, test.wantEvent)
+			}
+		})
+	}
+}
+
+func Test_buildLoadBalancerRequest_invalidNodeSelector(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			UID:  "abc123",
+			Annotations: map[string]string{
+				annDONodeSelector: "pool in (web",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{
+				{Name: "test", Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30000},
+			},
+		},
+	}
+
+	if _, err := buildLoadBalancerRequest(context.Background(), service, &godo.Client{}); err == nil {
+		t.Error("expected error for invalid node selector")
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+)
+
+// lbNotOwnedError is returned when a load-balancer referenced by a Service
+// does not belong to the cluster.
+type lbNotOwnedError struct {
+	lb     *godo.LoadBalancer
+	reason string
+}
+
+func (e *lbNotOwnedError) Error() string {
+	return fmt.Sprintf("load-balancer %s (%s) does not belong to this cluster because %s; set annotation %s to %q to adopt it", e.lb.Name, e.lb.ID, e.reason, annDOAdoptLB, e.lb.ID)
+}
+
+// verifyLoadBalancerOwnership returns an error if lb does not belong to the
+// cluster, i.e., it is not tagged with the cluster ID or resides in a VPC
+// other than the cluster's. Load-balancers explicitly adopted by service are
+// considered owned. Ownership cannot be verified without a cluster ID.
+func (r *resources) verifyLoadBalancerOwnership(service *v1.Service, lb *godo.LoadBalancer) error {
+	if r.clusterID == "" || adoptsLoadBalancer(service, lb) {
+		return nil
+	}
+
+	tag := buildK8sTag(r.clusterID)
+	if !slices.Contains(lb.Tags, tag) {
+		return &lbNotOwnedError{lb: lb, reason: fmt.Sprintf("it is not tagged with %q", tag)}
+	}
+
+	return r.verifyLoadBalancerVPC(lb)
+}
+
+// verifyLoadBalancerVPC returns an error if lb resides in a VPC other than the
+// cluster's. Unlike verifyLoadBalancerOwnership, it does not require the
+// cluster ID tag, so it applies to load-balancers created before CCM used
+// cluster IDs.
+func (r *resources) verifyLoadBalancerVPC(lb *godo.LoadBalancer) error {
+	// Global load-balancers do not reside in a VPC.
+	if r.clusterVPCID != "" && lb.VPCUUID != "" && lb.VPCUUID != r.clusterVPCID {
+		return &lbNotOwnedError{lb: lb, reason: fmt.Sprintf("it resides in VPC %s rather than the cluster VPC %s", lb.VPCUUID, r.clusterVPCID)}
+	}
+	return nil
+}
+
+// adoptsLoadBalancer returns whether service explicitly adopts lb by ID or
+// name.
+func adoptsLoadBalancer(service *v1.Service, lb *godo.LoadBalancer) bool {
+	adopt := service.Annotations[annDOAdoptLB]
+	return adopt != "" && (adopt == lb.ID || adopt == lb.Name)
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_verifyLoadBalancerOwnership(t *testing.T) {
+	const vpcID = "cluster-vpc"
+
+	tests := []struct {
+		name        string
+		clusterID   string
+		annotations map[string]string
+		lb          *godo.LoadBalancer
+		wantErr     string
+	}{
+		{
+			name:      "tagged in cluster VPC",
+			clusterID: clusterID,
+			lb:        &godo.LoadBalancer{ID: "lb-id", Tags: []string{clusterIDTag}, VPCUUID: vpcID},
+		},
+		{
+			name:      "global load-balancer",
+			clusterID: clusterID,
+			lb:        &godo.LoadBalancer{ID: "lb-id", Tags: []string{clusterIDTag}},
+		},
+		{
+			name: "no cluster ID",
+			lb:   &godo.LoadBalancer{ID: "lb-id"},
+		},
+		{
+			name:      "untagged",
+			clusterID: clusterID,
+			lb:        &godo.LoadBalancer{ID: "lb-id", Tags: []string{"k8s:other-cluster"}, VPCUUID: vpcID},
+			wantErr:   `it is not tagged with "` + clusterIDTag + `"`,
+		},
+		{
+			name:      "other VPC",
+			clusterID: clusterID,
+			lb:        &godo.LoadBalancer{ID: "lb-id", Tags: []string{clusterIDTag}, VPCUUID: "other-vpc"},
+			wantErr:   "it resides in VPC other-vpc rather than the cluster VPC cluster-vpc",
+		},
+		{
+			name:      "adopted by ID",
+			clusterID: clusterID,
+			annotations: map[string]string{
+				annDOAdoptLB: "lb-id",
+			},
+			lb: &godo.LoadBalancer{ID: "lb-id", VPCUUID: "other-vpc"},
+		},
+		{
+			name:      "adopted by name",
+			clusterID: clusterID,
+			annotations: map[string]string{
+				annDOAdoptLB: "hand-made",
+			},
+			lb: &godo.LoadBalancer{ID: "lb-id", Name: "hand-made"},
+		},
+		{
+			name:      "other load-balancer adopted",
+			clusterID: clusterID,
+			annotations: map[string]string{
+				annDOAdoptLB: "other-lb-id",
+			},
+			lb:      &godo.LoadBalancer{ID: "lb-id"},
+			wantErr: "not tagged",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newResources(test.clusterID, vpcID, publicAccessFirewall{}, nil)
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: test.annotations,
+				},
+			}
+
+			err := r.verifyLoadBalancerOwnership(service, test.lb)
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %s", err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Errorf("got error %v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func Test_foreignLoadBalancerNotMutated(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			UID:  "foobar123",
+			Annotations: map[string]string{
+				annDOLoadBalancerID: "foreign-lb-id",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{
+				{
+					Name:     "test",
+					Protocol: v1.ProtocolTCP,
+					Port:     80,
+					NodePort: 30000,
+				},
+			},
+		},
+	}
+	foreign := &godo.LoadBalancer{
+		ID:     "foreign-lb-id",
+		Name:   "afoobar123",
+		Status: lbStatusActive,
+		Tags:   []string{"k8s:other-cluster"},
+	}
+
+	fakeDroplet := &fakeDropletService{
+		listByTagFunc: func(context.Context, string, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+			return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+		},
+	}
+	fakeLB := &fakeLBService{
+		getFn: func(context.Context, string) (*godo.LoadBalancer, *godo.Response, error) {
+			return foreign, newFakeOKResponse(), nil
+		},
+		updateFn: func(context.Context, string, *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+			t.Fatal("foreign load-balancer must not be updated")
+			return nil, nil, nil
+		},
+		deleteFn: func(context.Context, string) (*godo.Response, error) {
+			t.Fatal("foreign load-balancer must not be deleted")
+			return nil, nil
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	lbs := &loadBalancers{
+		resources:         newResources(clusterID, "", publicAccessFirewall{}, newFakeClient(fakeDroplet, fakeLB, nil)),
+		region:            "nyc3",
+		lbActiveTimeout:   2,
+		lbActiveCheckTick: 1,
+		recorder:          recorder,
+	}
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		},
+	}
+
+	_, err := lbs.updateLoadBalancer(context.Background(), foreign, service, nodes)
+	if _, ok := err.(*lbNotOwnedError); !ok {
+		t.Errorf("got error %v, want ownership error", err)
+	}
+
+	// Deleting the Service is not blocked, but the load-balancer left
+	// behind is reported.
+	if err := lbs.EnsureLoadBalancerDeleted(context.Background(), "test", service); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	close(recorder.Events)
+	var gotDeletionEvent bool
+	for event := range recorder.Events {
+		if strings.HasPrefix(event, v1.EventTypeWarning+" "+eventReasonLBNotOwned+" Refusing to delete") {
+			gotDeletionEvent = true
+		}
+	}
+	if !gotDeletionEvent {
+		t.Errorf("no %s warning event recorded for the refused deletion", eventReasonLBNotOwned)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// portConfig is the forwarding rule configuration of a single Service port
+// as specified in the port config annotation.
+type portConfig struct {
+	EntryProtocol   string `json:"entryProtocol"`
+	TargetProtocol  string `json:"targetProtocol,omitempty"`
+	CertificateID   string `json:"certificateID,omitempty"`
+	CertificateName string `json:"certificateName,omitempty"`
+	TLSPassthrough  bool   `json:"tlsPassthrough,omitempty"`
+}
+
+// getPortConfigs returns the forwarding rule configurations of service keyed
+// by Service port number, or nil if service does not specify any. The
+// annotation is keyed by port name or number; names take precedence.
+func getPortConfigs(service *v1.Service) (map[int32]portConfig, error) {
+	raw, ok := service.Annotations[annDOPortConfig]
+	if !ok {
+		return nil, nil
+	}
+
+	var configs map[string]portConfig
+	if err := yaml.UnmarshalStrict([]byte(raw), &configs); err != nil {
+		return nil, invalidAnnotation(annDOPortConfig, "failed to parse annotation %q: %s", annDOPortConfig, err)
+	}
+
+	// Iterate in a stable order to report the same error every time.
+	keys := make([]string, 0, len(configs))
+	for key := range configs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	byPort := map[int32]portConfig{}
+	for _, key := range keys {
+		port := findServicePort(service, key)
+		if port == nil {
+			return nil, invalidAnnotation(annDOPortConfig, "port %q specified in annotation %q does not match the name or number of any service port", key, annDOPortConfig)
+		}
+		if _, ok := byPort[port.Port]; ok {
+			return nil, invalidAnnotation(annDOPortConfig, "port %d is configured more than once in annotation %q", port.Port, annDOPortConfig)
+		}
+
+		cfg := configs[key]
+		if err := cfg.validate(port); err != nil {
+			return nil, invalidAnnotation(annDOPortConfig, "invalid configuration of port %q in annotation %q: %s", key, annDOPortConfig, err)
+		}
+		byPort[port.Port] = cfg
+	}
+
+	return byPort, nil
+}
+
+// findServicePort returns the port of service with the given name or, if
+// there is none, number.
+func findServicePort(service *v1.Service, key string) *v1.ServicePort {
+	for i, port := range service.Spec.Ports {
+		if port.Name == key {
+			return &service.Spec.Ports[i]
+		}
+	}
+
+	number, err := strconv.Atoi(key)
+	if err != nil {
+		return nil
+	}
+	for i, port := range service.Spec.Ports {
+		if int(port.Port) == number {
+			return &service.Spec.Ports[i]
+		}
+	}
+	return nil
+}
+
+// configuredPorts returns the numbers of the Service ports that have a
+// forwarding rule configuration.
+func configuredPorts(service *v1.Service) []int {
+	configs, _ := getPortConfigs(service)
+
+	var ports []int
+	for port := range configs {
+		ports = append(ports, int(port))
+	}
+	return ports
+}
+
+func (c portConfig) validate(port *v1.ServicePort) error {
+	switch c.EntryProtocol {
+	case protocolTCP, protocolHTTP, protocolHTTPS, protocolHTTP2:
+		if port.Protocol == v1.ProtocolUDP {
+			return fmt.Errorf("entry protocol %q cannot be used for UDP ports", c.EntryProtocol)
+		}
+	case protocolUDP:
+		if port.Protocol != v1.ProtocolUDP {
+			return fmt.Errorf("entry protocol %q can only be used for UDP ports", c.EntryProtocol)
+		}
+	case "":
+		return errors.New("entry protocol is required")
+	default:
+		return fmt.Errorf("invalid entry protocol %q, options are %q, %q, %q, %q, and %q", c.EntryProtocol, protocolTCP, protocolUDP, protocolHTTP, protocolHTTPS, protocolHTTP2)
+	}
+
+	switch c.EntryProtocol {
+	case protocolTCP, protocolUDP:
+		if c.TargetProtocol != "" && c.TargetProtocol != c.EntryProtocol {
+			return fmt.Errorf("target protocol must be %q for entry protocol %q", c.EntryProtocol, c.EntryProtocol)
+		}
+	default:
+		switch c.TargetProtocol {
+		case "", protocolHTTP, protocolHTTPS, protocolHTTP2:
+		default:
+			return fmt.Errorf("invalid target protocol %q for entry protocol %q, options are %q, %q, and %q", c.TargetProtocol, c.EntryProtocol, protocolHTTP, protocolHTTPS, protocolHTTP2)
+		}
+	}
+
+	hasCertificate := c.CertificateID != "" || c.CertificateName != ""
+	if c.CertificateID != "" && c.CertificateName != "" {
+		return errors.New("either certificate ID or certificate name may be set, not both")
+	}
+	if c.EntryProtocol != protocolHTTPS && c.EntryProtocol != protocolHTTP2 {
+		if hasCertificate || c.TLSPassthrough {
+			return fmt.Errorf("certificates and TLS passthrough require entry protocol %q or %q", protocolHTTPS, protocolHTTP2)
+		}
+		return nil
+	}
+
+	if hasCertificate == c.TLSPassthrough {
+		return errors.New("must set either a certificate or enable TLS passthrough")
+	}
+	if c.TLSPassthrough && c.TargetProtocol != "" && c.TargetProtocol != c.EntryProtocol {
+		return fmt.Errorf("target protocol must be %q with TLS passthrough", c.EntryProtocol)
+	}
+	return nil
+}
+
+// targetProtocol returns the target protocol of c. It defaults to http for
+// ports terminating TLS and to the entry protocol otherwise.
+func (c portConfig) targetProtocol() string {
+	if c.TargetProtocol != "" {
+		return c.TargetProtocol
+	}
+	if c.CertificateID != "" || c.CertificateName != "" {
+		return protocolHTTP
+	}
+	return c.EntryProtocol
+}
+
+// buildConfiguredForwardingRule returns the forwarding rule of port as
+// configured by cfg.
+func buildConfiguredForwardingRule(ctx context.Context, port *v1.ServicePort, cfg portConfig, godoClient *godo.Client) (*godo.ForwardingRule, error) {
+	certificateID := cfg.CertificateID
+	if cfg.CertificateName != "" {
+		var err error
+		certificateID, err = findCertificateIDByName(ctx, cfg.CertificateName, godoClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &godo.ForwardingRule{
+		EntryProtocol:  cfg.EntryProtocol,
+		EntryPort:      int(port.Port),
+		TargetProtocol: cfg.targetProtocol(),
+		TargetPort:     int(port.NodePort),
+		CertificateID:  certificateID,
+		TlsPassthrough: cfg.TLSPassthrough,
+	}, nil
+}
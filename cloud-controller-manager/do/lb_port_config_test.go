@@ -0,0 +1,185 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+)
+
+// portConfigTestPorts are the ports of Services used to test port
+// configurations.
+var portConfigTestPorts = []v1.ServicePort{
+	{Name: "http", Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30080},
+	{Name: "https", Protocol: v1.ProtocolTCP, Port: 443, NodePort: 30443},
+	{Name: "admin", Protocol: v1.ProtocolTCP, Port: 8443, NodePort: 38443},
+	{Name: "dns", Protocol: v1.ProtocolUDP, Port: 53, NodePort: 30053},
+}
+
+func Test_getPortConfigs(t *testing.T) {
+	tests := []struct {
+		name       string
+		portConfig string
+		want       map[int32]portConfig
+		wantErr    bool
+	}{
+		{
+			name:       "JSON keyed by number",
+			portConfig: `{"443": {"entryProtocol": "https", "certificateID": "cert-a"}}`,
+			want: map[int32]portConfig{
+				443: {EntryProtocol: "https", CertificateID: "cert-a"},
+			},
+		},
+		{
+			name: "YAML keyed by name",
+			portConfig: `
+admin:
+  entryProtocol: https
+  tlsPassthrough: true
+dns:
+  entryProtocol: udp
+`,
+			want: map[int32]portConfig{
+				8443: {EntryProtocol: "https", TLSPassthrough: true},
+				53:   {EntryProtocol: "udp"},
+			},
+		},
+		{
+			name:       "unknown port",
+			portConfig: `{"8080": {"entryProtocol": "http"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "port configured twice",
+			portConfig: `{"443": {"entryProtocol": "https", "certificateID": "cert-a"}, "https": {"entryProtocol": "https", "certificateID": "cert-b"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "unknown field",
+			portConfig: `{"80": {"entryProtocol": "http", "certificate": "cert-a"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "missing entry protocol",
+			portConfig: `{"80": {"targetProtocol": "http"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "invalid entry protocol",
+			portConfig: `{"80": {"entryProtocol": "gopher"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "UDP protocol for TCP port",
+			portConfig: `{"80": {"entryProtocol": "udp"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "TCP protocol for UDP port",
+			portConfig: `{"dns": {"entryProtocol": "tcp"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "certificate for plain HTTP",
+			portConfig: `{"80": {"entryProtocol": "http", "certificateID": "cert-a"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "HTTPS without certificate or passthrough",
+			portConfig: `{"443": {"entryProtocol": "https"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "certificate and passthrough",
+			portConfig: `{"443": {"entryProtocol": "https", "certificateID": "cert-a", "tlsPassthrough": true}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "certificate ID and name",
+			portConfig: `{"443": {"entryProtocol": "https", "certificateID": "cert-a", "certificateName": "cert-b"}}`,
+			wantErr:    true,
+		},
+		{
+			name:       "passthrough with different target protocol",
+			portConfig: `{"443": {"entryProtocol": "https", "targetProtocol": "http", "tlsPassthrough": true}}`,
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := getPortConfigs(newTestService(map[string]string{annDOPortConfig: test.portConfig}, portConfigTestPorts...))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got port configs %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_buildForwardingRules_portConfig(t *testing.T) {
+	service := newTestService(map[string]string{annDOPortConfig: `
+https:
+  entryProtocol: https
+  certificateID: cert-a
+admin:
+  entryProtocol: http2
+  targetProtocol: https
+  certificateName: admin-cert
+`}, portConfigTestPorts...)
+	service.Annotations[annDOHTTPPorts] = "80"
+	service.Annotations[annDOTLSPassThrough] = "true"
+
+	fakeCert := newKVCertService(map[string]*godo.Certificate{
+		"admin-cert": {ID: "cert-b", Name: "admin-cert"},
+	}, false)
+	gclient := newFakeClient(nil, nil, &fakeCert)
+
+	got, err := buildForwardingRules(context.Background(), service, gclient)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []godo.ForwardingRule{
+		{EntryProtocol: "http", EntryPort: 80, TargetProtocol: "http", TargetPort: 30080},
+		{EntryProtocol: "https", EntryPort: 443, TargetProtocol: "http", TargetPort: 30443, CertificateID: "cert-a"},
+		{EntryProtocol: "http2", EntryPort: 8443, TargetProtocol: "https", TargetPort: 38443, CertificateID: "cert-b"},
+		{EntryProtocol: "udp", EntryPort: 53, TargetProtocol: "udp", TargetPort: 30053},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got forwarding rules %+v, want %+v", got, want)
+	}
+}
+
+func Test_getCertificateIDFromLB_excludedPorts(t *testing.T) {
+	lb := &godo.LoadBalancer{
+		ForwardingRules: []godo.ForwardingRule{
+			{EntryProtocol: "https", EntryPort: 8443, CertificateID: "cert-b"},
+			{EntryProtocol: "https", EntryPort: 443, CertificateID: "cert-a"},
+		},
+	}
+
+	if got := getCertificateIDFromLB(lb, 8443); got != "cert-a" {
+		t.Errorf("got certificate ID %q, want %q", got, "cert-a")
+	}
+}
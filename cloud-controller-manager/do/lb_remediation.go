@@ -0,0 +1,185 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/cloud-provider/api"
+	"k8s.io/klog/v2"
+)
+
+type lbRemediationStrategy string
+
+const (
+	// defaultLBRemediationTimeout is the default time load-balancers may
+	// remain new or errored before they are remediated. Provisioning
+	// load-balancers regularly takes several minutes.
+	defaultLBRemediationTimeout = 10 * time.Minute
+
+	// lbProvisioningRetryDelay is the delay after which the service
+	// controller checks back on load-balancers that are not active yet.
+	lbProvisioningRetryDelay = 15 * time.Second
+)
+
+const (
+	// lbRemediationStrategyAlert only reports load-balancers that are stuck.
+	lbRemediationStrategyAlert lbRemediationStrategy = "alert"
+	// lbRemediationStrategyRetry re-submits the configuration of errored
+	// load-balancers.
+	lbRemediationStrategyRetry lbRemediationStrategy = "retry"
+	// lbRemediationStrategyRecreate deletes stuck load-balancers and creates
+	// new ones.
+	lbRemediationStrategyRecreate lbRemediationStrategy = "recreate"
+)
+
+func parseLBRemediationStrategy(raw string) (lbRemediationStrategy, error) {
+	switch s := lbRemediationStrategy(raw); s {
+	case lbRemediationStrategyAlert, lbRemediationStrategyRetry, lbRemediationStrategyRecreate:
+		return s, nil
+	default:
+		return "", fmt.Errorf("invalid remediation strategy %q, options are %q, %q, and %q", raw, lbRemediationStrategyAlert, lbRemediationStrategyRetry, lbRemediationStrategyRecreate)
+	}
+}
+
+// unhealthyLB records since when a load-balancer has been in a status other
+// than active.
+type unhealthyLB struct {
+	status string
+	since  time.Time
+}
+
+// trackUnhealthy returns how long lb has been in its current, non-active
+// status as far as we know.
+func (l *loadBalancers) trackUnhealthy(lb *godo.LoadBalancer) time.Duration {
+	l.unhealthyMu.Lock()
+	defer l.unhealthyMu.Unlock()
+
+	if l.unhealthyLBs == nil {
+		l.unhealthyLBs = map[string]unhealthyLB{}
+	}
+	u, ok := l.unhealthyLBs[lb.ID]
+	if !ok || u.status != lb.Status {
+		u = unhealthyLB{status: lb.Status, since: time.Now()}
+		l.unhealthyLBs[lb.ID] = u
+	}
+	return time.Since(u.since)
+}
+
+// forgetUnhealthy stops tracking the load-balancer with the given ID.
+func (l *loadBalancers) forgetUnhealthy(id string) {
+	l.unhealthyMu.Lock()
+	defer l.unhealthyMu.Unlock()
+	delete(l.unhealthyLBs, id)
+}
+
+// remediateLoadBalancer handles lb of service if it is not active. Once lb
+// has been new or errored for longer than the remediation timeout, the
+// configured remediation strategy is applied. The returned error asks the
+// service controller to check back later. lbRequest is nil for shared
+// load-balancers, which are only alerted on.
+func (l *loadBalancers) remediateLoadBalancer(ctx context.Context, service *v1.Service, lb *godo.LoadBalancer, lbRequest *godo.LoadBalancerRequest) error {
+	if lb.Status != lbStatusNew && lb.Status != lbStatusErrored {
+		return fmt.Errorf("load-balancer has unexpected status %q", lb.Status)
+	}
+
+	stuckFor := l.trackUnhealthy(lb)
+	timeout := l.remediationTimeout
+	if timeout == 0 {
+		timeout = defaultLBRemediationTimeout
+	}
+	if stuckFor < timeout {
+		if lb.Status == lbStatusNew {
+			l.recordEvent(service, v1.EventTypeNormal, eventReasonLBProvisioning, "Load-balancer %s is still being provisioned", lb.ID)
+			return api.NewRetryError("load-balancer is currently being created", lbProvisioningRetryDelay)
+		}
+		return fmt.Errorf("load-balancer has unexpected status %q", lb.Status)
+	}
+
+	strategy := l.remediationStrategy
+	if strategy == "" {
+		strategy = lbRemediationStrategyAlert
+	}
+	// Retrying only makes sense for errored load-balancers, and we must not
+	// recreate load-balancers that we do not fully manage or share.
+	if strategy == lbRemediationStrategyRetry && lb.Status != lbStatusErrored {
+		strategy = lbRemediationStrategyAlert
+	}
+	if strategy != lbRemediationStrategyAlert && (lbRequest == nil || l.resources.verifyLoadBalancerOwnership(service, lb) != nil || service.Annotations[annDOAdoptLB] != "") {
+		strategy = lbRemediationStrategyAlert
+	}
+	// Recreating the load-balancer loses its IP address, which the Retain
+	// deletion policy is meant to keep.
+	if strategy == lbRemediationStrategyRecreate {
+		if policy, err := getDeletionPolicy(service); err != nil || policy == lbDeletionPolicyRetain {
+			strategy = lbRemediationStrategyAlert
+		}
+	}
+
+	klog.Warningf("Load-balancer %s for service %s/%s has been %s for %s, applying remediation strategy %s", lb.ID, service.Namespace, service.Name, lb.Status, stuckFor.Round(time.Second), strategy)
+	lbRemediationsTotal.WithLabelValues(lb.Status, string(strategy)).Inc()
+
+	switch strategy {
+	case lbRemediationStrategyRetry:
+		l.recordEvent(service, v1.EventTypeWarning, eventReasonLBRemediation, "Load-balancer %s has been %s for %s, re-submitting its configuration", lb.ID, lb.Status, stuckFor.Round(time.Second))
+		// Give the load-balancer another timeout period to recover.
+		l.forgetUnhealthy(lb.ID)
+
+		preserveImmutableFields(lb, lbRequest)
+		updated, _, err := l.resources.gclient.LoadBalancers.Update(ctx, lb.ID, lbRequest)
+		if err != nil {
+			l.resources.invalidateLoadBalancers()
+			l.recordAPIRejection(service, "update", err)
+			return fmt.Errorf("failed to re-submit configuration of load-balancer %s: %s", lb.ID, err)
+		}
+		l.resources.loadBalancerChanged(updated)
+		return api.NewRetryError("load-balancer configuration was re-submitted", lbProvisioningRetryDelay)
+
+	case lbRemediationStrategyRecreate:
+		l.recordEvent(service, v1.EventTypeWarning, eventReasonLBRemediation, "Load-balancer %s has been %s for %s, recreating it", lb.ID, lb.Status, stuckFor.Round(time.Second))
+		l.forgetUnhealthy(lb.ID)
+
+		resp, err := l.resources.gclient.LoadBalancers.Delete(ctx, lb.ID)
+		if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+			l.resources.invalidateLoadBalancers()
+			l.recordAPIRejection(service, "deletion", err)
+			return fmt.Errorf("failed to delete load-balancer %s for recreation: %s", lb.ID, err)
+		}
+		l.resources.loadBalancerDeleted(lb.ID)
+		l.recordEvent(service, v1.EventTypeNormal, eventReasonLBDeleted, "Deleted %s load-balancer %s (%s)", lb.Status, lb.Name, lb.ID)
+
+		created, err := l.createLoadBalancer(ctx, service, lbRequest)
+		if err != nil {
+			// The next reconciliation creates the load-balancer.
+			delete(service.Annotations, annDOLoadBalancerID)
+			return err
+		}
+		updateServiceAnnotation(service, annDOLoadBalancerID, created.ID)
+		return api.NewRetryError("load-balancer was recreated", lbProvisioningRetryDelay)
+
+	default:
+		l.recordEvent(service, v1.EventTypeWarning, eventReasonLBRemediation, "Load-balancer %s has been %s for %s and requires attention", lb.ID, lb.Status, stuckFor.Round(time.Second))
+		// Alert once per timeout period.
+		l.forgetUnhealthy(lb.ID)
+		return fmt.Errorf("load-balancer %s has been %s for %s", lb.ID, lb.Status, stuckFor.Round(time.Second))
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEnsureLoadBalancer_remediation(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		status       string
+		stuckFor     time.Duration
+		strategy     lbRemediationStrategy
+		wantAction   string
+		wantUpdate   bool
+		wantRecreate bool
+		wantEvent    string
+	}{
+		{
+			name:      "new within timeout",
+			status:    lbStatusNew,
+			stuckFor:  time.Minute,
+			strategy:  lbRemediationStrategyRecreate,
+			wantEvent: eventReasonLBProvisioning,
+		},
+		{
+			name:       "alert by default",
+			status:     lbStatusErrored,
+			stuckFor:   time.Hour,
+			wantAction: string(lbRemediationStrategyAlert),
+			wantEvent:  eventReasonLBRemediation,
+		},
+		{
+			name:       "retry errored",
+			status:     lbStatusErrored,
+			stuckFor:   time.Hour,
+			strategy:   lbRemediationStrategyRetry,
+			wantAction: string(lbRemediationStrategyRetry),
+			wantUpdate: true,
+			wantEvent:  eventReasonLBRemediation,
+		},
+		{
+			name:       "retry falls back to alert for new",
+			status:     lbStatusNew,
+			stuckFor:   time.Hour,
+			strategy:   lbRemediationStrategyRetry,
+			wantAction: string(lbRemediationStrategyAlert),
+			wantEvent:  eventReasonLBRemediation,
+		},
+		{
+			name:         "recreate",
+			status:       lbStatusErrored,
+			stuckFor:     time.Hour,
+			strategy:     lbRemediationStrategyRecreate,
+			wantAction:   string(lbRemediationStrategyRecreate),
+			wantRecreate: true,
+			wantEvent:    eventReasonLBRemediation,
+		},
+		{
+			name: "recreate falls back to alert for retained load-balancers",
+			annotations: map[string]string{
+				annDODeletionPolicy: string(lbDeletionPolicyRetain),
+			},
+			status:     lbStatusErrored,
+			stuckFor:   time.Hour,
+			strategy:   lbRemediationStrategyRecreate,
+			wantAction: string(lbRemediationStrategyAlert),
+			wantEvent:  eventReasonLBRemediation,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: v1.NamespaceDefault,
+					UID:       "foobar123",
+					Annotations: map[string]string{
+						annDOLoadBalancerID: "stuck-id",
+					},
+				},
+				Spec: v1.ServiceSpec{
+					Type: v1.ServiceTypeLoadBalancer,
+					Ports: []v1.ServicePort{
+						{
+							Name:     "test",
+							Protocol: v1.ProtocolTCP,
+							Port:     80,
+							NodePort: 30000,
+						},
+					},
+				},
+			}
+			for k, v := range test.annotations {
+				service.Annotations[k] = v
+			}
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			}
+
+			var (
+				live    *godo.LoadBalancer
+				updates int
+				deleted bool
+				created bool
+			)
+			fakeDroplet := &fakeDropletService{
+				listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+					return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+				},
+			}
+			fakeLB := &fakeLBService{
+				getFn: func(context.Context, string) (*godo.LoadBalancer, *godo.Response, error) {
+					return live, newFakeOKResponse(), nil
+				},
+				updateFn: func(_ context.Context, _ string, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+					updates++
+					lb := newLiveLoadBalancer(lbr)
+					lb.ID = "stuck-id"
+					lb.Status = test.status
+					return lb, newFakeOKResponse(), nil
+				},
+				deleteFn: func(context.Context, string) (*godo.Response, error) {
+					deleted = true
+					return newFakeOKResponse(), nil
+				},
+				createFn: func(_ context.Context, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+					created = true
+					lb := newLiveLoadBalancer(lbr)
+					lb.ID = "recreated-id"
+					lb.Status = lbStatusNew
+					return lb, newFakeOKResponse(), nil
+				},
+			}
+			fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(fakeDroplet, fakeLB, nil))
+			fakeResources.kclient = fake.NewSimpleClientset(service)
+
+			recorder := record.NewFakeRecorder(10)
+			lbs := &loadBalancers{
+				resources:           fakeResources,
+				region:              "nyc3",
+				lbActiveTimeout:     2,
+				lbActiveCheckTick:   1,
+				remediationStrategy: test.strategy,
+				recorder:            recorder,
+				unhealthyLBs: map[string]unhealthyLB{
+					"stuck-id": {status: test.status, since: time.Now().Add(-test.stuckFor)},
+				},
+			}
+
+			req, err := lbs.buildLoadBalancerRequest(context.Background(), service, []*v1.Node{node})
+			if err != nil {
+				t.Fatalf("failed to build load-balancer request: %s", err)
+			}
+			live = newLiveLoadBalancer(req)
+			live.ID = "stuck-id"
+			live.Status = test.status
+
+			var actionsBefore float64
+			if test.wantAction != "" {
+				actionsBefore = testutil.ToFloat64(lbRemediationsTotal.WithLabelValues(test.status, test.wantAction))
+			}
+
+			if _, err := lbs.EnsureLoadBalancer(context.Background(), "test", service, []*v1.Node{node}); err == nil {
+				t.Fatal("expected error for non-active load-balancer but got none")
+			}
+
+			if test.wantAction != "" {
+				if got := testutil.ToFloat64(lbRemediationsTotal.WithLabelValues(test.status, test.wantAction)) - actionsBefore; got != 1 {
+					t.Errorf("got %v remediation(s) with action %s, want 1", got, test.wantAction)
+				}
+			}
+			// The regular update is skipped because the configuration is
+			// up-to-date, so any update stems from the remediation.
+			if gotUpdate := updates > 0; gotUpdate != test.wantUpdate {
+				t.Errorf("got %d update(s), want update: %t", updates, test.wantUpdate)
+			}
+			if deleted != test.wantRecreate || created != test.wantRecreate {
+				t.Errorf("got deleted %t and created %t, want recreation: %t", deleted, created, test.wantRecreate)
+			}
+			wantID := "stuck-id"
+			if test.wantRecreate {
+				wantID = "recreated-id"
+			}
+			if got := service.Annotations[annDOLoadBalancerID]; got != wantID {
+				t.Errorf("got load-balancer ID annotation %q, want %q", got, wantID)
+			}
+
+			close(recorder.Events)
+			var found bool
+			for event := range recorder.Events {
+				if strings.Contains(event, test.wantEvent) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("got no %s event", test.wantEvent)
+			}
+		})
+	}
+}
+
+func Test_parseLBRemediationStrategy(t *testing.T) {
+	for _, raw := range []string{"alert", "retry", "recreate"} {
+		if _, err := parseLBRemediationStrategy(raw); err != nil {
+			t.Errorf("unexpected error for strategy %q: %s", raw, err)
+		}
+	}
+	if _, err := parseLBRemediationStrategy("ignore"); err == nil {
+		t.Error("expected error for invalid strategy but got none")
+	}
+}
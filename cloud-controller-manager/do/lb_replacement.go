@@ -0,0 +1,156 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/cloud-provider/api"
+	"k8s.io/klog/v2"
+)
+
+// immutableFieldChanges returns the names of the load-balancer fields that
+// cannot be updated in place and differ between the current and the desired
+// request.
+func immutableFieldChanges(current, desired *godo.LoadBalancerRequest) []string {
+	current = normalizeLoadBalancerRequest(copyLoadBalancerRequest(current))
+	desired = normalizeLoadBalancerRequest(copyLoadBalancerRequest(desired))
+
+	var fields []string
+	if current.Type != desired.Type {
+		fields = append(fields, "Type")
+	}
+	if current.Network != desired.Network {
+		fields = append(fields, "Network")
+	}
+	return fields
+}
+
+// preserveImmutableFields sets the fields of lbRequest that cannot be updated
+// in place to the values of lb so that lb can still be updated while its
+// replacement is pending.
+func preserveImmutableFields(lb *godo.LoadBalancer, lbRequest *godo.LoadBalancerRequest) {
+	lbRequest.Type = lb.Type
+	lbRequest.Network = lb.Network
+}
+
+// replaceLoadBalancer replaces lb with a new load-balancer built from
+// lbRequest because fields that cannot be updated in place changed. The
+// replacement is created next to lb and tracked through an annotation on
+// service. Once it is active, service is switched over to it and lb is
+// deleted (or retained, depending on the deletion policy). Until then, lb is
+// returned along with a retry error.
+func (l *loadBalancers) replaceLoadBalancer(ctx context.Context, service *v1.Service, lb *godo.LoadBalancer, lbRequest *godo.LoadBalancerRequest, fields []string) (*godo.LoadBalancer, error) {
+	if err := l.resources.verifyLoadBalancerOwnership(service, lb); err != nil {
+		l.recordEvent(service, v1.EventTypeWarning, eventReasonLBNotOwned, "Refusing to replace load-balancer: %s", err)
+		return nil, err
+	}
+
+	var replacement *godo.LoadBalancer
+	if replacementID := service.Annotations[annDOLoadBalancerReplacementID]; replacementID != "" {
+		var err error
+		replacement, err = l.findLoadBalancerByID(ctx, replacementID)
+		if err != nil && err != errLBNotFound {
+			return nil, err
+		}
+		if replacement == nil {
+			klog.Warningf("Replacement load-balancer %s for service %s/%s vanished, creating a new one", replacementID, service.Namespace, service.Name)
+		}
+	}
+
+	if replacement == nil {
+		klog.Infof("Replacing load-balancer %s for service %s/%s because immutable field(s) %s changed", lb.ID, service.Namespace, service.Name, strings.Join(fields, ", "))
+		l.recordEvent(service, v1.EventTypeNormal, eventReasonLBReplacing, "Replacing load-balancer %s because immutable field(s) %s changed", lb.ID, strings.Join(fields, ", "))
+
+		var err error
+		replacement, err = l.createLoadBalancer(ctx, service, lbRequest)
+		if err != nil {
+			return nil, err
+		}
+		updateServiceAnnotation(service, annDOLoadBalancerReplacementID, replacement.ID)
+	}
+
+	switch replacement.Status {
+	case lbStatusActive:
+	case lbStatusNew:
+		l.recordEvent(service, v1.EventTypeNormal, eventReasonLBProvisioning, "Replacement load-balancer %s is still being provisioned", replacement.ID)
+		return nil, api.NewRetryError("replacement load-balancer is currently being created", 15*time.Second)
+	default:
+		return nil, fmt.Errorf("replacement load-balancer %s has unexpected status %q", replacement.ID, replacement.Status)
+	}
+
+	updateServiceAnnotation(service, annDOLoadBalancerID, replacement.ID)
+	delete(service.Annotations, annDOLoadBalancerReplacementID)
+	klog.Infof("Switched service %s/%s from load-balancer %s to replacement %s", service.Namespace, service.Name, lb.ID, replacement.ID)
+	l.recordEvent(service, v1.EventTypeNormal, eventReasonLBReplaced, "Switched from load-balancer %s to replacement %s", lb.ID, replacement.ID)
+
+	// The Service has been switched over already, so failing to get rid of
+	// the old load-balancer is not fatal: it is left to the garbage
+	// collector.
+	if err := l.releaseReplacedLoadBalancer(ctx, service, lb); err != nil {
+		klog.Errorf("Failed to release replaced load-balancer %s for service %s/%s: %s", lb.ID, service.Namespace, service.Name, err)
+		l.recordEvent(service, v1.EventTypeWarning, eventReasonAPIRejected, "Failed to release replaced load-balancer %s: %s", lb.ID, err)
+	}
+
+	return replacement, nil
+}
+
+// releaseReplacedLoadBalancer deletes lb after it was replaced, or retains
+// it if the deletion policy of service says so.
+func (l *loadBalancers) releaseReplacedLoadBalancer(ctx context.Context, service *v1.Service, lb *godo.LoadBalancer) error {
+	deletionPolicy, err := getDeletionPolicy(service)
+	if err != nil {
+		return err
+	}
+	if deletionPolicy == lbDeletionPolicyRetain {
+		return l.retainLoadBalancer(ctx, service, lb)
+	}
+
+	resp, err := l.resources.gclient.LoadBalancers.Delete(ctx, lb.ID)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		l.resources.invalidateLoadBalancers()
+		return fmt.Errorf("failed to delete load-balancer: %s", err)
+	}
+	l.resources.loadBalancerDeleted(lb.ID)
+	if err == nil {
+		l.recordEvent(service, v1.EventTypeNormal, eventReasonLBDeleted, "Deleted replaced load-balancer %s (%s)", lb.Name, lb.ID)
+	}
+	return nil
+}
+
+// deleteReplacementLoadBalancer deletes the pending replacement of the
+// load-balancer of service, if any.
+func (l *loadBalancers) deleteReplacementLoadBalancer(ctx context.Context, service *v1.Service) error {
+	replacementID := service.Annotations[annDOLoadBalancerReplacementID]
+	if replacementID == "" {
+		return nil
+	}
+
+	resp, err := l.resources.gclient.LoadBalancers.Delete(ctx, replacementID)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		l.resources.invalidateLoadBalancers()
+		return fmt.Errorf("failed to delete replacement load-balancer: %s", err)
+	}
+	l.resources.loadBalancerDeleted(replacementID)
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_immutableFieldChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		current *godo.LoadBalancerRequest
+		desired *godo.LoadBalancerRequest
+		want    []string
+	}{
+		{
+			name:    "defaults",
+			current: &godo.LoadBalancerRequest{},
+			desired: &godo.LoadBalancerRequest{
+				Type:    godo.LoadBalancerTypeRegional,
+				Network: godo.LoadBalancerNetworkTypeExternal,
+			},
+		},
+		{
+			name: "type changed",
+			current: &godo.LoadBalancerRequest{
+				Type: godo.LoadBalancerTypeRegional,
+			},
+			desired: &godo.LoadBalancerRequest{
+				Type: godo.LoadBalancerTypeRegionalNetwork,
+			},
+			want: []string{"Type"},
+		},
+		{
+			name: "network changed",
+			current: &godo.LoadBalancerRequest{
+				Network: godo.LoadBalancerNetworkTypeExternal,
+			},
+			desired: &godo.LoadBalancerRequest{
+				Network: godo.LoadBalancerNetworkTypeInternal,
+			},
+			want: []string{"Network"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := immutableFieldChanges(test.current, test.desired); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got fields %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnsureLoadBalancer_replacement(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: v1.NamespaceDefault,
+			UID:       "foobar123",
+			Annotations: map[string]string{
+				annDOLoadBalancerID: "old-id",
+				annDONetwork:        godo.LoadBalancerNetworkTypeInternal,
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{
+				{
+					Name:     "test",
+					Protocol: v1.ProtocolTCP,
+					Port:     80,
+					NodePort: 30000,
+				},
+			},
+		},
+	}
+	nodes := []*v1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		},
+	}
+
+	lbs := map[string]*godo.LoadBalancer{
+		"old-id": {
+			ID:      "old-id",
+			Name:    "afoobar123",
+			IP:      "10.0.0.1",
+			Status:  lbStatusActive,
+			Network: godo.LoadBalancerNetworkTypeExternal,
+		},
+	}
+	var (
+		creates int
+		deleted []string
+	)
+	fakeDroplet := &fakeDropletService{
+		listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+			return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+		},
+	}
+	fakeLB := &fakeLBService{
+		getFn: func(_ context.Context, id string) (*godo.LoadBalancer, *godo.Response, error) {
+			lb, ok := lbs[id]
+			if !ok {
+				return nil, newFakeNotFoundResponse(), newFakeNotFoundErrorResponse()
+			}
+			return lb, newFakeOKResponse(), nil
+		},
+		createFn: func(_ context.Context, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+			creates++
+			lbs["new-id"] = &godo.LoadBalancer{
+				ID:      "new-id",
+				Name:    lbr.Name,
+				IP:      "10.0.0.2",
+				Status:  lbStatusNew,
+				Network: lbr.Network,
+			}
+			return lbs["new-id"], newFakeOKResponse(), nil
+		},
+		updateFn: func(context.Context, string, *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+			t.Fatal("load-balancer must not be updated")
+			return nil, nil, nil
+		},
+		deleteFn: func(_ context.Context, id string) (*godo.Response, error) {
+			deleted = append(deleted, id)
+			delete(lbs, id)
+			return newFakeOKResponse(), nil
+		},
+	}
+	fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(fakeDroplet, fakeLB, nil))
+	fakeResources.kclient = fake.NewSimpleClientset(service)
+	l := &loadBalancers{
+		resources:         fakeResources,
+		region:            "nyc3",
+		lbActiveTimeout:   2,
+		lbActiveCheckTick: 1,
+	}
+
+	// The replacement is created but not active yet.
+	if _, err := l.EnsureLoadBalancer(context.Background(), "test", service, nodes); err == nil {
+		t.Fatal("expected retry error while replacement is provisioning but got none")
+	}
+	if creates != 1 {
+		t.Fatalf("got %d creation(s), want 1", creates)
+	}
+	if got := service.Annotations[annDOLoadBalancerReplacementID]; got != "new-id" {
+		t.Fatalf("got replacement ID annotation %q, want %q", got, "new-id")
+	}
+	if got := service.Annotations[annDOLoadBalancerID]; got != "old-id" {
+		t.Fatalf("got load-balancer ID annotation %q, want %q", got, "old-id")
+	}
+	if len(deleted) > 0 {
+		t.Fatalf("got deleted load-balancers %v, want none", deleted)
+	}
+
+	// The replacement became active.
+	lbs["new-id"].Status = lbStatusActive
+	status, err := l.EnsureLoadBalancer(context.Background(), "test", service, nodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if creates != 1 {
+		t.Errorf("got %d creation(s), want 1", creates)
+	}
+	if status == nil || len(status.Ingress) != 1 || status.Ingress[0].IP != "10.0.0.2" {
+		t.Errorf("got status %v, want ingress IP %s", status, "10.0.0.2")
+	}
+	if got := service.Annotations[annDOLoadBalancerID]; got != "new-id" {
+		t.Errorf("got load-balancer ID annotation %q, want %q", got, "new-id")
+	}
+	if _, ok := service.Annotations[annDOLoadBalancerReplacementID]; ok {
+		t.Error("got replacement ID annotation, want none")
+	}
+	if !reflect.DeepEqual(deleted, []string{"old-id"}) {
+		t.Errorf("got deleted load-balancers %v, want %v", deleted, []string{"old-id"})
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/digitalocean/godo"
 	"github.com/go-logr/logr"
@@ -34,10 +36,11 @@ type LBServiceAdmissionHandler struct {
 	log        *logr.Logger
 	godoClient *godo.Client
 
-	decoder   admission.Decoder
-	region    string
-	clusterID string
-	vpcID     string
+	decoder     admission.Decoder
+	region      string
+	clusterID   string
+	vpcID       string
+	lbInventory *lbInventory
 }
 
 // NewLBServiceAdmissionHandler returns a configured instance of LBServiceHandler.
@@ -88,7 +91,7 @@ func (h *LBServiceAdmissionHandler) handle(ctx context.Context, req admission.Re
 	var resp admission.Response
 	switch {
 	case req.OldObject.Raw != nil:
-		resp = h.validateUpdate(ctx, req, lbID, lbReq)
+		resp = h.validateUpdate(ctx, req, &svc, lbID, lbReq)
 	default:
 		resp = h.validateCreate(ctx, lbReq)
 	}
@@ -96,7 +99,7 @@ func (h *LBServiceAdmissionHandler) handle(ctx context.Context, req admission.Re
 	return resp
 }
 
-func (h *LBServiceAdmissionHandler) validateUpdate(ctx context.Context, req admission.Request, lbID string, lbReq *godo.LoadBalancerRequest) admission.Response {
+func (h *LBServiceAdmissionHandler) validateUpdate(ctx context.Context, req admission.Request, svc *corev1.Service, lbID string, lbReq *godo.LoadBalancerRequest) admission.Response {
 	var oldSvc corev1.Service
 	if err := h.decoder.DecodeRaw(req.OldObject, &oldSvc); err != nil {
 		return admission.Errored(http.StatusBadRequest, fmt.Errorf("failed to decode old object: %s", err))
@@ -111,16 +114,29 @@ func (h *LBServiceAdmissionHandler) validateUpdate(ctx context.Context, req admi
 	}
 
 	// We prefer the new LB ID if it is set. If not, we fallback to the old
-	// service's LB ID. If the old and new entities don't have an LB id, we
-	// fallback to the creation validation.
+	// service's LB ID and then to the ID of an existing LB going by the
+	// requested name. If none of them yield an LB id, we fallback to the
+	// creation validation.
 	reqLbID := lbID
 	if reqLbID == "" {
 		reqLbID = oldSvc.Annotations[annDOLoadBalancerID]
 	}
+	if reqLbID == "" {
+		reqLbID = h.findLoadBalancerIDByName(svc, lbReq.Name)
+	}
 	if reqLbID == "" {
 		return h.validateCreate(ctx, lbReq)
 	}
 
+	// Changes to immutable fields are applied by replacing the load
+	// balancer, so validate them as a creation.
+	if oldReq != nil {
+		if fields := immutableFieldChanges(oldReq, lbReq); len(fields) > 0 {
+			resp := h.validateCreate(ctx, lbReq)
+			return resp.WithWarnings(fmt.Sprintf("changing the load balancer field(s) %s replaces load balancer %s: a new load balancer with a new IP address is created and the existing one is deleted once the new one is active", strings.Join(fields, ", "), reqLbID))
+		}
+	}
+
 	_, resp, err := h.godoClient.LoadBalancers.Update(ctx, reqLbID, lbReq)
 	return h.mapGodoRespToAdmissionResp(resp, err)
 }
@@ -136,14 +152,46 @@ func (h *LBServiceAdmissionHandler) buildLoadBalancerRequest(ctx context.Context
 		return nil, fmt.Errorf("failed to build base load balancer request: %s", err)
 	}
 	lbReq.ValidateOnly = true
-	lbReq.Region = h.region
-	lbReq.VPCUUID = h.vpcID
+	setLoadBalancerPlacement(lbReq, h.region, h.vpcID)
 	if h.clusterID != "" {
 		lbReq.Tags = []string{buildK8sTag(h.clusterID)}
 	}
 	return lbReq, nil
 }
 
+// findLoadBalancerIDByName returns the ID of the load balancer of svc going by
+// name if the handler has a load balancer inventory. Only load balancers that
+// belong to the cluster are considered, and names shared by several of them
+// are treated as a miss.
+//
+// Listing all load balancers can take longer than the admission timeout, so
+// lookups never wait for the inventory to be refreshed. Names are treated as
+// a miss until the inventory is first populated.
+func (h *LBServiceAdmissionHandler) findLoadBalancerIDByName(svc *corev1.Service, name string) string {
+	if h.lbInventory == nil {
+		return ""
+	}
+	h.lbInventory.refreshInBackground()
+
+	ownership := &resources{clusterID: h.clusterID, clusterVPCID: h.vpcID}
+	var ids []string
+	for _, lb := range h.lbInventory.lookupAllByName(name) {
+		if ownership.verifyLoadBalancerOwnership(svc, &lb) == nil {
+			ids = append(ids, lb.ID)
+		}
+	}
+
+	switch len(ids) {
+	case 0:
+		return ""
+	case 1:
+		return ids[0]
+	default:
+		h.log.Info("ignoring load balancer name shared by multiple load balancers", "name", name, "ids", ids)
+		return ""
+	}
+}
+
 // mapGodoRespToAdmissionResp converts godo responses to admission responses. The returned admission response
 // has to be permissive enough to allow validation requests when unexpected errors happen. Webhook definitions
 // with a failure policy set to `Ignore` will reject `admission.Errored(...)` and `admission.Denied(...)` responses.
@@ -181,3 +229,11 @@ func (a *LBServiceAdmissionHandler) WithVPCID(vpcID string) {
 func (a *LBServiceAdmissionHandler) WithClusterID(clusterID string) {
 	a.clusterID = clusterID
 }
+
+// WithLBInventory makes the handler look up existing load balancers by name
+// from an in-memory inventory that is refreshed in the background once it is
+// older than maxAge. The inventory starts to be populated right away.
+func (a *LBServiceAdmissionHandler) WithLBInventory(maxAge time.Duration) {
+	a.lbInventory = newLBInventory(a.godoClient, maxAge)
+	a.lbInventory.refreshInBackground()
+}
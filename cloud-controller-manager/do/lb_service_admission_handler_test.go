@@ -31,6 +31,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -42,6 +43,7 @@ func TestHandle(t *testing.T) {
 		givenGodoUpdateFn func(ctx context.Context, lbID string, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error)
 		expectedAllowed   bool
 		expectedMessage   string
+		expectedWarning   bool
 	}{
 		{
 			name:            "error if the admission request is not a proper service",
@@ -99,6 +101,24 @@ func TestHandle(t *testing.T) {
 			expectedAllowed: false,
 			expectedMessage: "failed to build DO API request: failed to build base load balancer request: failed to parse health check interval annotation \"service.beta.kubernetes.io/do-loadbalancer-healthcheck-check-interval-seconds\": strconv.Atoi: parsing \"abc\": invalid syntax",
 		},
+		{
+			name: "error create when port config is invalid",
+			req: fakeAdmissionRequest(&corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						annDOPortConfig: `{"443": {"entryProtocol": "https"}}`,
+					},
+				},
+				Spec: corev1.ServiceSpec{
+					Type: corev1.ServiceTypeLoadBalancer,
+					Ports: []corev1.ServicePort{
+						{Protocol: corev1.ProtocolTCP, Port: 443, NodePort: 30443},
+					},
+				},
+			}, nil),
+			expectedAllowed: false,
+			expectedMessage: "failed to build DO API request: failed to build base load balancer request: invalid configuration of port \"443\" in annotation \"service.kubernetes.io/do-loadbalancer-port-config\": must set either a certificate or enable TLS passthrough",
+		},
 		{
 			name: "error create when godo answers has no resp and error",
 			req:  fakeAdmissionRequest(fakeService(), nil),
@@ -386,6 +406,43 @@ func TestHandle(t *testing.T) {
 			expectedAllowed: true,
 			expectedMessage: "valid load balancer definition",
 		},
+		{
+			name: "warn about replacement when immutable fields change",
+			req: fakeAdmissionRequest(
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							annDOLoadBalancerID: "lbid",
+							annDONetwork:        godo.LoadBalancerNetworkTypeInternal,
+						},
+					},
+					Spec: corev1.ServiceSpec{
+						Type: corev1.ServiceTypeLoadBalancer,
+						Ports: []corev1.ServicePort{
+							{Protocol: "TCP", Port: 8080},
+						},
+					},
+				},
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							annDOLoadBalancerID: "lbid",
+						},
+					},
+					Spec: corev1.ServiceSpec{
+						Type: corev1.ServiceTypeLoadBalancer,
+						Ports: []corev1.ServicePort{
+							{Protocol: "TCP", Port: 8080},
+						},
+					},
+				}),
+			givenGodoCreateFn: func(ctx context.Context, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+				return nil, &godo.Response{Response: &http.Response{StatusCode: http.StatusNoContent}}, nil
+			},
+			expectedAllowed: true,
+			expectedMessage: "valid load balancer definition",
+			expectedWarning: true,
+		},
 	}
 
 	for _, tc := range testcases {
@@ -408,10 +465,126 @@ func TestHandle(t *testing.T) {
 			if resp.Allowed != tc.expectedAllowed {
 				t.Fatalf("expected %s to equal %v, got %v", "allowed", tc.expectedAllowed, resp.Allowed)
 			}
+			if gotWarning := len(resp.Warnings) > 0; gotWarning != tc.expectedWarning {
+				t.Fatalf("expected %s to equal %v, got %q", "warning", tc.expectedWarning, resp.Warnings)
+			}
 		})
 	}
 }
 
+func Test_findLoadBalancerIDByName(t *testing.T) {
+	clusterTag := buildK8sTag("cluster")
+	testcases := []struct {
+		name      string
+		clusterID string
+		adopt     string
+		lbs       []godo.LoadBalancer
+		want      string
+	}{
+		{
+			name:      "owned load balancer",
+			clusterID: "cluster",
+			lbs:       []godo.LoadBalancer{{ID: "1", Name: "lb", Tags: []string{clusterTag}, VPCUUID: "vpc"}},
+			want:      "1",
+		},
+		{
+			name:      "load balancer not tagged with the cluster ID",
+			clusterID: "cluster",
+			lbs:       []godo.LoadBalancer{{ID: "1", Name: "lb", VPCUUID: "vpc"}},
+		},
+		{
+			name:      "load balancer in another VPC",
+			clusterID: "cluster",
+			lbs:       []godo.LoadBalancer{{ID: "1", Name: "lb", Tags: []string{clusterTag}, VPCUUID: "other-vpc"}},
+		},
+		{
+			name:      "adopted load balancer",
+			clusterID: "cluster",
+			adopt:     "1",
+			lbs:       []godo.LoadBalancer{{ID: "1", Name: "lb", VPCUUID: "vpc"}},
+			want:      "1",
+		},
+		{
+			name: "no cluster ID",
+			lbs:  []godo.LoadBalancer{{ID: "1", Name: "lb", VPCUUID: "vpc"}},
+			want: "1",
+		},
+		{
+			name:      "name shared with a load balancer of another cluster",
+			clusterID: "cluster",
+			lbs: []godo.LoadBalancer{
+				{ID: "1", Name: "lb", Tags: []string{buildK8sTag("other")}, VPCUUID: "vpc"},
+				{ID: "2", Name: "lb", Tags: []string{clusterTag}, VPCUUID: "vpc"},
+			},
+			want: "2",
+		},
+		{
+			name:      "name shared by owned load balancers",
+			clusterID: "cluster",
+			lbs: []godo.LoadBalancer{
+				{ID: "1", Name: "lb", Tags: []string{clusterTag}, VPCUUID: "vpc"},
+				{ID: "2", Name: "lb", Tags: []string{clusterTag}, VPCUUID: "vpc"},
+			},
+		},
+		{
+			name:      "unknown name",
+			clusterID: "cluster",
+			lbs:       []godo.LoadBalancer{{ID: "1", Name: "other", Tags: []string{clusterTag}, VPCUUID: "vpc"}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			godoClient := newFakeLBClient(&fakeLBService{
+				listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+					return tc.lbs, newFakeOKResponse(), nil
+				},
+			})
+			admissionHandler := NewLBServiceAdmissionHandler(&logr.Logger{}, godoClient)
+			admissionHandler.WithClusterID(tc.clusterID)
+			admissionHandler.WithVPCID("vpc")
+			admissionHandler.lbInventory = newLBInventory(godoClient, time.Hour)
+			if err := admissionHandler.lbInventory.refresh(context.Background()); err != nil {
+				t.Fatalf("failed to refresh inventory: %s", err)
+			}
+
+			svc := fakeService()
+			if tc.adopt != "" {
+				svc.Annotations = map[string]string{annDOAdoptLB: tc.adopt}
+			}
+
+			if got := admissionHandler.findLoadBalancerIDByName(svc, "lb"); got != tc.want {
+				t.Errorf("got load balancer ID %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_findLoadBalancerIDByName_coldInventory(t *testing.T) {
+	release := make(chan struct{})
+	godoClient := newFakeLBClient(&fakeLBService{
+		listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+			<-release
+			return []godo.LoadBalancer{{ID: "1", Name: "lb"}}, newFakeOKResponse(), nil
+		},
+	})
+	admissionHandler := NewLBServiceAdmissionHandler(&logr.Logger{}, godoClient)
+	admissionHandler.WithLBInventory(time.Hour)
+
+	// The lookup must not wait for all load balancers to be listed.
+	if got := admissionHandler.findLoadBalancerIDByName(fakeService(), "lb"); got != "" {
+		t.Errorf("got load balancer ID %q from a cold inventory, want none", got)
+	}
+	close(release)
+
+	err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		return admissionHandler.findLoadBalancerIDByName(fakeService(), "lb") == "1", nil
+	})
+	if err != nil {
+		t.Errorf("load balancer was not found once the inventory was populated: %s", err)
+	}
+}
+
 func fakeAdmissionRequest(newSvc *corev1.Service, oldSvc *corev1.Service) admission.Request {
 	var (
 		m []byte
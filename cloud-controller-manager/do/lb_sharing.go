@@ -0,0 +1,362 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// getSharingGroup returns the sharing group of service, or an empty string if
+// service does not share its load-balancer.
+func getSharingGroup(service *v1.Service) (string, error) {
+	group := service.Annotations[annDOSharingGroup]
+	if group == "" {
+		return "", nil
+	}
+	if errs := validation.IsDNS1123Label(group); len(errs) > 0 {
+		return "", invalidAnnotation(annDOSharingGroup, "invalid sharing group %q specified in annotation %q: %s", group, annDOSharingGroup, strings.Join(errs, ", "))
+	}
+	if service.Annotations[annDOAdoptLB] != "" {
+		return "", invalidAnnotation(annDOSharingGroup, "annotation %q cannot be combined with annotation %q", annDOSharingGroup, annDOAdoptLB)
+	}
+	return group, nil
+}
+
+// sharedLoadBalancerName returns the name of the load-balancer shared by the
+// group in namespace. The hash keeps the names of groups of different
+// clusters apart.
+func (r *resources) sharedLoadBalancerName(namespace, group string) string {
+	sum := sha256.Sum256([]byte(r.clusterID + "/" + namespace + "/" + group))
+	return fmt.Sprintf("shared-%s-%s-%x", namespace, group, sum[:4])
+}
+
+// sharingGroupMembers returns the Services other than service that belong to
+// group in the namespace of service, ordered by age.
+func (l *loadBalancers) sharingGroupMembers(service *v1.Service, group string) ([]*v1.Service, error) {
+	if l.svcLister == nil {
+		return nil, errors.New("service lister is not initialized")
+	}
+	svcs, err := l.svcLister.Services(service.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %s", err)
+	}
+
+	var members []*v1.Service
+	for _, svc := range svcs {
+		if svc.UID == service.UID || svc.Spec.Type != v1.ServiceTypeLoadBalancer || svc.DeletionTimestamp != nil {
+			continue
+		}
+		if g, err := getSharingGroup(svc); err != nil || g != group {
+			continue
+		}
+		// Objects returned by the lister must not be modified.
+		members = append(members, svc.DeepCopy())
+	}
+	sortSharingGroupMembers(members)
+	return members, nil
+}
+
+// lockSharingGroup serializes changes to the load-balancer of group in
+// namespace so that its members do not create or update it concurrently. The
+// returned function unlocks the group.
+func (l *loadBalancers) lockSharingGroup(namespace, group string) func() {
+	key := namespace + "/" + group
+
+	l.sharingMu.Lock()
+	if l.sharingLocks == nil {
+		l.sharingLocks = map[string]*sync.Mutex{}
+	}
+	mu, ok := l.sharingLocks[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		l.sharingLocks[key] = mu
+	}
+	l.sharingMu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// sortSharingGroupMembers orders members by age and name. The oldest member
+// determines the settings of the shared load-balancer and wins port
+// conflicts.
+func sortSharingGroupMembers(members []*v1.Service) {
+	sort.SliceStable(members, func(i, j int) bool {
+		ti, tj := members[i].CreationTimestamp, members[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return members[i].Name < members[j].Name
+	})
+}
+
+// buildSharedLoadBalancerRequest returns the request for the load-balancer
+// shared by members. It is built from the request of the oldest member whose
+// forwarding rules are replaced by the union of those of all members. Members
+// whose request cannot be built or whose entry ports are in use by an older
+// member are left out and returned along with the reason. The returned
+// request is nil if no member is left.
+func (l *loadBalancers) buildSharedLoadBalancerRequest(ctx context.Context, namespace, group string, members []*v1.Service, nodes []*v1.Node) (*godo.LoadBalancerRequest, map[string]error) {
+	requests, failed := l.buildSharingGroupRequests(ctx, members, nodes)
+	shared, excluded := l.mergeSharedLoadBalancerRequest(namespace, group, members, requests)
+	for name, err := range failed {
+		excluded[name] = err
+	}
+	return shared, excluded
+}
+
+// buildSharingGroupRequests builds the load-balancer request of each of
+// members. Members whose request cannot be built are returned along with the
+// reason.
+func (l *loadBalancers) buildSharingGroupRequests(ctx context.Context, members []*v1.Service, nodes []*v1.Node) (map[string]*godo.LoadBalancerRequest, map[string]error) {
+	requests := map[string]*godo.LoadBalancerRequest{}
+	failed := map[string]error{}
+	for _, member := range members {
+		lbRequest, err := l.buildLoadBalancerRequest(ctx, member, nodes)
+		if err != nil {
+			failed[member.Name] = fmt.Errorf("failed to build load-balancer request: %w", err)
+			continue
+		}
+		requests[member.Name] = lbRequest
+	}
+	return requests, failed
+}
+
+// mergeSharedLoadBalancerRequest merges the requests of members into the
+// request for their shared load-balancer. Members without a request are
+// skipped, and those whose entry ports are in use by an older member are
+// returned along with the conflict.
+func (l *loadBalancers) mergeSharedLoadBalancerRequest(namespace, group string, members []*v1.Service, requests map[string]*godo.LoadBalancerRequest) (*godo.LoadBalancerRequest, map[string]error) {
+	var shared *godo.LoadBalancerRequest
+	excluded := map[string]error{}
+	portOwners := map[int]string{}
+
+	for _, member := range members {
+		lbRequest, ok := requests[member.Name]
+		if !ok {
+			continue
+		}
+
+		var conflicts []string
+		for _, rule := range lbRequest.ForwardingRules {
+			if owner, ok := portOwners[rule.EntryPort]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("port %d is in use by service %s", rule.EntryPort, owner))
+			}
+		}
+		if len(conflicts) > 0 {
+			excluded[member.Name] = fmt.Errorf("conflicts with sharing group %q: %s", group, strings.Join(conflicts, ", "))
+			continue
+		}
+		for _, rule := range lbRequest.ForwardingRules {
+			portOwners[rule.EntryPort] = member.Name
+		}
+
+		if shared == nil {
+			shared = lbRequest
+			shared.Name = l.resources.sharedLoadBalancerName(namespace, group)
+			continue
+		}
+		shared.ForwardingRules = append(shared.ForwardingRules, lbRequest.ForwardingRules...)
+	}
+
+	return shared, excluded
+}
+
+// findSharedLoadBalancer returns the load-balancer of group in namespace by
+// the ID recorded on one of services or by name, or nil if there is none.
+func (l *loadBalancers) findSharedLoadBalancer(ctx context.Context, namespace, group string, services []*v1.Service) (*godo.LoadBalancer, error) {
+	for _, svc := range services {
+		id := getLoadBalancerID(svc)
+		if id == "" || svc.Annotations[annDOLoadBalancerSharingGroup] != group {
+			continue
+		}
+		lb, err := l.findLoadBalancerByID(ctx, id)
+		switch err {
+		case nil:
+			return lb, nil
+		case errLBNotFound:
+			continue
+		default:
+			return nil, err
+		}
+	}
+
+	return l.resources.loadBalancerByName(ctx, l.resources.sharedLoadBalancerName(namespace, group))
+}
+
+// syncSharedLoadBalancer converges lb, which may be nil if it does not exist
+// yet, to lbRequest, the request merged from members, on behalf of service.
+// The droplets of lb are kept if nodes is nil. lb is deleted if no member is
+// left.
+func (l *loadBalancers) syncSharedLoadBalancer(ctx context.Context, service *v1.Service, lb *godo.LoadBalancer, group string, members []*v1.Service, lbRequest *godo.LoadBalancerRequest, nodes []*v1.Node) (*godo.LoadBalancer, error) {
+	if lbRequest == nil {
+		// Members whose request cannot be built still hold on to lb.
+		if lb == nil || len(members) > 0 {
+			return lb, nil
+		}
+		klog.Infof("Deleting load-balancer %s of sharing group %s/%s because it has no members left", lb.ID, service.Namespace, group)
+		return nil, l.deleteLoadBalancer(ctx, service, lb)
+	}
+
+	if lb == nil {
+		return l.createLoadBalancer(ctx, service, lbRequest)
+	}
+
+	if err := l.resources.verifyLoadBalancerOwnership(service, lb); err != nil {
+		l.recordEvent(service, v1.EventTypeWarning, eventReasonLBNotOwned, "Refusing to update load-balancer: %s", err)
+		return nil, err
+	}
+	if nodes == nil && lbRequest.Tag == "" {
+		lbRequest.DropletIDs = lb.DropletIDs
+	}
+	preserveImmutableFields(lb, lbRequest)
+
+	return l.applyLoadBalancerRequest(ctx, service, lb, lbRequest)
+}
+
+// ensureSharedLoadBalancer ensures that service is served by the
+// load-balancer of group.
+func (l *loadBalancers) ensureSharedLoadBalancer(ctx context.Context, service *v1.Service, group string, nodes []*v1.Node) (*godo.LoadBalancer, error) {
+	others, err := l.sharingGroupMembers(service, group)
+	if err != nil {
+		return nil, err
+	}
+	members := append([]*v1.Service{service}, others...)
+	sortSharingGroupMembers(members)
+
+	// The request of each member is built once per sync, before the group is
+	// locked.
+	requests, failed := l.buildSharingGroupRequests(ctx, members, nodes)
+	// Report invalid annotations before they are mistaken for conflicts.
+	if err, ok := failed[service.Name]; ok {
+		l.recordBuildFailure(service, err)
+		return nil, err
+	}
+	lbRequest, excluded := l.mergeSharedLoadBalancerRequest(service.Namespace, group, members, requests)
+
+	unlock := l.lockSharingGroup(service.Namespace, group)
+	defer unlock()
+
+	lb, err := l.findSharedLoadBalancer(ctx, service.Namespace, group, members)
+	if err != nil {
+		return nil, err
+	}
+
+	lb, err = l.syncSharedLoadBalancer(ctx, service, lb, group, members, lbRequest, nodes)
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := excluded[service.Name]; ok {
+		l.recordEvent(service, v1.EventTypeWarning, eventReasonLBSharingConflict, "Not joining load-balancer of sharing group %q: %s", group, err)
+		return nil, err
+	}
+
+	// A Service joining a group gives up the load-balancer it had before.
+	if id := getLoadBalancerID(service); id != "" && id != lb.ID && service.Annotations[annDOLoadBalancerSharingGroup] != group {
+		l.releaseOwnLoadBalancer(ctx, service, id)
+	}
+
+	updateServiceAnnotation(service, annDOLoadBalancerID, lb.ID)
+	updateServiceAnnotation(service, annDOLoadBalancerSharingGroup, group)
+	return lb, nil
+}
+
+// releaseOwnLoadBalancer releases the load-balancer with the given ID that
+// service had before joining a sharing group. Failures are left to the
+// garbage collector.
+func (l *loadBalancers) releaseOwnLoadBalancer(ctx context.Context, service *v1.Service, id string) {
+	lb, err := l.findLoadBalancerByID(ctx, id)
+	if err == errLBNotFound {
+		return
+	}
+	if err == nil {
+		err = l.deleteLoadBalancer(ctx, service, lb)
+	}
+	if err != nil {
+		klog.Errorf("Failed to release load-balancer %s of service %s/%s after joining a sharing group: %s", id, service.Namespace, service.Name, err)
+	}
+}
+
+// leaveSharingGroup removes service from group. The load-balancer of the group is updated without the ports of service,
+// or deleted if service was its last member.
+func (l *loadBalancers) leaveSharingGroup(ctx context.Context, service *v1.Service, group string) error {
+	members, err := l.sharingGroupMembers(service, group)
+	if err != nil {
+		return err
+	}
+	lbRequest, _ := l.buildSharedLoadBalancerRequest(ctx, service.Namespace, group, members, nil)
+
+	unlock := l.lockSharingGroup(service.Namespace, group)
+	defer unlock()
+
+	lb, err := l.findSharedLoadBalancer(ctx, service.Namespace, group, append([]*v1.Service{service}, members...))
+	if err != nil {
+		return err
+	}
+	if lb != nil {
+		if _, err := l.syncSharedLoadBalancer(ctx, service, lb, group, members, lbRequest, nil); err != nil {
+			return err
+		}
+		klog.Infof("Service %s/%s left load-balancer %s of sharing group %q", service.Namespace, service.Name, lb.ID, group)
+	}
+	return nil
+}
+
+// joinedSharingGroup returns the sharing group service was last joined to,
+// or, if it has not been joined yet, the one it asks for.
+func joinedSharingGroup(service *v1.Service) string {
+	if group := service.Annotations[annDOLoadBalancerSharingGroup]; group != "" {
+		return group
+	}
+	group, _ := getSharingGroup(service)
+	return group
+}
+
+// syncSharingGroupMembership returns the sharing group of service. If service
+// has left the sharing group it was last joined to, it is removed from that
+// group first so that it gets a load-balancer of its own.
+func (l *loadBalancers) syncSharingGroupMembership(ctx context.Context, service *v1.Service) (string, error) {
+	group, err := getSharingGroup(service)
+	if err != nil {
+		l.recordBuildFailure(service, err)
+		return "", err
+	}
+
+	joined := service.Annotations[annDOLoadBalancerSharingGroup]
+	if joined == "" || joined == group {
+		return group, nil
+	}
+	if err := l.leaveSharingGroup(ctx, service, joined); err != nil {
+		return "", fmt.Errorf("failed to leave sharing group %q: %s", joined, err)
+	}
+	l.recordEvent(service, v1.EventTypeNormal, eventReasonLBSharingLeft, "Left load-balancer of sharing group %q", joined)
+	delete(service.Annotations, annDOLoadBalancerID)
+	delete(service.Annotations, annDOLoadBalancerSharingGroup)
+	return group, nil
+}
@@ -0,0 +1,411 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newSharingTestService(name string, age time.Duration, group string, ports ...int32) *v1.Service {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         v1.NamespaceDefault,
+			UID:               types.UID(name + "-uid"),
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age).Truncate(time.Second)),
+			Annotations:       map[string]string{},
+		},
+		Spec: v1.ServiceSpec{
+			Type: v1.ServiceTypeLoadBalancer,
+		},
+	}
+	if group != "" {
+		service.Annotations[annDOSharingGroup] = group
+	}
+	for _, port := range ports {
+		service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{
+			Name:     fmt.Sprintf("port-%d", port),
+			Protocol: v1.ProtocolTCP,
+			Port:     port,
+			NodePort: 30000 + port,
+		})
+	}
+	return service
+}
+
+// newSharingTestLoadBalancers returns load-balancers backed by a fake API that
+// keeps load-balancers in store, and the IDs of deleted load-balancers.
+func newSharingTestLoadBalancers(t *testing.T, store map[string]*godo.LoadBalancer, services ...*v1.Service) (*loadBalancers, *[]string) {
+	t.Helper()
+
+	var deleted []string
+	fakeLB := newKVLBService(store)
+	fakeLB.listFn = func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+		var lbs []godo.LoadBalancer
+		for _, lb := range store {
+			lbs = append(lbs, *lb)
+		}
+		return lbs, newFakeOKResponse(), nil
+	}
+	fakeLB.createFn = func(_ context.Context, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+		lb := &godo.LoadBalancer{
+			ID:              fmt.Sprintf("lb-%d", len(store)+len(deleted)+1),
+			Name:            lbr.Name,
+			IP:              "10.0.0.1",
+			Status:          lbStatusActive,
+			ForwardingRules: lbr.ForwardingRules,
+			DropletIDs:      lbr.DropletIDs,
+			Tags:            lbr.Tags,
+		}
+		store[lb.ID] = lb
+		return lb, newFakeOKResponse(), nil
+	}
+	fakeLB.deleteFn = func(_ context.Context, lbID string) (*godo.Response, error) {
+		delete(store, lbID)
+		deleted = append(deleted, lbID)
+		return newFakeOKResponse(), nil
+	}
+	fakeDroplet := &fakeDropletService{
+		listByTagFunc: func(context.Context, string, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+			return []godo.Droplet{{ID: 100, Name: "node-1"}}, newFakeOKResponse(), nil
+		},
+	}
+
+	var objs []runtime.Object
+	for _, svc := range services {
+		objs = append(objs, svc)
+	}
+	fakeResources := newResources(clusterID, "", publicAccessFirewall{}, newFakeClient(fakeDroplet, &fakeLB, nil))
+	kclient := fake.NewSimpleClientset(objs...)
+	fakeResources.kclient = kclient
+
+	sharedInformer := informers.NewSharedInformerFactory(kclient, 0)
+	svcLister := sharedInformer.Core().V1().Services().Lister()
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	sharedInformer.Start(stop)
+	sharedInformer.WaitForCacheSync(stop)
+
+	return &loadBalancers{
+		resources:         fakeResources,
+		region:            "nyc3",
+		lbActiveTimeout:   2,
+		lbActiveCheckTick: 1,
+		recorder:          record.NewFakeRecorder(100),
+		svcLister:         svcLister,
+	}, &deleted
+}
+
+// deleteSharingTestService deletes service and waits for the Service lister
+// of lbs to observe the deletion.
+func deleteSharingTestService(t *testing.T, lbs *loadBalancers, service *v1.Service) {
+	t.Helper()
+
+	if err := lbs.resources.kclient.CoreV1().Services(service.Namespace).Delete(context.Background(), service.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete service: %s", err)
+	}
+	err := wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		_, err := lbs.svcLister.Services(service.Namespace).Get(service.Name)
+		return errors.IsNotFound(err), nil
+	})
+	if err != nil {
+		t.Fatalf("service %s was not removed from the lister", service.Name)
+	}
+}
+
+func entryPorts(lb *godo.LoadBalancer) []int {
+	var ports []int
+	for _, rule := range lb.ForwardingRules {
+		ports = append(ports, rule.EntryPort)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+func Test_getSharingGroup(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name: "no sharing group",
+		},
+		{
+			name: "valid sharing group",
+			annotations: map[string]string{
+				annDOSharingGroup: "web",
+			},
+			want: "web",
+		},
+		{
+			name: "invalid sharing group",
+			annotations: map[string]string{
+				annDOSharingGroup: "Web_1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "combined with adoption",
+			annotations: map[string]string{
+				annDOSharingGroup: "web",
+				annDOAdoptLB:      "hand-made",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test",
+					Annotations: test.annotations,
+				},
+			}
+
+			got, err := getSharingGroup(service)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error: %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got sharing group %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func Test_sharedLoadBalancerName(t *testing.T) {
+	r := newResources(clusterID, "", publicAccessFirewall{}, nil)
+	name := r.sharedLoadBalancerName("default", "web")
+	if err := validateLoadBalancerName(name); err != nil {
+		t.Errorf("got invalid name %q: %s", name, err)
+	}
+	if other := r.sharedLoadBalancerName("other", "web"); other == name {
+		t.Errorf("got name %q for groups of different namespaces", name)
+	}
+	if other := newResources("other-cluster", "", publicAccessFirewall{}, nil).sharedLoadBalancerName("default", "web"); other == name {
+		t.Errorf("got name %q for groups of different clusters", name)
+	}
+}
+
+func Test_lockSharingGroup(t *testing.T) {
+	lbs := &loadBalancers{}
+	unlock := lbs.lockSharingGroup(v1.NamespaceDefault, "web")
+	defer unlock()
+
+	// Other groups are not held up by the locked one.
+	done := make(chan struct{})
+	go func() {
+		lbs.lockSharingGroup(v1.NamespaceDefault, "api")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("locking another sharing group blocked")
+	}
+}
+
+func Test_buildSharedLoadBalancerRequest(t *testing.T) {
+	oldest := newSharingTestService("oldest", 3*time.Hour, "web", 80)
+	middle := newSharingTestService("middle", 2*time.Hour, "web", 443)
+	conflicting := newSharingTestService("conflicting", time.Hour, "web", 80, 8080)
+	members := []*v1.Service{conflicting, middle, oldest}
+	sortSharingGroupMembers(members)
+
+	lbs, _ := newSharingTestLoadBalancers(t, map[string]*godo.LoadBalancer{})
+	nodes := []*v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+
+	lbRequest, excluded := lbs.buildSharedLoadBalancerRequest(context.Background(), v1.NamespaceDefault, "web", members, nodes)
+	if lbRequest == nil {
+		t.Fatal("got no load-balancer request")
+	}
+	if want := lbs.resources.sharedLoadBalancerName(v1.NamespaceDefault, "web"); lbRequest.Name != want {
+		t.Errorf("got name %q, want %q", lbRequest.Name, want)
+	}
+	if got, want := entryPorts(&godo.LoadBalancer{ForwardingRules: lbRequest.ForwardingRules}), []int{80, 443}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got entry ports %v, want %v", got, want)
+	}
+	if len(excluded) != 1 || excluded["conflicting"] == nil {
+		t.Errorf("got excluded members %v, want only conflicting", excluded)
+	}
+}
+
+func TestEnsureLoadBalancer_sharing(t *testing.T) {
+	first := newSharingTestService("first", 2*time.Hour, "web", 80)
+	second := newSharingTestService("second", time.Hour, "web", 443)
+	store := map[string]*godo.LoadBalancer{}
+	lbs, _ := newSharingTestLoadBalancers(t, store, first, second)
+	nodes := []*v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+
+	for _, service := range []*v1.Service{first, second} {
+		status, err := lbs.EnsureLoadBalancer(context.Background(), "test", service, nodes)
+		if err != nil {
+			t.Fatalf("failed to ensure load-balancer for service %s: %s", service.Name, err)
+		}
+		if len(status.Ingress) != 1 || status.Ingress[0].IP != "10.0.0.1" {
+			t.Errorf("got status %v for service %s, want shared IP", status, service.Name)
+		}
+	}
+
+	if len(store) != 1 {
+		t.Fatalf("got %d load-balancers, want 1", len(store))
+	}
+	for _, lb := range store {
+		if got, want := entryPorts(lb), []int{80, 443}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got entry ports %v, want %v", got, want)
+		}
+		for _, service := range []*v1.Service{first, second} {
+			if got := service.Annotations[annDOLoadBalancerID]; got != lb.ID {
+				t.Errorf("got load-balancer ID annotation %q on service %s, want %q", got, service.Name, lb.ID)
+			}
+			if got := service.Annotations[annDOLoadBalancerSharingGroup]; got != "web" {
+				t.Errorf("got sharing group annotation %q on service %s, want %q", got, service.Name, "web")
+			}
+		}
+	}
+}
+
+func TestEnsureLoadBalancer_sharingConflict(t *testing.T) {
+	first := newSharingTestService("first", 2*time.Hour, "web", 80)
+	second := newSharingTestService("second", time.Hour, "web", 80)
+	store := map[string]*godo.LoadBalancer{}
+	lbs, _ := newSharingTestLoadBalancers(t, store, first, second)
+	nodes := []*v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+
+	if _, err := lbs.EnsureLoadBalancer(context.Background(), "test", first, nodes); err != nil {
+		t.Fatalf("failed to ensure load-balancer for first service: %s", err)
+	}
+	if _, err := lbs.EnsureLoadBalancer(context.Background(), "test", second, nodes); err == nil {
+		t.Fatal("expected conflict error but got none")
+	}
+	if _, ok := second.Annotations[annDOLoadBalancerID]; ok {
+		t.Error("got load-balancer ID annotation on conflicting service")
+	}
+
+	recorder := lbs.recorder.(*record.FakeRecorder)
+	close(recorder.Events)
+	var found bool
+	for event := range recorder.Events {
+		if event == "Warning LoadBalancerSharingConflict Not joining load-balancer of sharing group \"web\": conflicts with sharing group \"web\": port 80 is in use by service first" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("missing sharing conflict event")
+	}
+}
+
+func TestEnsureLoadBalancerDeleted_sharing(t *testing.T) {
+	first := newSharingTestService("first", 2*time.Hour, "web", 80)
+	second := newSharingTestService("second", time.Hour, "web", 443)
+	store := map[string]*godo.LoadBalancer{}
+	lbs, deleted := newSharingTestLoadBalancers(t, store, first, second)
+	nodes := []*v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+
+	for _, service := range []*v1.Service{first, second} {
+		if _, err := lbs.EnsureLoadBalancer(context.Background(), "test", service, nodes); err != nil {
+			t.Fatalf("failed to ensure load-balancer for service %s: %s", service.Name, err)
+		}
+	}
+	lbID := first.Annotations[annDOLoadBalancerID]
+
+	// Removing the first member keeps the load-balancer for the second one.
+	deleteSharingTestService(t, lbs, first)
+	if err := lbs.EnsureLoadBalancerDeleted(context.Background(), "test", first); err != nil {
+		t.Fatalf("failed to delete load-balancer for first service: %s", err)
+	}
+	if len(*deleted) != 0 {
+		t.Fatalf("got deleted load-balancers %v while a member is left", *deleted)
+	}
+	if got, want := entryPorts(store[lbID]), []int{443}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got entry ports %v, want %v", got, want)
+	}
+	if got, want := store[lbID].DropletIDs, []int{100}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got droplets %v, want %v", got, want)
+	}
+
+	// Removing the last member deletes the load-balancer.
+	deleteSharingTestService(t, lbs, second)
+	if err := lbs.EnsureLoadBalancerDeleted(context.Background(), "test", second); err != nil {
+		t.Fatalf("failed to delete load-balancer for second service: %s", err)
+	}
+	if want := []string{lbID}; !reflect.DeepEqual(*deleted, want) {
+		t.Errorf("got deleted load-balancers %v, want %v", *deleted, want)
+	}
+}
+
+func TestEnsureLoadBalancer_leaveSharingGroup(t *testing.T) {
+	first := newSharingTestService("first", 2*time.Hour, "web", 80)
+	second := newSharingTestService("second", time.Hour, "web", 443)
+	store := map[string]*godo.LoadBalancer{}
+	lbs, deleted := newSharingTestLoadBalancers(t, store, first, second)
+	nodes := []*v1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+
+	for _, service := range []*v1.Service{first, second} {
+		if _, err := lbs.EnsureLoadBalancer(context.Background(), "test", service, nodes); err != nil {
+			t.Fatalf("failed to ensure load-balancer for service %s: %s", service.Name, err)
+		}
+	}
+	sharedID := second.Annotations[annDOLoadBalancerID]
+
+	delete(second.Annotations, annDOSharingGroup)
+	if _, err := lbs.resources.kclient.CoreV1().Services(v1.NamespaceDefault).Update(context.Background(), second, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update service: %s", err)
+	}
+	if _, err := lbs.EnsureLoadBalancer(context.Background(), "test", second, nodes); err != nil {
+		t.Fatalf("failed to ensure load-balancer for leaving service: %s", err)
+	}
+
+	if len(*deleted) != 0 {
+		t.Errorf("got deleted load-balancers %v, want none", *deleted)
+	}
+	if len(store) != 2 {
+		t.Fatalf("got %d load-balancers, want 2", len(store))
+	}
+	if got, want := entryPorts(store[sharedID]), []int{80}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got entry ports %v of shared load-balancer, want %v", got, want)
+	}
+	ownID := second.Annotations[annDOLoadBalancerID]
+	if ownID == sharedID || store[ownID] == nil {
+		t.Fatalf("got load-balancer ID annotation %q, want own load-balancer", ownID)
+	}
+	if got, want := entryPorts(store[ownID]), []int{443}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got entry ports %v of own load-balancer, want %v", got, want)
+	}
+	if _, ok := second.Annotations[annDOLoadBalancerSharingGroup]; ok {
+		t.Error("got sharing group annotation on service that left its group")
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultLBUpdateBatchConcurrency = 5
+
+	lbUpdateBatchTimeout = 2 * time.Minute
+)
+
+// pendingLBUpdate is the latest node update requested for a load-balancer.
+type pendingLBUpdate struct {
+	service *v1.Service
+	nodes   []*v1.Node
+}
+
+// lbUpdateBatcher coalesces the node updates of load-balancers requested by
+// the service controller. Updates requested within window of the first one
+// are applied once with the latest set of nodes, so that node churn results
+// in at most one update per load-balancer and window.
+type lbUpdateBatcher struct {
+	lbs         *loadBalancers
+	window      time.Duration
+	concurrency int
+	queue       workqueue.RateLimitingInterface
+
+	mu      sync.Mutex
+	pending map[string]*pendingLBUpdate
+}
+
+func newLBUpdateBatcher(lbs *loadBalancers, window time.Duration, concurrency int) *lbUpdateBatcher {
+	return &lbUpdateBatcher{
+		lbs:         lbs,
+		window:      window,
+		concurrency: concurrency,
+		queue:       workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "lb-updates"),
+		pending:     map[string]*pendingLBUpdate{},
+	}
+}
+
+// lbUpdateKey returns the key identifying the load-balancer of service.
+// Members of a sharing group share a key since they share a load-balancer.
+func lbUpdateKey(service *v1.Service) string {
+	if group := joinedSharingGroup(service); group != "" {
+		// Service names cannot contain colons, so group keys do not collide
+		// with Service keys.
+		return fmt.Sprintf("%s/sharing-group:%s", service.Namespace, group)
+	}
+	return fmt.Sprintf("%s/%s", service.Namespace, service.Name)
+}
+
+// enqueue schedules an update of the load-balancer of service to target
+// nodes, replacing any update pending for the load-balancer.
+func (b *lbUpdateBatcher) enqueue(service *v1.Service, nodes []*v1.Node) {
+	key := lbUpdateKey(service)
+
+	b.mu.Lock()
+	if _, ok := b.pending[key]; ok {
+		lbUpdatesCoalescedTotal.Inc()
+	}
+	b.pending[key] = &pendingLBUpdate{
+		service: service.DeepCopy(),
+		nodes:   nodes,
+	}
+	b.mu.Unlock()
+
+	// The delaying queue keeps the earliest deadline of a key, so further
+	// updates do not postpone the pending one.
+	b.queue.AddAfter(key, b.window)
+}
+
+// forget drops the update pending for the load-balancer of service, e.g.,
+// because the load-balancer is being ensured or deleted.
+func (b *lbUpdateBatcher) forget(service *v1.Service) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.pending, lbUpdateKey(service))
+}
+
+// Run processes pending updates with bounded concurrency until stopCh is
+// closed.
+func (b *lbUpdateBatcher) Run(stopCh <-chan struct{}) {
+	defer b.queue.ShutDown()
+	for i := 0; i < b.concurrency; i++ {
+		go wait.Until(b.runWorker, time.Second, stopCh)
+	}
+	<-stopCh
+}
+
+func (b *lbUpdateBatcher) runWorker() {
+	for b.processNextItem() {
+	}
+}
+
+func (b *lbUpdateBatcher) processNextItem() bool {
+	key, quit := b.queue.Get()
+	if quit {
+		return false
+	}
+	defer b.queue.Done(key)
+
+	b.mu.Lock()
+	update, ok := b.pending[key.(string)]
+	delete(b.pending, key.(string))
+	b.mu.Unlock()
+	if !ok {
+		b.queue.Forget(key)
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lbUpdateBatchTimeout)
+	defer cancel()
+	if err := b.sync(ctx, update); err != nil {
+		klog.Errorf("Failed to update nodes of load-balancer %s: %s", key, err)
+		lbBatchedUpdatesTotal.WithLabelValues("failed").Inc()
+		// The service controller does not learn about failed batched
+		// updates, so they are reported on the Service instead.
+		b.lbs.recordEvent(update.service, v1.EventTypeWarning, eventReasonLBUpdateFailed, "Failed to update nodes of load-balancer: %s", err)
+
+		// Retry unless a newer update is pending already.
+		b.mu.Lock()
+		if _, ok := b.pending[key.(string)]; !ok {
+			b.pending[key.(string)] = update
+		}
+		b.mu.Unlock()
+		b.queue.AddRateLimited(key)
+	} else {
+		lbBatchedUpdatesTotal.WithLabelValues("updated").Inc()
+		b.queue.Forget(key)
+	}
+	return true
+}
+
+// sync applies update to the current version of its Service, which may have
+// changed while the update was pending.
+func (b *lbUpdateBatcher) sync(ctx context.Context, update *pendingLBUpdate) error {
+	if b.lbs.svcLister == nil {
+		return fmt.Errorf("service lister is not initialized")
+	}
+	service, err := b.lbs.svcLister.Services(update.service.Namespace).Get(update.service.Name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get service: %s", err)
+	}
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || service.DeletionTimestamp != nil {
+		return nil
+	}
+	// Objects returned by the lister must not be modified.
+	return b.lbs.updateLoadBalancerNodes(ctx, service.DeepCopy(), update.nodes)
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2024 DigitalOcean
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package do
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitalocean/godo"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_lbUpdateKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+	}{
+		{
+			name: "dedicated load-balancer",
+			want: "default/test",
+		},
+		{
+			name: "shared load-balancer",
+			annotations: map[string]string{
+				annDOSharingGroup: "web",
+			},
+			want: "default/sharing-group:web",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := lbUpdateKey(newTestService(test.annotations)); got != test.want {
+				t.Errorf("got key %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLBUpdateBatcher(t *testing.T) {
+	service := newTestService(map[string]string{
+		annDOLoadBalancerID: "load-balancer-id",
+	})
+	nodes := []*v1.Node{
+		newNodeSelectorTestNode("node-1", "digitalocean://100", nil),
+		newNodeSelectorTestNode("node-2", "digitalocean://101", nil),
+		newNodeSelectorTestNode("node-3", "digitalocean://102", nil),
+	}
+
+	var updates []*godo.LoadBalancerRequest
+	live := &godo.LoadBalancer{}
+	fakeLB := &fakeLBService{
+		getFn: func(context.Context, string) (*godo.LoadBalancer, *godo.Response, error) {
+			return live, newFakeOKResponse(), nil
+		},
+		updateFn: func(_ context.Context, _ string, lbr *godo.LoadBalancerRequest) (*godo.LoadBalancer, *godo.Response, error) {
+			updates = append(updates, lbr)
+			live = newLiveLoadBalancer(lbr)
+			live.ID = "load-balancer-id"
+			return live, newFakeOKResponse(), nil
+		},
+	}
+	fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(&fakeDropletService{}, fakeLB, nil))
+	fakeResources.kclient = fake.NewSimpleClientset(service)
+	lbs := &loadBalancers{
+		resources:         fakeResources,
+		region:            "nyc3",
+		lbActiveTimeout:   2,
+		lbActiveCheckTick: 1,
+		svcLister:         newServiceLister(t, service),
+	}
+
+	// Start with a load-balancer targeting the first node only.
+	req, err := lbs.buildLoadBalancerRequest(context.Background(), service, nodes[:1])
+	if err != nil {
+		t.Fatalf("failed to build load-balancer request: %s", err)
+	}
+	live = newLiveLoadBalancer(req)
+	live.ID = "load-balancer-id"
+
+	b := newLBUpdateBatcher(lbs, time.Second, 1)
+	lbs.updateBatcher = b
+	coalesced := testutil.ToFloat64(lbUpdatesCoalescedTotal)
+
+	// Nodes are added one by one within the window.
+	for i := 2; i <= len(nodes); i++ {
+		if err := lbs.UpdateLoadBalancer(context.Background(), "", service, nodes[:i]); err != nil {
+			t.Fatalf("failed to update load-balancer: %s", err)
+		}
+	}
+	if len(updates) != 0 {
+		t.Fatalf("got %d update(s) before the window elapsed, want 0", len(updates))
+	}
+	if got := testutil.ToFloat64(lbUpdatesCoalescedTotal) - coalesced; got != 1 {
+		t.Errorf("got %v coalesced update(s), want 1", got)
+	}
+
+	err = wait.PollUntilContextTimeout(context.Background(), 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+		return b.queue.Len() > 0, nil
+	})
+	if err != nil {
+		t.Fatal("no update was queued after the window elapsed")
+	}
+	if got := b.queue.Len(); got != 1 {
+		t.Fatalf("got %d queued update(s), want 1", got)
+	}
+
+	// The load-balancer is updated once with the final set of nodes.
+	b.processNextItem()
+	if len(updates) != 1 {
+		t.Fatalf("got %d update(s), want 1", len(updates))
+	}
+	if want := []int{100, 101, 102}; !reflect.DeepEqual(updates[0].DropletIDs, want) {
+		t.Errorf("got droplet IDs %v, want %v", updates[0].DropletIDs, want)
+	}
+
+	// Forgotten updates are dropped.
+	if err := lbs.UpdateLoadBalancer(context.Background(), "", service, nodes[:1]); err != nil {
+		t.Fatalf("failed to update load-balancer: %s", err)
+	}
+	b.forget(service)
+	b.queue.Add(lbUpdateKey(service))
+	b.processNextItem()
+	if len(updates) != 1 {
+		t.Errorf("got %d update(s) after dropping the pending update, want 1", len(updates))
+	}
+}
+
+func TestLBUpdateBatcher_failure(t *testing.T) {
+	service := newTestService(map[string]string{
+		annDOLoadBalancerID: "load-balancer-id",
+	})
+	nodes := []*v1.Node{
+		newNodeSelectorTestNode("node-1", "digitalocean://100", nil),
+	}
+
+	fakeLB := &fakeLBService{
+		getFn: func(context.Context, string) (*godo.LoadBalancer, *godo.Response, error) {
+			return nil, newFakeNotOKResponse(), errors.New("internal server error")
+		},
+	}
+	fakeResources := newResources("", "", publicAccessFirewall{}, newFakeClient(&fakeDropletService{}, fakeLB, nil))
+	fakeResources.kclient = fake.NewSimpleClientset(service)
+	recorder := record.NewFakeRecorder(10)
+	lbs := &loadBalancers{
+		resources:         fakeResources,
+		region:            "nyc3",
+		lbActiveTimeout:   2,
+		lbActiveCheckTick: 1,
+		recorder:          recorder,
+		svcLister:         newServiceLister(t, service),
+	}
+	b := newLBUpdateBatcher(lbs, 0, 1)
+	defer b.queue.ShutDown()
+
+	b.enqueue(service, nodes)
+	b.processNextItem()
+
+	close(recorder.Events)
+	var found bool
+	for event := range recorder.Events {
+		if strings.HasPrefix(event, "Warning UpdateLoadBalancerFailed Failed to update nodes of load-balancer:") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("missing update failure event")
+	}
+
+	// The failed update is retried.
+	b.mu.Lock()
+	_, pending := b.pending[lbUpdateKey(service)]
+	b.mu.Unlock()
+	if !pending {
+		t.Error("failed update is not pending anymore")
+	}
+}
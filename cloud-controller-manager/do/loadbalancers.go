@@ -22,16 +22,21 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
-	"k8s.io/cloud-provider/api"
 	servicehelpers "k8s.io/cloud-provider/service/helpers"
 	"k8s.io/klog/v2"
 	utilnet "k8s.io/utils/net"
@@ -92,6 +97,43 @@ type loadBalancers struct {
 	clusterID         string
 	lbActiveTimeout   int
 	lbActiveCheckTick int
+
+	// remediationStrategy is applied to load-balancers that do not become
+	// active within remediationTimeout. Defaults to alert.
+	remediationStrategy lbRemediationStrategy
+	// remediationTimeout defaults to defaultLBRemediationTimeout when zero.
+	remediationTimeout time.Duration
+
+	// updateBatcher coalesces the node updates of load-balancers if set.
+	updateBatcher *lbUpdateBatcher
+
+	// drainPeriod is the time nodes about to be removed are drained from
+	// load-balancers before their deletion may proceed. Draining is disabled
+	// if it is zero.
+	drainPeriod time.Duration
+
+	// sharingLocks serializes changes to the load-balancer of each sharing
+	// group, keyed by namespace and group. sharingMu guards the map.
+	sharingMu    sync.Mutex
+	sharingLocks map[string]*sync.Mutex
+
+	// unhealthyLBs tracks since when load-balancers have not been active.
+	unhealthyMu  sync.Mutex
+	unhealthyLBs map[string]unhealthyLB
+
+	// recorder records events on Services. It is nil until the cloud
+	// provider is initialized.
+	recorder record.EventRecorder
+
+	// svcLister and nodeLister list all Services and nodes of the cluster.
+	// They are nil until the cloud provider is initialized.
+	svcLister  v1lister.ServiceLister
+	nodeLister v1lister.NodeLister
+
+	// endpointSliceLister lists the EndpointSlices of Services with
+	// endpoint-aware backends. It is nil unless endpoint-aware backends are
+	// enabled.
+	endpointSliceLister discoverylisters.EndpointSliceLister
 }
 
 type servicePatcher struct {
@@ -119,8 +161,8 @@ func (sp *servicePatcher) Patch(ctx context.Context, err error) error {
 	return utilerrors.NewAggregate([]error{err, perr})
 }
 
-// newLoadbalancers returns a cloudprovider.LoadBalancer whose concrete type is a *loadbalancer.
-func newLoadBalancers(resources *resources, region string) cloudprovider.LoadBalancer {
+// newLoadBalancers returns a *loadBalancers implementing cloudprovider.LoadBalancer.
+func newLoadBalancers(resources *resources, region string) *loadBalancers {
 	return &loadBalancers{
 		resources:         resources,
 		region:            region,
@@ -157,7 +199,7 @@ func (l *loadBalancers) GetLoadBalancer(ctx context.Context, clusterName string,
 // GetLoadBalancerName returns the name of the load balancer. Implementations must treat the
 // *v1.Service parameter as read-only and not modify it.
 func (l *loadBalancers) GetLoadBalancerName(_ context.Context, clusterName string, service *v1.Service) string {
-	return getLoadBalancerName(service)
+	return l.resources.loadBalancerName(service)
 }
 
 func getLoadBalancerName(service *v1.Service) string {
@@ -182,19 +224,44 @@ func getLoadBalancerLegacyName(service *v1.Service) string {
 func (l *loadBalancers) EnsureLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (lbs *v1.LoadBalancerStatus, err error) {
 	lbIsDisowned, err := getDisownLB(service)
 	if err != nil {
+		l.recordBuildFailure(service, err)
 		return nil, err
 	}
 	if lbIsDisowned {
 		klog.Infof("Short-circuiting EnsureLoadBalancer because service %q is disowned", service.Name)
-		return &service.Status.LoadBalancer, nil
+		return l.observeDisownedLoadBalancer(ctx, service)
+	}
+	// The load-balancer is rendered from the current nodes below, which
+	// makes any pending node update obsolete.
+	if l.updateBatcher != nil {
+		l.updateBatcher.forget(service)
 	}
 
 	patcher := newServicePatcher(l.resources.kclient, service)
 	defer func() { err = patcher.Patch(ctx, err) }()
 
+	var group string
+	group, err = l.syncSharingGroupMembership(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+	if group != "" {
+		var lb *godo.LoadBalancer
+		lb, err = l.ensureSharedLoadBalancer(ctx, service, group, nodes)
+		if err != nil {
+			return nil, err
+		}
+		if lb.Status != lbStatusActive {
+			return nil, l.remediateLoadBalancer(ctx, service, lb, nil)
+		}
+		l.forgetUnhealthy(lb.ID)
+		return loadBalancerStatus(service, lb), nil
+	}
+
 	var lbRequest *godo.LoadBalancerRequest
 	lbRequest, err = l.buildLoadBalancerRequest(ctx, service, nodes)
 	if err != nil {
+		l.recordBuildFailure(service, err)
 		return nil, fmt.Errorf("failed to build load-balancer request: %s", err)
 	}
 
@@ -203,20 +270,25 @@ func (l *loadBalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 	switch err {
 	case nil:
 		// LB existing
-		lb, err = l.updateLoadBalancer(ctx, lb, service, nodes)
+		if fields := immutableFieldChanges(lb.AsRequest(), lbRequest); len(fields) > 0 && !observesLoadBalancer(service) {
+			lb, err = l.replaceLoadBalancer(ctx, service, lb, lbRequest, fields)
+		} else {
+			lb, err = l.updateLoadBalancer(ctx, lb, service, nodes)
+		}
 		if err != nil {
 			return nil, err
 		}
 
 	case errLBNotFound:
 		// LB missing
-		lb, _, err = l.resources.gclient.LoadBalancers.Create(ctx, lbRequest)
-		if err != nil {
-			logLBInfo("CREATE", lbRequest, 2)
-			return nil, fmt.Errorf("failed to create load-balancer: %s", err)
+		if adopt := service.Annotations[annDOAdoptLB]; adopt != "" {
+			return nil, fmt.Errorf("failed to find load-balancer %q to adopt", adopt)
 		}
-		logLBInfo("CREATE", lbRequest, 2)
 
+		lb, err = l.createLoadBalancer(ctx, service, lbRequest)
+		if err != nil {
+			return nil, err
+		}
 		updateServiceAnnotation(service, annDOLoadBalancerID, lb.ID)
 
 	default:
@@ -224,14 +296,16 @@ func (l *loadBalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 		return nil, err
 	}
 
-	if lb.Status == lbStatusNew {
-		return nil, api.NewRetryError("load-balancer is currently being created", 15*time.Second)
-	}
-
 	if lb.Status != lbStatusActive {
-		return nil, fmt.Errorf("load-balancer has unexpected status %q", lb.Status)
+		return nil, l.remediateLoadBalancer(ctx, service, lb, lbRequest)
 	}
+	l.forgetUnhealthy(lb.ID)
 
+	return loadBalancerStatus(service, lb), nil
+}
+
+// loadBalancerStatus returns the status of service backed by lb.
+func loadBalancerStatus(service *v1.Service, lb *godo.LoadBalancer) *v1.LoadBalancerStatus {
 	// If a LB hostname annotation is specified, return with it instead of the IP.
 	hostname := getHostname(service)
 	if hostname != "" {
@@ -241,7 +315,7 @@ func (l *loadBalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 					Hostname: hostname,
 				},
 			},
-		}, nil
+		}
 	}
 
 	return &v1.LoadBalancerStatus{
@@ -250,12 +324,31 @@ func (l *loadBalancers) EnsureLoadBalancer(ctx context.Context, clusterName stri
 				IP: lb.IP,
 			},
 		},
-	}, nil
+	}
+}
+
+// createLoadBalancer creates a load-balancer for service from lbRequest.
+func (l *loadBalancers) createLoadBalancer(ctx context.Context, service *v1.Service, lbRequest *godo.LoadBalancerRequest) (*godo.LoadBalancer, error) {
+	lb, _, err := l.resources.gclient.LoadBalancers.Create(ctx, lbRequest)
+	if err != nil {
+		l.resources.invalidateLoadBalancers()
+		l.recordAPIRejection(service, "creation", err)
+		logLBInfo("CREATE", lbRequest, 2)
+		return nil, fmt.Errorf("failed to create load-balancer: %s", err)
+	}
+	logLBInfo("CREATE", lbRequest, 2)
+	l.resources.loadBalancerChanged(lb)
+	l.recordEvent(service, v1.EventTypeNormal, eventReasonLBCreated, "Created load-balancer %s (%s)", lb.Name, lb.ID)
+
+	return lb, nil
 }
 
-func getCertificateIDFromLB(lb *godo.LoadBalancer) string {
+// getCertificateIDFromLB returns the first certificate ID used by the
+// forwarding rules of lb, ignoring rules with one of excludedPorts as entry
+// port.
+func getCertificateIDFromLB(lb *godo.LoadBalancer, excludedPorts ...int) string {
 	for _, rule := range lb.ForwardingRules {
-		if rule.CertificateID != "" {
+		if rule.CertificateID != "" && !slices.Contains(excludedPorts, rule.EntryPort) {
 			return rule.CertificateID
 		}
 	}
@@ -279,6 +372,7 @@ func (l *loadBalancers) recordUpdatedLetsEncryptCert(ctx context.Context, servic
 
 		if lbCert.Type == certTypeLetsEncrypt {
 			updateServiceAnnotation(service, annDOCertificateID, lbCertID)
+			l.recordEvent(service, v1.EventTypeNormal, eventReasonCertificateRotated, "Load-balancer certificate was rotated from %s to %s", serviceCertID, lbCertID)
 		}
 	}
 
@@ -286,15 +380,33 @@ func (l *loadBalancers) recordUpdatedLetsEncryptCert(ctx context.Context, servic
 }
 
 func (l *loadBalancers) updateLoadBalancer(ctx context.Context, lb *godo.LoadBalancer, service *v1.Service, nodes []*v1.Node) (*godo.LoadBalancer, error) {
+	if err := l.resources.verifyLoadBalancerOwnership(service, lb); err != nil {
+		l.recordEvent(service, v1.EventTypeWarning, eventReasonLBNotOwned, "Refusing to update load-balancer: %s", err)
+		return nil, err
+	}
+
+	adoptionMode, err := getAdoptionMode(service)
+	if err != nil {
+		l.recordBuildFailure(service, err)
+		return nil, err
+	}
+	if adoptionMode == lbAdoptionModeObserve {
+		klog.V(2).Infof("Not updating load-balancer %s because it is only observed", lb.ID)
+		return lb, nil
+	}
+
 	// call buildLoadBalancerRequest for its error checking; we have to call it
 	// again just before actually updating the loadbalancer in case
 	// checkAndUpdateLBAndServiceCerts modifies the service
-	_, err := l.buildLoadBalancerRequest(ctx, service, nodes)
+	_, err = l.buildLoadBalancerRequest(ctx, service, nodes)
 	if err != nil {
+		l.recordBuildFailure(service, err)
 		return nil, fmt.Errorf("failed to build load-balancer request: %s", err)
 	}
 
-	lbCertID := getCertificateIDFromLB(lb)
+	// Certificates of ports with a structured configuration are not tracked
+	// by the certificate annotation.
+	lbCertID := getCertificateIDFromLB(lb, configuredPorts(service)...)
 	serviceCertID, err := findCertificateID(ctx, service, l.resources.gclient)
 	if err != nil {
 		return nil, err
@@ -309,14 +421,46 @@ func (l *loadBalancers) updateLoadBalancer(ctx context.Context, lb *godo.LoadBal
 	if err != nil {
 		return nil, fmt.Errorf("failed to build load-balancer request (post-certificate update): %s", err)
 	}
+	if adoptionMode == lbAdoptionModeMerge {
+		mergeUnmanagedFields(lb, lbRequest)
+	}
+	// Changes to immutable fields are applied by replacing the
+	// load-balancer in EnsureLoadBalancer.
+	preserveImmutableFields(lb, lbRequest)
+
+	return l.applyLoadBalancerRequest(ctx, service, lb, lbRequest)
+}
 
+// applyLoadBalancerRequest updates lb to match lbRequest unless it is
+// up-to-date already.
+func (l *loadBalancers) applyLoadBalancerRequest(ctx context.Context, service *v1.Service, lb *godo.LoadBalancer, lbRequest *godo.LoadBalancerRequest) (*godo.LoadBalancer, error) {
 	lbID := lb.ID
-	lb, _, err = l.resources.gclient.LoadBalancers.Update(ctx, lb.ID, lbRequest)
+	lbName := lb.Name
+	equal, diff := loadBalancerRequestEqual(lb, lbRequest)
+	if equal {
+		klog.V(2).Infof("Skipping update of load-balancer %s because its configuration is up-to-date", lbID)
+		return lb, nil
+	}
+	klog.Infof("Updating load-balancer %s\ndiff (-live +desired):\n%s", lbID, diff)
+
+	lb, resp, err := l.resources.gclient.LoadBalancers.Update(ctx, lb.ID, lbRequest)
 	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			l.resources.loadBalancerDeleted(lbID)
+		} else {
+			l.resources.invalidateLoadBalancers()
+		}
+		l.recordAPIRejection(service, "update", err)
 		logLBInfo("UPDATE", lbRequest, 2)
 		return nil, fmt.Errorf("failed to update load-balancer with ID %s: %s", lbID, err)
 	}
 	logLBInfo("UPDATE", lbRequest, 2)
+	l.resources.loadBalancerChanged(lb)
+	l.recordEvent(service, v1.EventTypeNormal, eventReasonLBUpdated, "Updated load-balancer %s", lbID)
+	if lbName != lbRequest.Name {
+		klog.Infof("Renamed load-balancer %s from %q to %q", lbID, lbName, lbRequest.Name)
+		l.recordEvent(service, v1.EventTypeNormal, eventReasonLBRenamed, "Renamed load-balancer %s from %q to %q", lbID, lbName, lbRequest.Name)
+	}
 
 	return lb, nil
 }
@@ -325,9 +469,22 @@ func (l *loadBalancers) updateLoadBalancer(ctx context.Context, lb *godo.LoadBal
 // the droplets in nodes.
 //
 // UpdateLoadBalancer will not modify service or nodes.
-func (l *loadBalancers) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) (err error) {
+func (l *loadBalancers) UpdateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service, nodes []*v1.Node) error {
+	// Node churn triggers updates of all load-balancers at once, which are
+	// coalesced if batching is enabled.
+	if l.updateBatcher != nil {
+		l.updateBatcher.enqueue(service, nodes)
+		return nil
+	}
+	return l.updateLoadBalancerNodes(ctx, service, nodes)
+}
+
+// updateLoadBalancerNodes updates the load-balancer of service to target
+// nodes.
+func (l *loadBalancers) updateLoadBalancerNodes(ctx context.Context, service *v1.Service, nodes []*v1.Node) (err error) {
 	lbIsDisowned, err := getDisownLB(service)
 	if err != nil {
+		l.recordBuildFailure(service, err)
 		return err
 	}
 	if lbIsDisowned {
@@ -338,6 +495,16 @@ func (l *loadBalancers) UpdateLoadBalancer(ctx context.Context, clusterName stri
 	patcher := newServicePatcher(l.resources.kclient, service)
 	defer func() { err = patcher.Patch(ctx, err) }()
 
+	var group string
+	group, err = l.syncSharingGroupMembership(ctx, service)
+	if err != nil {
+		return err
+	}
+	if group != "" {
+		_, err = l.ensureSharedLoadBalancer(ctx, service, group, nodes)
+		return err
+	}
+
 	var lb *godo.LoadBalancer
 	lb, err = l.retrieveAndAnnotateLoadBalancer(ctx, service)
 	if err != nil {
@@ -362,6 +529,19 @@ func (l *loadBalancers) EnsureLoadBalancerDeleted(ctx context.Context, clusterNa
 		klog.Infof("Short-circuiting EnsureLoadBalancerDeleted because service %q is disowned", service.Name)
 		return nil
 	}
+	if l.updateBatcher != nil {
+		l.updateBatcher.forget(service)
+	}
+
+	if err := l.deleteReplacementLoadBalancer(ctx, service); err != nil {
+		return err
+	}
+
+	// The load-balancer of a sharing group is only deleted along with its
+	// last member.
+	if group := joinedSharingGroup(service); group != "" {
+		return l.leaveSharingGroup(ctx, service, group)
+	}
 
 	// Not calling retrieveAndAnnotateLoadBalancer to save a potential PATCH API
 	// call: the load-balancer is destined to be removed anyway.
@@ -373,13 +553,45 @@ func (l *loadBalancers) EnsureLoadBalancerDeleted(ctx context.Context, clusterNa
 		return err
 	}
 
+	return l.deleteLoadBalancer(ctx, service, lb)
+}
+
+// deleteLoadBalancer deletes lb of service, or retains it if the deletion
+// policy of service says so.
+func (l *loadBalancers) deleteLoadBalancer(ctx context.Context, service *v1.Service, lb *godo.LoadBalancer) error {
+	// Deleting the Service must not be blocked by a load-balancer that is
+	// not ours to delete, so we leave it alone, report it through a warning
+	// event, and report success.
+	if err := l.resources.verifyLoadBalancerOwnership(service, lb); err != nil {
+		klog.Errorf("Refusing to delete load-balancer %s for service %s/%s, leaving it behind: %s", lb.ID, service.Namespace, service.Name, err)
+		l.recordEvent(service, v1.EventTypeWarning, eventReasonLBNotOwned, "Refusing to delete load-balancer %s, leaving it behind: %s", lb.ID, err)
+		return nil
+	}
+	if observesLoadBalancer(service) {
+		klog.Infof("Not deleting load-balancer %s for service %s/%s because it is only observed", lb.ID, service.Namespace, service.Name)
+		return nil
+	}
+
+	deletionPolicy, err := getDeletionPolicy(service)
+	if err != nil {
+		return err
+	}
+	if deletionPolicy == lbDeletionPolicyRetain {
+		return l.retainLoadBalancer(ctx, service, lb)
+	}
+
 	resp, err := l.resources.gclient.LoadBalancers.Delete(ctx, lb.ID)
 	if err != nil {
 		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			l.resources.loadBalancerDeleted(lb.ID)
 			return nil
 		}
+		l.resources.invalidateLoadBalancers()
+		l.recordAPIRejection(service, "deletion", err)
 		return fmt.Errorf("failed to delete load-balancer: %s", err)
 	}
+	l.resources.loadBalancerDeleted(lb.ID)
+	l.recordEvent(service, v1.EventTypeNormal, eventReasonLBDeleted, "Deleted load-balancer %s (%s)", lb.Name, lb.ID)
 
 	return nil
 }
@@ -392,6 +604,24 @@ func (l *loadBalancers) retrieveAndAnnotateLoadBalancer(ctx context.Context, ser
 		return nil, err
 	}
 
+	if getLoadBalancerID(service) == "" {
+		switch {
+		case adoptsLoadBalancer(service, lb):
+			adoptionMode, err := getAdoptionMode(service)
+			if err != nil {
+				l.recordBuildFailure(service, err)
+				return nil, err
+			}
+			l.adoptLoadBalancer(ctx, service, lb, adoptionMode)
+		case l.resources.verifyLoadBalancerOwnership(service, lb) != nil:
+			// Do not associate a load-balancer that merely shares the name
+			// with the Service if it belongs to someone else. Mutating calls
+			// verify ownership themselves.
+			return lb, nil
+		default:
+			l.recordEvent(service, v1.EventTypeNormal, eventReasonLBAdopted, "Adopted existing load-balancer %s (%s) by name", lb.Name, lb.ID)
+		}
+	}
 	updateServiceAnnotation(service, annDOLoadBalancerID, lb.ID)
 
 	return lb, nil
@@ -405,12 +635,17 @@ func (l *loadBalancers) retrieveLoadBalancer(ctx context.Context, service *v1.Se
 		return l.findLoadBalancerByID(ctx, id)
 	}
 
-	allLBs, err := allLoadBalancerList(ctx, l.resources.gclient)
+	if service.Annotations[annDOAdoptLB] != "" {
+		return l.retrieveAdoptedLoadBalancer(ctx, service)
+	}
+
+	candidates := l.resources.loadBalancerNameCandidates(service)
+	klog.V(2).Infof("Looking up load-balancer for service %s/%s by name (candidates: %s)", service.Namespace, service.Name, strings.Join(candidates, ", "))
+
+	lb, err := l.resources.loadBalancerByName(ctx, candidates...)
 	if err != nil {
 		return nil, err
 	}
-
-	lb := findLoadBalancerByName(service, allLBs)
 	if lb == nil {
 		return nil, errLBNotFound
 	}
@@ -418,25 +653,52 @@ func (l *loadBalancers) retrieveLoadBalancer(ctx context.Context, service *v1.Se
 	return lb, nil
 }
 
+// lookupLoadBalancer returns the load-balancer of service like
+// retrieveLoadBalancer, but from the load-balancer inventory rather than the
+// API. It is meant for frequent lookups, such as periodic checks of all
+// load-balancers.
+func (l *loadBalancers) lookupLoadBalancer(ctx context.Context, service *v1.Service) (*godo.LoadBalancer, error) {
+	var (
+		lb  *godo.LoadBalancer
+		err error
+	)
+	switch {
+	case getLoadBalancerID(service) != "":
+		lb, err = l.resources.loadBalancerByID(ctx, getLoadBalancerID(service))
+	case service.Annotations[annDOAdoptLB] != "":
+		adopt := service.Annotations[annDOAdoptLB]
+		lb, err = l.resources.loadBalancerByName(ctx, adopt)
+		if err == nil && lb == nil {
+			lb, err = l.resources.loadBalancerByID(ctx, adopt)
+		}
+	default:
+		lb, err = l.resources.loadBalancerByName(ctx, l.resources.loadBalancerNameCandidates(service)...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lb == nil {
+		return nil, errLBNotFound
+	}
+	return lb, nil
+}
+
 func (l *loadBalancers) findLoadBalancerByID(ctx context.Context, id string) (*godo.LoadBalancer, error) {
 	lb, resp, err := l.resources.gclient.LoadBalancers.Get(ctx, id)
 	if err != nil {
 		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			l.resources.loadBalancerDeleted(id)
 			return nil, errLBNotFound
 		}
 
 		return nil, fmt.Errorf("failed to get load-balancer by ID %s: %s", id, err)
 	}
+	l.resources.loadBalancerChanged(lb)
 	return lb, nil
 }
 
-func findLoadBalancerByName(service *v1.Service, allLBs []godo.LoadBalancer) *godo.LoadBalancer {
-	customName := getLoadBalancerName(service)
-	legacyName := getLoadBalancerLegacyName(service)
-	candidates := []string{customName}
-	if legacyName != customName {
-		candidates = append(candidates, legacyName)
-	}
+func (r *resources) findLoadBalancerByName(service *v1.Service, allLBs []godo.LoadBalancer) *godo.LoadBalancer {
+	candidates := r.loadBalancerNameCandidates(service)
 
 	klog.V(2).Infof("Looking up load-balancer for service %s/%s by name (candidates: %s)", service.Namespace, service.Name, strings.Join(candidates, ", "))
 
@@ -451,13 +713,22 @@ func findLoadBalancerByName(service *v1.Service, allLBs []godo.LoadBalancer) *go
 	return nil
 }
 
-func findLoadBalancerID(service *v1.Service, allLBs []godo.LoadBalancer) string {
+func findLoadBalancerInList(id string, allLBs []godo.LoadBalancer) *godo.LoadBalancer {
+	for _, lb := range allLBs {
+		if lb.ID == id {
+			return &lb
+		}
+	}
+	return nil
+}
+
+func (r *resources) findLoadBalancerID(service *v1.Service, allLBs []godo.LoadBalancer) string {
 	id := getLoadBalancerID(service)
 	if len(id) > 0 {
 		return id
 	}
 
-	lb := findLoadBalancerByName(service, allLBs)
+	lb := r.findLoadBalancerByName(service, allLBs)
 	if lb == nil {
 		return ""
 	}
@@ -477,7 +748,7 @@ func updateServiceAnnotation(service *v1.Service, annotName, annotValue string)
 // Node names are assumed to match droplet names.
 func (l *loadBalancers) nodesToDropletIDs(ctx context.Context, nodes []*v1.Node) ([]int, error) {
 	var dropletIDs []int
-	missingDroplets := map[string]bool{}
+	var missingNames []string
 
 	for _, node := range nodes {
 		providerID := node.Spec.ProviderID
@@ -488,53 +759,42 @@ func (l *loadBalancers) nodesToDropletIDs(ctx context.Context, nodes []*v1.Node)
 			}
 			dropletIDs = append(dropletIDs, dropletID)
 		} else {
-			missingDroplets[node.Name] = true
+			missingNames = append(missingNames, node.Name)
 		}
 	}
 
-	if len(missingDroplets) > 0 {
-		// Discover missing droplets by matching names.
-		droplets, err := allDropletList(ctx, l.resources.gclient)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list all droplets: %s", err)
+	// Discover droplets of nodes lacking a provider ID by matching names or
+	// IP addresses.
+	var notFoundNames []string
+	for _, name := range missingNames {
+		droplet, err := l.resources.dropletByName(ctx, types.NodeName(name))
+		if err == cloudprovider.InstanceNotFound {
+			notFoundNames = append(notFoundNames, name)
+			continue
 		}
-
-		for _, droplet := range droplets {
-			if missingDroplets[droplet.Name] {
-				dropletIDs = append(dropletIDs, droplet.ID)
-				delete(missingDroplets, droplet.Name)
-				continue
-			}
-			addresses, err := nodeAddresses(&droplet)
-			if err != nil {
-				klog.Errorf("Error getting node addresses for %s: %s", droplet.Name, err)
-				continue
-			}
-			for _, address := range addresses {
-				if missingDroplets[address.Address] {
-					dropletIDs = append(dropletIDs, droplet.ID)
-					delete(missingDroplets, droplet.Name)
-					break
-				}
-			}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up droplet for node %q: %s", name, err)
 		}
+		dropletIDs = append(dropletIDs, droplet.ID)
 	}
 
-	if len(missingDroplets) > 0 {
+	if len(notFoundNames) > 0 {
 		// Sort node names for stable output.
-		missingNames := make([]string, 0, len(missingDroplets))
-		for missingName := range missingDroplets {
-			missingNames = append(missingNames, missingName)
-		}
-		sort.Strings(missingNames)
+		sort.Strings(notFoundNames)
 
-		klog.Errorf("Failed to find droplets for nodes %s", strings.Join(missingNames, " "))
+		klog.Errorf("Failed to find droplets for nodes %s", strings.Join(notFoundNames, " "))
 	}
 
 	return dropletIDs, nil
 }
 
 func buildLoadBalancerRequest(ctx context.Context, service *v1.Service, godoClient *godo.Client) (*godo.LoadBalancerRequest, error) {
+	if err := validateLoadBalancerNameAnnotation(service); err != nil {
+		return nil, err
+	}
+	if _, err := getNodeSelector(service); err != nil {
+		return nil, err
+	}
 	lbName := getLoadBalancerName(service)
 
 	lbType, err := getType(service)
@@ -545,8 +805,21 @@ func buildLoadBalancerRequest(ctx context.Context, service *v1.Service, godoClie
 	if err != nil {
 		return nil, err
 	}
+	if err := validateGLBAnnotations(service, lbType); err != nil {
+		return nil, err
+	}
+	backendTag, err := getBackendTag(service, lbType)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateEndpointAwareBackends(service, backendTag); err != nil {
+		return nil, err
+	}
 	var forwardingRules []godo.ForwardingRule
 	if lbType == godo.LoadBalancerTypeRegionalNetwork {
+		if _, ok := service.Annotations[annDOPortConfig]; ok {
+			return nil, fmt.Errorf("annotation %q is not supported for load-balancers of type %s", annDOPortConfig, lbType)
+		}
 		forwardingRules, err = buildRegionalNetworkForwardingRule(service)
 		if err != nil {
 			return nil, err
@@ -614,6 +887,29 @@ func buildLoadBalancerRequest(ctx context.Context, service *v1.Service, godoClie
 		return nil, err
 	}
 
+	var (
+		glbSettings           *godo.GLBSettings
+		domains               []*godo.LBDomain
+		targetLoadBalancerIDs []string
+	)
+	if lbType == godo.LoadBalancerTypeGlobal {
+		if lbNetwork == godo.LoadBalancerNetworkTypeInternal {
+			return nil, fmt.Errorf("LB type %s does not support network %s", godo.LoadBalancerTypeGlobal, godo.LoadBalancerNetworkTypeInternal)
+		}
+
+		glbSettings, err = buildGLBSettings(service)
+		if err != nil {
+			return nil, err
+		}
+
+		domains, err = buildGLBDomains(service)
+		if err != nil {
+			return nil, err
+		}
+
+		targetLoadBalancerIDs = getGLBTargetLoadBalancerIDs(service)
+	}
+
 	return &godo.LoadBalancerRequest{
 		Name:                         lbName,
 		SizeSlug:                     sizeSlug,
@@ -630,22 +926,60 @@ func buildLoadBalancerRequest(ctx context.Context, service *v1.Service, godoClie
 		Firewall:                     fw,
 		Type:                         lbType,
 		Network:                      lbNetwork,
+		GLBSettings:                  glbSettings,
+		Domains:                      domains,
+		TargetLoadBalancerIDs:        targetLoadBalancerIDs,
+		Tag:                          backendTag,
 	}, nil
 }
 
 // buildLoadBalancerRequest returns a *godo.LoadBalancerRequest to balance
-// requests for service across nodes.
+// requests for service across nodes. The droplets of nodes are tagged as
+// needed if the load-balancer targets a tag.
 func (l *loadBalancers) buildLoadBalancerRequest(ctx context.Context, service *v1.Service, nodes []*v1.Node) (*godo.LoadBalancerRequest, error) {
+	return l.renderLoadBalancerRequest(ctx, service, nodes, true)
+}
+
+// desiredLoadBalancerRequest returns the request buildLoadBalancerRequest
+// would return without any side effects: droplets are not tagged and no
+// events are recorded.
+func (l *loadBalancers) desiredLoadBalancerRequest(ctx context.Context, service *v1.Service, nodes []*v1.Node) (*godo.LoadBalancerRequest, error) {
+	return l.renderLoadBalancerRequest(ctx, service, nodes, false)
+}
+
+func (l *loadBalancers) renderLoadBalancerRequest(ctx context.Context, service *v1.Service, nodes []*v1.Node, apply bool) (*godo.LoadBalancerRequest, error) {
 	req, err := buildLoadBalancerRequest(ctx, service, l.resources.gclient)
 	if err != nil {
 		return nil, err
 	}
 
-	dropletIDs, err := l.nodesToDropletIDs(ctx, nodes)
-	if err != nil {
-		return nil, err
+	// Global load balancers forwarding to regional load balancers do not
+	// target any droplets.
+	if len(req.TargetLoadBalancerIDs) == 0 {
+		nodes := l.excludeDrainingNodes(nodes)
+		selected, err := l.selectNodes(service, nodes, apply)
+		if err != nil {
+			return nil, err
+		}
+		// Load balancers targeting a tag pick up the tagged droplets
+		// without being updated. Requests built without nodes, e.g. when
+		// a Service leaves its sharing group, leave the tags alone.
+		if req.Tag != "" {
+			if apply && nodes != nil {
+				if err := l.ensureBackendTag(ctx, service, req.Tag, nodes, selected); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			dropletIDs, err := l.nodesToDropletIDs(ctx, l.endpointNodes(service, selected))
+			if err != nil {
+				return nil, err
+			}
+			req.DropletIDs = dropletIDs
+		}
 	}
-	req.DropletIDs = dropletIDs
+
+	req.Name = l.resources.loadBalancerName(service)
 
 	var tags []string
 	if l.resources.clusterID != "" {
@@ -653,8 +987,7 @@ func (l *loadBalancers) buildLoadBalancerRequest(ctx context.Context, service *v
 	}
 	req.Tags = tags
 
-	req.Region = l.region
-	req.VPCUUID = l.resources.clusterVPCID
+	setLoadBalancerPlacement(req, l.region, l.resources.clusterVPCID)
 	return req, nil
 }
 
@@ -786,7 +1119,14 @@ func buildForwardingRules(ctx context.Context, service *v1.Service, godoClient *
 	tlsPassThrough := getTLSPassThrough(service)
 	needSecureProto := certificateID != "" || tlsPassThrough
 
-	if needSecureProto && len(httpsPorts) == 0 && !contains(http2Ports, defaultSecurePort) {
+	useAppProtocols, err := getAppProtocols(service)
+	if err != nil {
+		return nil, err
+	}
+
+	// The secure port only defaults to https if its application protocol
+	// does not say otherwise.
+	if needSecureProto && len(httpsPorts) == 0 && !contains(http2Ports, defaultSecurePort) && !(useAppProtocols && hasKnownAppProtocol(service, defaultSecurePort, certificateID != "", tlsPassThrough)) {
 		httpsPorts = append(httpsPorts, defaultSecurePort)
 	}
 
@@ -803,34 +1143,73 @@ func buildForwardingRules(ctx context.Context, service *v1.Service, godoClient *
 		http2PortMap[int32(port)] = true
 	}
 
+	portConfigs, err := getPortConfigs(service)
+	if err != nil {
+		return nil, err
+	}
+
+	var http3Rules []godo.ForwardingRule
 	for _, port := range service.Spec.Ports {
-		protocol := defaultProtocol
-		if httpPortMap[port.Port] {
-			protocol = protocolHTTP
-		}
-		if httpsPortMap[port.Port] {
-			protocol = protocolHTTPS
+		if cfg, ok := portConfigs[port.Port]; ok {
+			forwardingRule, err := buildConfiguredForwardingRule(ctx, &port, cfg, godoClient)
+			if err != nil {
+				return nil, err
+			}
+			forwardingRules = append(forwardingRules, *forwardingRule)
+			continue
 		}
-		if http2PortMap[port.Port] {
+
+		// The port annotations take precedence over the application
+		// protocol, which only applies without a protocol annotation.
+		protocol := defaultProtocol
+		var targetProtocol string
+		switch {
+		case http2PortMap[port.Port]:
 			protocol = protocolHTTP2
+		case httpsPortMap[port.Port]:
+			protocol = protocolHTTPS
+		case httpPortMap[port.Port]:
+			protocol = protocolHTTP
+		case useAppProtocols:
+			if entry, target, ok := appProtocolForwarding(port.AppProtocol, certificateID != "", tlsPassThrough); ok {
+				protocol, targetProtocol = entry, target
+				if isHTTP3AppProtocol(port.AppProtocol) && port.Protocol != v1.ProtocolUDP {
+					http3Rules = append(http3Rules, godo.ForwardingRule{
+						EntryProtocol:  protocolHTTP3,
+						EntryPort:      int(port.Port),
+						CertificateID:  certificateID,
+						TargetProtocol: protocolHTTP,
+						TargetPort:     int(port.NodePort),
+					})
+				}
+			}
 		}
 
 		if port.Protocol == v1.ProtocolUDP {
 			protocol = protocolUDP
+			targetProtocol = ""
 		}
 
 		forwardingRule, err := buildForwardingRule(service, &port, protocol, certificateID, tlsPassThrough)
 		if err != nil {
 			return nil, err
 		}
+		if targetProtocol != "" {
+			forwardingRule.TargetProtocol = targetProtocol
+		}
 		forwardingRules = append(forwardingRules, *forwardingRule)
 	}
 
 	if h3, err := buildHTTP3ForwardingRule(ctx, service, godoClient); err != nil {
 		return nil, fmt.Errorf("failed to construct http3 forwarding rule: %w", err)
 	} else if h3 != nil {
-		forwardingRules = append(forwardingRules, *h3)
+		http3Rules = append(http3Rules, *h3)
+	}
+	// Load balancers support a single http3 forwarding rule.
+	if len(http3Rules) > 1 {
+		return nil, fmt.Errorf("only one port may use http3, but found %d through annotation %q and application protocol %q", len(http3Rules), annDOHTTP3Port, appProtocolHTTP3)
 	}
+	forwardingRules = append(forwardingRules, http3Rules...)
 
 	return forwardingRules, nil
 }
@@ -978,7 +1357,7 @@ func getProtocol(service *v1.Service) (string, error) {
 	switch protocol {
 	case protocolTCP, protocolHTTP, protocolHTTPS, protocolHTTP2, protocolHTTP3:
 	default:
-		return "", fmt.Errorf("invalid protocol %q specified in annotation %q", protocol, annDOProtocol)
+		return "", invalidAnnotation(annDOProtocol, "invalid protocol %q specified in annotation %q", protocol, annDOProtocol)
 	}
 
 	return protocol, nil
@@ -994,7 +1373,7 @@ func getHostname(service *v1.Service) string {
 func healthCheckPort(service *v1.Service) (int, error) {
 	ports, err := getPorts(service, annDOHealthCheckPort)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get health check port: %v", err)
+		return 0, fmt.Errorf("failed to get health check port: %w", err)
 	}
 
 	if len(ports) > 1 {
@@ -1040,7 +1419,7 @@ func healthCheckProtocol(service *v1.Service) (string, error) {
 	switch protocol {
 	case protocolTCP, protocolHTTP, protocolHTTPS:
 	default:
-		return "", fmt.Errorf("invalid protocol %q specified in annotation %q", protocol, annDOHealthCheckProtocol)
+		return "", invalidAnnotation(annDOHealthCheckProtocol, "invalid protocol %q specified in annotation %q", protocol, annDOHealthCheckProtocol)
 	}
 
 	return protocol, nil
@@ -1077,7 +1456,7 @@ func healthCheckIntervalSeconds(service *v1.Service) (int, error) {
 
 	val, err := strconv.Atoi(valStr)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse health check interval annotation %q: %s", annDOHealthCheckIntervalSeconds, err)
+		return 0, invalidAnnotation(annDOHealthCheckIntervalSeconds, "failed to parse health check interval annotation %q: %s", annDOHealthCheckIntervalSeconds, err)
 	}
 
 	return val, nil
@@ -1092,7 +1471,7 @@ func healthCheckResponseTimeoutSeconds(service *v1.Service) (int, error) {
 
 	val, err := strconv.Atoi(valStr)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse health check response timeout annotation %q: %s", annDOHealthCheckResponseTimeoutSeconds, err)
+		return 0, invalidAnnotation(annDOHealthCheckResponseTimeoutSeconds, "failed to parse health check response timeout annotation %q: %s", annDOHealthCheckResponseTimeoutSeconds, err)
 	}
 
 	return val, nil
@@ -1107,7 +1486,7 @@ func healthCheckUnhealthyThreshold(service *v1.Service) (int, error) {
 
 	val, err := strconv.Atoi(valStr)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse health check unhealthy threshold annotation %q: %s", annDOHealthCheckUnhealthyThreshold, err)
+		return 0, invalidAnnotation(annDOHealthCheckUnhealthyThreshold, "failed to parse health check unhealthy threshold annotation %q: %s", annDOHealthCheckUnhealthyThreshold, err)
 	}
 
 	return val, nil
@@ -1122,7 +1501,7 @@ func healthCheckHealthyThreshold(service *v1.Service) (int, error) {
 
 	val, err := strconv.Atoi(valStr)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse health check healthy threshold annotation %q: %s", annDOHealthCheckHealthyThreshold, err)
+		return 0, invalidAnnotation(annDOHealthCheckHealthyThreshold, "failed to parse health check healthy threshold annotation %q: %s", annDOHealthCheckHealthyThreshold, err)
 	}
 
 	return val, nil
@@ -1149,7 +1528,7 @@ func getHTTP3Port(service *v1.Service) (int, error) {
 
 	port, err := strconv.Atoi(portStr)
 	if err != nil {
-		return -1, err
+		return -1, annotationError{name: annDOHTTP3Port, err: err}
 	}
 
 	return port, nil
@@ -1174,7 +1553,7 @@ func getPorts(service *v1.Service, anno string) ([]int, error) {
 	for i, port := range portsSlice {
 		port, err := strconv.Atoi(port)
 		if err != nil {
-			return nil, err
+			return nil, annotationError{name: anno, err: err}
 		}
 
 		portsInt[i] = port
@@ -1218,6 +1597,12 @@ func findCertificateID(ctx context.Context, service *v1.Service, godoClient *god
 	if certificateName == "" {
 		return "", nil
 	}
+	return findCertificateIDByName(ctx, certificateName, godoClient)
+}
+
+// findCertificateIDByName returns the ID of the certificate with the given
+// name.
+func findCertificateIDByName(ctx context.Context, certificateName string, godoClient *godo.Client) (string, error) {
 	lbCert, _, err := godoClient.Certificates.ListByName(ctx, certificateName, &godo.ListOptions{Page: 1, PerPage: 1})
 	if err != nil {
 		return "", fmt.Errorf("failed to get certificate by name: %q error: %s", certificateName, err)
@@ -1256,7 +1641,7 @@ func getSizeSlug(service *v1.Service) (string, error) {
 		switch sizeSlug {
 		case "lb-small", "lb-medium", "lb-large":
 		default:
-			return "", fmt.Errorf("invalid LB size slug provided: %s", sizeSlug)
+			return "", invalidAnnotation(annDOSizeSlug, "invalid LB size slug provided: %s", sizeSlug)
 		}
 	}
 
@@ -1273,11 +1658,11 @@ func getSizeUnit(service *v1.Service) (uint32, error) {
 
 	sizeUnit, err := strconv.Atoi(sizeUnitStr)
 	if err != nil {
-		return 0, fmt.Errorf("invalid LB size unit %q provided: %s", sizeUnitStr, err)
+		return 0, invalidAnnotation(annDOSizeUnit, "invalid LB size unit %q provided: %s", sizeUnitStr, err)
 	}
 
 	if sizeUnit < 0 {
-		return 0, fmt.Errorf("LB size unit must be non-negative. %d provided", sizeUnit)
+		return 0, invalidAnnotation(annDOSizeUnit, "LB size unit must be non-negative. %d provided", sizeUnit)
 	}
 
 	return uint32(sizeUnit), nil
@@ -1301,7 +1686,7 @@ func getStickySessionsType(service *v1.Service) string {
 func getStickySessionsCookieName(service *v1.Service) (string, error) {
 	name, ok := service.Annotations[annDOStickySessionsCookieName]
 	if !ok || name == "" {
-		return "", fmt.Errorf("sticky session cookie name not specified, but required")
+		return "", invalidAnnotation(annDOStickySessionsCookieName, "sticky session cookie name not specified, but required")
 	}
 
 	return name, nil
@@ -1312,10 +1697,14 @@ func getStickySessionsCookieName(service *v1.Service) (string, error) {
 func getStickySessionsCookieTTL(service *v1.Service) (int, error) {
 	ttl, ok := service.Annotations[annDOStickySessionsCookieTTL]
 	if !ok || ttl == "" {
-		return 0, fmt.Errorf("sticky session cookie ttl not specified, but required")
+		return 0, invalidAnnotation(annDOStickySessionsCookieTTL, "sticky session cookie ttl not specified, but required")
 	}
 
-	return strconv.Atoi(ttl)
+	val, err := strconv.Atoi(ttl)
+	if err != nil {
+		return 0, annotationError{name: annDOStickySessionsCookieTTL, err: err}
+	}
+	return val, nil
 }
 
 // getRedirectHTTPToHTTPS returns whether or not Http traffic should be redirected
@@ -1323,7 +1712,7 @@ func getStickySessionsCookieTTL(service *v1.Service) (int, error) {
 func getRedirectHTTPToHTTPS(service *v1.Service) (bool, error) {
 	redirectHTTPToHTTPS, _, err := getBool(service.Annotations, annDORedirectHTTPToHTTPS)
 	if err != nil {
-		return false, fmt.Errorf("failed to get HTTP-to-HTTPS configuration setting: %s", err)
+		return false, fmt.Errorf("failed to get HTTP-to-HTTPS configuration setting: %w", err)
 	}
 	return redirectHTTPToHTTPS, nil
 }
@@ -1333,7 +1722,7 @@ func getRedirectHTTPToHTTPS(service *v1.Service) (bool, error) {
 func getEnableProxyProtocol(service *v1.Service) (bool, error) {
 	enableProxyProtocol, _, err := getBool(service.Annotations, annDOEnableProxyProtocol)
 	if err != nil {
-		return false, fmt.Errorf("failed to get proxy protocol configuration setting: %s", err)
+		return false, fmt.Errorf("failed to get proxy protocol configuration setting: %w", err)
 	}
 	return enableProxyProtocol, nil
 }
@@ -1343,7 +1732,7 @@ func getEnableProxyProtocol(service *v1.Service) (bool, error) {
 func getDisableLetsEncryptDNSRecords(service *v1.Service) (bool, error) {
 	disableLetsEncryptDNSRecords, _, err := getBool(service.Annotations, annDODisableLetsEncryptDNSRecords)
 	if err != nil {
-		return false, fmt.Errorf("failed to get disable lets encrypt dns records configuration setting: %s", err)
+		return false, fmt.Errorf("failed to get disable lets encrypt dns records configuration setting: %w", err)
 	}
 
 	return disableLetsEncryptDNSRecords, nil
@@ -1354,7 +1743,7 @@ func getDisableLetsEncryptDNSRecords(service *v1.Service) (bool, error) {
 func getEnableBackendKeepalive(service *v1.Service) (bool, error) {
 	enableBackendKeepalive, _, err := getBool(service.Annotations, annDOEnableBackendKeepalive)
 	if err != nil {
-		return false, fmt.Errorf("failed to get backend keepalive configuration setting: %s", err)
+		return false, fmt.Errorf("failed to get backend keepalive configuration setting: %w", err)
 	}
 	return enableBackendKeepalive, nil
 }
@@ -1367,7 +1756,7 @@ func getHttpIdleTimeoutSeconds(service *v1.Service) (*uint64, error) {
 
 	httpIdleTimeout, err := strconv.ParseUint(httpIdleTimeoutSeconds, 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse provided LB HTTP idle timeout seconds value '%s': %s", httpIdleTimeoutSeconds, err)
+		return nil, invalidAnnotation(annDOHttpIdleTimeoutSeconds, "failed to parse provided LB HTTP idle timeout seconds value '%s': %s", httpIdleTimeoutSeconds, err)
 	}
 
 	return &httpIdleTimeout, nil
@@ -1382,7 +1771,7 @@ func getLoadBalancerID(service *v1.Service) string {
 func getDisownLB(service *v1.Service) (bool, error) {
 	disownLB, _, err := getBool(service.Annotations, annDODisownLB)
 	if err != nil {
-		return false, fmt.Errorf("failed to get disown LB configuration setting: %s", err)
+		return false, fmt.Errorf("failed to get disown LB configuration setting: %w", err)
 	}
 	return disownLB, nil
 }
@@ -1392,8 +1781,10 @@ func getType(service *v1.Service) (string, error) {
 	if !ok || name == "" {
 		return godo.LoadBalancerTypeRegional, nil
 	}
-	if !(name == godo.LoadBalancerTypeRegional || name == godo.LoadBalancerTypeRegionalNetwork) {
-		return "", fmt.Errorf("only LB types supported are (%s, %s)", godo.LoadBalancerTypeRegional, godo.LoadBalancerTypeRegionalNetwork)
+	switch name {
+	case godo.LoadBalancerTypeRegional, godo.LoadBalancerTypeRegionalNetwork, godo.LoadBalancerTypeGlobal:
+	default:
+		return "", invalidAnnotation(annDOType, "only LB types supported are (%s, %s, %s)", godo.LoadBalancerTypeRegional, godo.LoadBalancerTypeRegionalNetwork, godo.LoadBalancerTypeGlobal)
 	}
 	return name, nil
 }
@@ -1404,7 +1795,7 @@ func getNetwork(service *v1.Service) (string, error) {
 		return godo.LoadBalancerNetworkTypeExternal, nil
 	}
 	if !(network == godo.LoadBalancerNetworkTypeExternal || network == godo.LoadBalancerNetworkTypeInternal) {
-		return "", fmt.Errorf("only LB networks supported are (%s, %s)", godo.LoadBalancerNetworkTypeExternal, godo.LoadBalancerNetworkTypeInternal)
+		return "", invalidAnnotation(annDONetwork, "only LB networks supported are (%s, %s)", godo.LoadBalancerNetworkTypeExternal, godo.LoadBalancerNetworkTypeInternal)
 	}
 	return network, nil
 }
@@ -24,6 +24,7 @@ import (
 	"io"
 	"net/http"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -127,6 +128,28 @@ func newFakeLBClient(fakeLB *fakeLBService) *godo.Client {
 	return newFakeClient(nil, fakeLB, nil)
 }
 
+// newTestService returns a LoadBalancer Service with the given annotations
+// and ports. A single TCP port is used if no ports are given.
+func newTestService(annotations map[string]string, ports ...v1.ServicePort) *v1.Service {
+	if len(ports) == 0 {
+		ports = []v1.ServicePort{
+			{Name: "test", Protocol: v1.ProtocolTCP, Port: 80, NodePort: 30000},
+		}
+	}
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   v1.NamespaceDefault,
+			UID:         "abc123",
+			Annotations: annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeLoadBalancer,
+			Ports: slices.Clone(ports),
+		},
+	}
+}
+
 func createLB() *godo.LoadBalancer {
 	return &godo.LoadBalancer{
 		ID:     "load-balancer-id",
@@ -909,7 +932,7 @@ func Test_getProtocol(t *testing.T) {
 				},
 			},
 			"",
-			fmt.Errorf("invalid protocol %q specified in annotation %q", "invalid", annDOProtocol),
+			invalidAnnotation(annDOProtocol, "invalid protocol %q specified in annotation %q", "invalid", annDOProtocol),
 		},
 	}
 
@@ -1029,7 +1052,7 @@ func Test_getStickySessionsCookieName(t *testing.T) {
 				},
 			},
 			"",
-			fmt.Errorf("sticky session cookie name not specified, but required"),
+			invalidAnnotation(annDOStickySessionsCookieName, "sticky session cookie name not specified, but required"),
 		},
 		{
 			"sticky sessions cookie name not defined",
@@ -1041,7 +1064,7 @@ func Test_getStickySessionsCookieName(t *testing.T) {
 				},
 			},
 			"",
-			fmt.Errorf("sticky session cookie name not specified, but required"),
+			invalidAnnotation(annDOStickySessionsCookieName, "sticky session cookie name not specified, but required"),
 		},
 	}
 
@@ -1094,7 +1117,7 @@ func Test_getStickySessionsCookieTTL(t *testing.T) {
 				},
 			},
 			0,
-			fmt.Errorf("sticky session cookie ttl not specified, but required"),
+			invalidAnnotation(annDOStickySessionsCookieTTL, "sticky session cookie ttl not specified, but required"),
 		},
 		{
 			"sticky sessions cookie ttl not defined",
@@ -1106,7 +1129,7 @@ func Test_getStickySessionsCookieTTL(t *testing.T) {
 				},
 			},
 			0,
-			fmt.Errorf("sticky session cookie ttl not specified, but required"),
+			invalidAnnotation(annDOStickySessionsCookieTTL, "sticky session cookie ttl not specified, but required"),
 		},
 	}
 
@@ -3316,7 +3339,7 @@ func Test_buildStickySessions(t *testing.T) {
 				},
 			},
 			nil,
-			fmt.Errorf("sticky session cookie ttl not specified, but required"),
+			invalidAnnotation(annDOStickySessionsCookieTTL, "sticky session cookie ttl not specified, but required"),
 		},
 		{
 			"sticky sessions type cookies without name",
@@ -3329,7 +3352,7 @@ func Test_buildStickySessions(t *testing.T) {
 				},
 			},
 			nil,
-			fmt.Errorf("sticky session cookie name not specified, but required"),
+			invalidAnnotation(annDOStickySessionsCookieName, "sticky session cookie name not specified, but required"),
 		},
 		{
 			"sticky sessions type cookies without name and ttl",
@@ -3341,7 +3364,7 @@ func Test_buildStickySessions(t *testing.T) {
 				},
 			},
 			nil,
-			fmt.Errorf("sticky session cookie name not specified, but required"),
+			invalidAnnotation(annDOStickySessionsCookieName, "sticky session cookie name not specified, but required"),
 		},
 	}
 
@@ -4723,15 +4746,18 @@ func Test_buildLoadBalancerRequestWithClusterID(t *testing.T) {
 					},
 				},
 			}
+			droplets := []godo.Droplet{
+				{
+					ID:   100,
+					Name: "node-1",
+				},
+			}
 			fakeClient := newFakeDropletClient(
 				&fakeDropletService{
 					listFunc: func(context.Context, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
-						droplets := []godo.Droplet{
-							{
-								ID:   100,
-								Name: "node-1",
-							},
-						}
+						return droplets, newFakeOKResponse(), nil
+					},
+					listByTagFunc: func(context.Context, string, *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
 						return droplets, newFakeOKResponse(), nil
 					},
 				},
@@ -4931,6 +4957,39 @@ func Test_nodeToDropletIDs(t *testing.T) {
 			dropletIDs:   []int{100, 101},
 			missingNames: []string{"node-3", "node-4"},
 		},
+		{
+			name: "droplets matched by IPv4 and IPv6 addresses",
+			nodes: []*v1.Node{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "10.0.0.1",
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "2001:db8::2",
+					},
+				},
+			},
+			droplets: []godo.Droplet{
+				{
+					ID:   100,
+					Name: "node-1",
+					Networks: &godo.Networks{
+						V4: []godo.NetworkV4{{IPAddress: "10.0.0.1", Type: "private"}},
+					},
+				},
+				{
+					ID:   101,
+					Name: "node-2",
+					Networks: &godo.Networks{
+						V4: []godo.NetworkV4{{IPAddress: "10.0.0.2", Type: "private"}},
+						V6: []godo.NetworkV6{{IPAddress: "2001:db8:0::2", Type: "public"}},
+					},
+				},
+			},
+			dropletIDs: []int{100, 101},
+		},
 	}
 
 	for _, test := range testcases {
@@ -6096,6 +6155,7 @@ func Test_getType(t *testing.T) {
 	var (
 		regional        = godo.LoadBalancerTypeRegional
 		regionalNetwork = godo.LoadBalancerTypeRegionalNetwork
+		global          = godo.LoadBalancerTypeGlobal
 	)
 	testcases := []struct {
 		name         string
@@ -6142,6 +6202,20 @@ func Test_getType(t *testing.T) {
 			wantErr:      false,
 			expectedType: &regionalNetwork,
 		},
+		{
+			name: "annotation set to GLOBAL",
+			service: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+					UID:  "abc123",
+					Annotations: map[string]string{
+						annDOType: godo.LoadBalancerTypeGlobal,
+					},
+				},
+			},
+			wantErr:      false,
+			expectedType: &global,
+		},
 		{
 			name: "illegal value",
 			service: &v1.Service{
@@ -25,6 +25,7 @@ const (
 	firewallOperationGetByList = "get_by_list"
 	firewallOperationCreate    = "create"
 	firewallOperationUpdate    = "update"
+	firewallOperationDelete    = "delete"
 )
 
 type metrics struct {
@@ -99,6 +100,73 @@ var (
 		},
 		[]string{"result", "error_type"},
 	)
+	dropletInventoryLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "droplet_inventory",
+			Name:      "lookups_total",
+			Help:      "The total number of droplet inventory lookups by index and result (hit or miss).",
+		},
+		[]string{"index", "result"},
+	)
+	lbDrift = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "loadbalancer_drift",
+			Help: "Whether the load-balancer of a Service deviates from the configuration rendered from the Service (1) or not (0).",
+		},
+		[]string{"namespace", "service"},
+	)
+	lbDriftFieldsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loadbalancer_drift_fields_total",
+			Help: "The total number of load-balancer fields found to deviate from the configuration rendered from the Service, by field.",
+		},
+		[]string{"field"},
+	)
+	lbGCOrphans = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "loadbalancer_gc_orphans",
+			Help: "The number of load-balancers tagged with the cluster ID that do not belong to any Service.",
+		},
+	)
+	lbGCDeletionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loadbalancer_gc_deletions_total",
+			Help: "The total number of orphaned load-balancer deletions by result (deleted, failed, or dry_run).",
+		},
+		[]string{"result"},
+	)
+	lbRemediationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loadbalancer_remediations_total",
+			Help: "The total number of remediations of load-balancers stuck in a non-active status, by status and action (alert, retry, or recreate).",
+		},
+		[]string{"status", "action"},
+	)
+	lbNodesDraining = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "loadbalancer_nodes_draining",
+			Help: "The number of nodes waiting for their connections to be drained from load-balancers.",
+		},
+	)
+	lbNodeDrainsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "loadbalancer_node_drains_total",
+			Help: "The total number of nodes whose connections were drained from load-balancers.",
+		},
+	)
+	lbUpdatesCoalescedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "loadbalancer_updates_coalesced_total",
+			Help: "The total number of load-balancer node updates saved by coalescing them with a pending update.",
+		},
+	)
+	lbBatchedUpdatesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "loadbalancer_batched_updates_total",
+			Help: "The total number of coalesced load-balancer node updates applied, by result (updated or failed).",
+		},
+		[]string{"result"},
+	)
 )
 
 func newMetrics(host string) metrics {
@@ -20,21 +20,27 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"text/template"
 	"time"
 
 	"github.com/digitalocean/godo"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	v1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	v1lister "k8s.io/client-go/listers/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 )
 
 const (
 	controllerSyncTagsPeriod = 15 * time.Minute
 	syncTagsTimeout          = 1 * time.Minute
+	syncLBInventoryTimeout   = 1 * time.Minute
+
+	syncDropletInventoryTimeout = 1 * time.Minute
 )
 
 type tagMissingError struct {
@@ -51,6 +57,24 @@ type resources struct {
 	clusterVPCID string
 	firewall     publicAccessFirewall
 
+	// nodeAddresses controls which droplet addresses are reported for nodes.
+	nodeAddresses nodeAddressConfig
+
+	// lbInventory is the shared inventory of all load-balancers. Lookups
+	// fall back to listing load-balancers directly if it is nil.
+	lbInventory *lbInventory
+
+	// dropletInventory is the shared inventory of droplets. Lookups fall
+	// back to the API directly if it is nil.
+	dropletInventory *dropletInventory
+
+	// lbGC configures the garbage collection of orphaned load-balancers.
+	lbGC lbGCConfig
+
+	// lbNameTemplate renders the names of load-balancers of Services lacking
+	// a custom name. Legacy names are used if it is nil.
+	lbNameTemplate *template.Template
+
 	gclient *godo.Client
 	kclient kubernetes.Interface
 }
@@ -61,13 +85,157 @@ type resources struct {
 // initialization order guarantees that kclient won't be consumed prior to it
 // being set.
 func newResources(clusterID, clusterVPCID string, publicAccessFW publicAccessFirewall, gclient *godo.Client) *resources {
-	return &resources{
+	r := &resources{
 		clusterID:    clusterID,
 		clusterVPCID: clusterVPCID,
 		firewall:     publicAccessFW,
+		lbInventory:  newLBInventory(gclient, lbInventoryRefreshPeriod),
 
 		gclient: gclient,
 	}
+
+	// Cluster droplets are tagged with the cluster ID, so only those need
+	// to be listed if it is known.
+	var dropletTag string
+	if clusterID != "" {
+		dropletTag = buildK8sTag(clusterID)
+	}
+	r.dropletInventory = newDropletInventory(gclient, dropletTag, dropletInventoryRefreshPeriod)
+
+	return r
+}
+
+// dropletByID returns the droplet identified by id.
+func (r *resources) dropletByID(ctx context.Context, id int) (*godo.Droplet, error) {
+	if r.dropletInventory == nil {
+		return dropletByID(ctx, r.gclient, id)
+	}
+	return r.dropletInventory.getByID(ctx, id)
+}
+
+// liveDropletByID returns the droplet identified by id from the API, bypassing
+// the droplet inventory, which may lag behind by up to its refresh period. The
+// inventory is updated with the result.
+func (r *resources) liveDropletByID(ctx context.Context, id int) (*godo.Droplet, error) {
+	droplet, err := dropletByID(ctx, r.gclient, id)
+	if r.dropletInventory != nil {
+		if err == nil {
+			r.dropletInventory.put(droplet)
+		} else if godoErr, ok := err.(*godo.ErrorResponse); ok && godoErr.Response.StatusCode == http.StatusNotFound {
+			r.dropletInventory.remove(id)
+		}
+	}
+	return droplet, err
+}
+
+// liveDropletByName returns the droplet identified by nodeName, which may be
+// the droplet's name or one of its IP addresses, from the API, bypassing the
+// droplet inventory. cloudprovider.InstanceNotFound is returned if no such
+// droplet exists.
+func (r *resources) liveDropletByName(ctx context.Context, nodeName types.NodeName) (*godo.Droplet, error) {
+	return dropletByName(ctx, r.gclient, nodeName)
+}
+
+// dropletByName returns the droplet identified by nodeName, which may be the
+// droplet's name or one of its IP addresses. cloudprovider.InstanceNotFound is
+// returned if no such droplet exists.
+func (r *resources) dropletByName(ctx context.Context, nodeName types.NodeName) (*godo.Droplet, error) {
+	if r.dropletInventory == nil {
+		return dropletByName(ctx, r.gclient, nodeName)
+	}
+
+	droplet, err := r.dropletInventory.getByName(ctx, string(nodeName))
+	if err != nil {
+		return nil, err
+	}
+	if droplet == nil {
+		return nil, cloudprovider.InstanceNotFound
+	}
+	return droplet, nil
+}
+
+// allLoadBalancers returns all load-balancers in the account.
+func (r *resources) allLoadBalancers(ctx context.Context) ([]godo.LoadBalancer, error) {
+	if r.lbInventory == nil {
+		return allLoadBalancerList(ctx, r.gclient)
+	}
+	return r.lbInventory.list(ctx)
+}
+
+// loadBalancerByName returns the first load-balancer matching one of names,
+// or nil if none matches.
+func (r *resources) loadBalancerByName(ctx context.Context, names ...string) (*godo.LoadBalancer, error) {
+	if r.lbInventory != nil {
+		return r.lbInventory.getByName(ctx, names...)
+	}
+
+	lbs, err := allLoadBalancerList(ctx, r.gclient)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		for _, lb := range lbs {
+			if lb.Name == name {
+				return &lb, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// loadBalancerByID returns the load-balancer with the given ID from the
+// inventory, or from the API if there is none. nil is returned if the
+// load-balancer does not exist.
+func (r *resources) loadBalancerByID(ctx context.Context, id string) (*godo.LoadBalancer, error) {
+	if r.lbInventory != nil {
+		return r.lbInventory.getByID(ctx, id)
+	}
+
+	lb, resp, err := r.gclient.LoadBalancers.Get(ctx, id)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return lb, nil
+}
+
+// isClusterTag returns whether tag is the tag carried by all droplets of the
+// cluster.
+func (r *resources) isClusterTag(tag string) bool {
+	return r.clusterID != "" && tag == buildK8sTag(r.clusterID)
+}
+
+// dropletChanged records that droplet was updated.
+func (r *resources) dropletChanged(droplet *godo.Droplet) {
+	if r.dropletInventory != nil {
+		r.dropletInventory.put(droplet)
+	}
+}
+
+// loadBalancerChanged records that lb was created, updated, or freshly
+// retrieved.
+func (r *resources) loadBalancerChanged(lb *godo.LoadBalancer) {
+	if r.lbInventory != nil {
+		r.lbInventory.put(lb)
+	}
+}
+
+// loadBalancerDeleted records that the load-balancer with the given ID no
+// longer exists.
+func (r *resources) loadBalancerDeleted(id string) {
+	if r.lbInventory != nil {
+		r.lbInventory.remove(id)
+	}
+}
+
+// invalidateLoadBalancers forces the next load-balancer lookup to refresh the
+// inventory.
+func (r *resources) invalidateLoadBalancers() {
+	if r.lbInventory != nil {
+		r.lbInventory.invalidate()
+	}
 }
 
 type syncer interface {
@@ -106,6 +274,10 @@ type ResourcesController struct {
 
 	resources *resources
 	syncer    syncer
+
+	// orphanedLBs holds the IDs of orphaned load-balancers along with the
+	// time they were first found to be orphaned.
+	orphanedLBs map[string]time.Time
 }
 
 // NewResourcesController returns a new resource controller.
@@ -117,16 +289,52 @@ func NewResourcesController(r *resources, inf v1informers.ServiceInformer, clien
 		kclient:   client,
 		svcLister: inf.Lister(),
 		syncer:    &tickerSyncer{},
+
+		orphanedLBs: map[string]time.Time{},
 	}
 }
 
 // Run starts the resources controller loop.
 func (r *ResourcesController) Run(stopCh <-chan struct{}) {
+	if r.resources.lbInventory != nil {
+		go r.syncer.Sync("load-balancer inventory syncer", lbInventoryRefreshPeriod, stopCh, r.syncLBInventory)
+	}
+	if r.resources.dropletInventory != nil {
+		go r.syncer.Sync("droplet inventory syncer", dropletInventoryRefreshPeriod, stopCh, r.syncDropletInventory)
+	}
+
 	if r.resources.clusterID == "" {
 		klog.Info("No cluster ID configured -- skipping cluster dependent syncers.")
 		return
 	}
 	go r.syncer.Sync("tags syncer", controllerSyncTagsPeriod, stopCh, r.syncTags)
+
+	if r.resources.lbGC.enabled() {
+		klog.Infof("Garbage-collecting orphaned load-balancers (mode: %s, grace period: %s)", r.resources.lbGC.mode, r.resources.lbGC.gracePeriod)
+		go r.syncer.Sync("load-balancer garbage collector", controllerGCLoadBalancersPeriod, stopCh, r.gcLoadBalancers)
+	}
+}
+
+// syncLBInventory refreshes the shared load-balancer inventory.
+func (r *ResourcesController) syncLBInventory() error {
+	ctx, cancel := context.WithTimeout(context.Background(), syncLBInventoryTimeout)
+	defer cancel()
+
+	if err := r.resources.lbInventory.refresh(ctx); err != nil {
+		return fmt.Errorf("failed to refresh load-balancer inventory: %s", err)
+	}
+	return nil
+}
+
+// syncDropletInventory refreshes the shared droplet inventory.
+func (r *ResourcesController) syncDropletInventory() error {
+	ctx, cancel := context.WithTimeout(context.Background(), syncDropletInventoryTimeout)
+	defer cancel()
+
+	if err := r.resources.dropletInventory.refresh(ctx); err != nil {
+		return fmt.Errorf("failed to refresh droplet inventory: %s", err)
+	}
+	return nil
 }
 
 // syncTags synchronizes tags. Currently, this is only needed to associate
@@ -152,7 +360,7 @@ func (r *ResourcesController) syncTags() error {
 		return nil
 	}
 
-	lbs, err := allLoadBalancerList(ctx, r.resources.gclient)
+	lbs, err := r.resources.allLoadBalancers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to list load-balancers: %s", err)
 	}
@@ -162,7 +370,23 @@ func (r *ResourcesController) syncTags() error {
 	// a matching name).
 	var res []godo.Resource
 	for _, svc := range lbSvcs {
-		id := findLoadBalancerID(svc, lbs)
+		id := r.resources.findLoadBalancerID(svc, lbs)
+
+		// Load-balancers created before CCM used cluster IDs lack the tag
+		// to be added, so only those residing in the cluster VPC are tagged
+		// unless they are adopted explicitly.
+		if id != "" && r.resources.clusterID != "" {
+			lb := findLoadBalancerInList(id, lbs)
+			if lb == nil {
+				continue
+			}
+			if !adoptsLoadBalancer(svc, lb) {
+				if err := r.resources.verifyLoadBalancerVPC(lb); err != nil {
+					klog.Warningf("Not tagging load-balancer for service %s/%s: %s", svc.Namespace, svc.Name, err)
+					continue
+				}
+			}
+		}
 
 		// Load-balancers that have no LB ID set yet and were renamed directly
 		// (e.g., via the cloud control panel) would still be missed, so check
@@ -179,16 +403,22 @@ func (r *ResourcesController) syncTags() error {
 		return nil
 	}
 
-	tag := buildK8sTag(r.resources.clusterID)
+	return r.resources.tagWithClusterID(ctx, res)
+}
+
+// tagWithClusterID tags the given resources with the cluster ID, creating the
+// tag if it does not exist yet.
+func (r *resources) tagWithClusterID(ctx context.Context, res []godo.Resource) error {
+	tag := buildK8sTag(r.clusterID)
 	// Tag collected resources with the cluster ID. If the tag does not exist
 	// (for reasons outlined below), we will create it and retry tagging again.
-	err = r.tagResources(res)
+	err := r.tagResources(res)
 	if _, ok := err.(tagMissingError); ok {
 		// Cluster ID tag has not been created yet. This should have happen
 		// when we set the tag on LB creation. For LBs that have been created
 		// prior to CCM using cluster IDs, however, we need to create the tag
 		// explicitly.
-		_, _, err = r.resources.gclient.Tags.Create(ctx, &godo.TagCreateRequest{
+		_, _, err = r.gclient.Tags.Create(ctx, &godo.TagCreateRequest{
 			Name: tag,
 		})
 		if err != nil {
@@ -207,11 +437,11 @@ func (r *ResourcesController) syncTags() error {
 	return nil
 }
 
-func (r *ResourcesController) tagResources(res []godo.Resource) error {
+func (r *resources) tagResources(res []godo.Resource) error {
 	ctx, cancel := context.WithTimeout(context.Background(), syncTagsTimeout)
 	defer cancel()
-	tag := buildK8sTag(r.resources.clusterID)
-	resp, err := r.resources.gclient.Tags.TagResources(ctx, tag, &godo.TagResourcesRequest{
+	tag := buildK8sTag(r.clusterID)
+	resp, err := r.gclient.Tags.TagResources(ctx, tag, &godo.TagResourcesRequest{
 		Resources: res,
 	})
 
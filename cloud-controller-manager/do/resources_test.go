@@ -153,6 +153,9 @@ func TestResourcesController_Run(t *testing.T) {
 			listFunc: func(ctx context.Context, opt *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
 				return []godo.Droplet{{ID: 2, Name: "two"}}, newFakeOKResponse(), nil
 			},
+			listByTagFunc: func(ctx context.Context, tag string, opt *godo.ListOptions) ([]godo.Droplet, *godo.Response, error) {
+				return []godo.Droplet{{ID: 2, Name: "two"}}, newFakeOKResponse(), nil
+			},
 		},
 		&fakeLBService{
 			listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
@@ -390,3 +393,62 @@ func TestResourcesController_SyncTags(t *testing.T) {
 		})
 	}
 }
+
+func TestResourcesController_SyncTags_clusterID(t *testing.T) {
+	const clusterVPCID = "cluster-vpc"
+
+	tests := []struct {
+		name       string
+		lb         godo.LoadBalancer
+		wantTagged bool
+	}{
+		{
+			name:       "untagged load-balancer in cluster VPC",
+			lb:         godo.LoadBalancer{ID: "1", Name: lbName(1), VPCUUID: clusterVPCID},
+			wantTagged: true,
+		},
+		{
+			name: "untagged load-balancer in foreign VPC",
+			lb:   godo.LoadBalancer{ID: "1", Name: lbName(1), VPCUUID: "other-vpc"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gclient := newFakeLBClient(
+				&fakeLBService{
+					listFn: func(context.Context, *godo.ListOptions) ([]godo.LoadBalancer, *godo.Response, error) {
+						return []godo.LoadBalancer{test.lb}, newFakeOKResponse(), nil
+					},
+				},
+			)
+			fakeTagsService := newFakeTagsService(clusterIDTag)
+			gclient.Tags = fakeTagsService
+			fakeResources := newResources(clusterID, clusterVPCID, publicAccessFirewall{}, gclient)
+
+			kclient := fake.NewSimpleClientset(createLBSvc(1))
+			sharedInformer := informers.NewSharedInformerFactory(kclient, 0)
+			res := NewResourcesController(fakeResources, sharedInformer.Core().V1().Services(), kclient)
+			sharedInformer.Start(nil)
+			sharedInformer.WaitForCacheSync(nil)
+
+			if err := res.syncTags(); err != nil {
+				t.Fatalf("failed to sync tags: %s", err)
+			}
+
+			var want []*godo.TagResourcesRequest
+			if test.wantTagged {
+				want = []*godo.TagResourcesRequest{
+					{
+						Resources: []godo.Resource{
+							{ID: "1", Type: godo.LoadBalancerResourceType},
+						},
+					},
+				}
+			}
+			if !reflect.DeepEqual(fakeTagsService.tagRequests, want) {
+				t.Errorf("got tag requests %+v, want %+v", fakeTagsService.tagRequests, want)
+			}
+		})
+	}
+}